@@ -1,46 +1,151 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"instagram/internal/config"
+	"instagram/internal/grpcapi"
+	"instagram/internal/handlers"
+	"instagram/internal/jobs"
+	"instagram/internal/logging"
+	"instagram/internal/metrics"
 	"instagram/internal/middleware"
+	"instagram/internal/openapi"
+	"instagram/internal/repositories"
 	"instagram/internal/routes"
+	"instagram/internal/server"
+	"instagram/internal/storage"
+	"instagram/internal/tracing"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		panic(err)
+	}
+
+	// Everything is logged as structured records, including output of the standard log package
+	level, err := logging.ParseLevel(cfg.Logging.Level)
+	if err != nil {
+		panic(err)
+	}
+	logger, err := logging.New(os.Stderr, cfg.Logging.Format, level)
+	if err != nil {
+		panic(err)
+	}
+	slog.SetDefault(logger)
+
+	// Requests, middleware, handlers and SQL statements are traced with OpenTelemetry
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		panic(err)
+	}
+
 	mux := http.NewServeMux()
 
-	// Connect to the SQLite database
-	db, err := sql.Open("sqlite3", "instagram.db")
+	// Connect to the SQLite database. The PostgreSQL stores do not cover every repository yet, see the
+	// postgres package.
+	db, err := tracing.OpenDB("sqlite3", cfg.Database.DSN())
+	if err != nil {
+		panic(err)
+	}
+
+	// Bring the schema up to date, databases from older releases are upgraded in place
+	err = repositories.Migrate(context.Background(), db)
+	if err != nil {
+		panic(err)
+	}
+
+	// Uploaded and imported media is stored on local disk
+	media, err := storage.NewLocal(cfg.Media.Dir, "/media")
 	if err != nil {
 		panic(err)
 	}
 
-	// Enable foreign key support in SQLite
-	_, err = db.Exec("PRAGMA foreign_keys = ON;")
+	exporter, err := jobs.NewExporter(db, media, cfg.Exports.Dir, cfg.Exports.Retention)
 	if err != nil {
 		panic(err)
 	}
 
-	// Wrap the mux with the DB middleware, and then with the CORS middleware
+	// The handlers get the database, stores, configuration, media storage and identity providers from the app
+	app := handlers.NewApp(cfg, logger, db, media)
+
+	// Wrap the mux with the CORS middleware. Each middleware gets its own span, the tracing middleware
+	// starts the trace of the request.
 	var muxWithMiddleware http.Handler
-	muxWithMiddleware = middleware.DBMiddleware(mux, db)
-	muxWithMiddleware = middleware.CORSMiddleware(muxWithMiddleware)
-	muxWithMiddleware = middleware.LoggingMiddleware(muxWithMiddleware)
+	muxWithMiddleware = middleware.Traced("CORSMiddleware", middleware.CORSMiddleware(middleware.RecordRoute(mux), cfg.CORS))
+	muxWithMiddleware = middleware.Traced("MetricsMiddleware", middleware.MetricsMiddleware(muxWithMiddleware))
+	muxWithMiddleware = middleware.Traced("LoggingMiddleware", middleware.LoggingMiddleware(muxWithMiddleware, app.Logger))
+	muxWithMiddleware = middleware.Traced("RequestIDMiddleware", middleware.RequestIDMiddleware(muxWithMiddleware))
+	muxWithMiddleware = middleware.TracingMiddleware(muxWithMiddleware)
+
+	// Protect /users/ and /follow/ routes with JWTMiddleware. Every router records its matched route
+	// pattern for the access log.
+	protected := func(router *http.ServeMux) http.Handler {
+		return middleware.Traced("JWTMiddleware", middleware.JWTMiddleware(middleware.RecordRoute(router), db, cfg.Auth.JWTSecret))
+	}
+	mux.Handle("/users/", protected(routes.UserRouter(app)))
+	mux.Handle("/follow/", protected(routes.FollowRouter(app)))
+	mux.Handle("/post/", protected(routes.PostRouter(app)))
+	mux.Handle("/comment/", protected(routes.CommentRouter(app)))
+	mux.Handle("/report/", protected(routes.ReportRouter(app)))
+	mux.Handle("/admin/", protected(routes.AdminRouter(app)))
+	mux.Handle("/import/", protected(routes.ImportRouter(app)))
+	mux.Handle("/graphql", protected(routes.GraphQLRouter(app)))
 
-	// Protect /users/ and /follow/ routes with JWTMiddleware
-	mux.Handle("/users/", middleware.JWTMiddleware(routes.UserRouter()))
-	mux.Handle("/follow/", middleware.JWTMiddleware(routes.FollowRouter()))
-	mux.Handle("/post/", middleware.JWTMiddleware(routes.PostRouter()))
-	mux.Handle("/comment/", middleware.JWTMiddleware(routes.CommentRouter()))
+	mux.Handle("GET /media/", media.Handler())
 
 	// Do not protect /auth/ route (for login, registration, etc.)
-	mux.Handle("/auth/", routes.AuthRouter())
+	mux.Handle("/auth/", middleware.RecordRoute(routes.AuthRouter(app)))
+	mux.Handle("/export/", middleware.RecordRoute(routes.DataExportRouter(app)))
+
+	// The API is described by an OpenAPI document, browsable at /docs/
+	mux.Handle("GET /openapi.json", openapi.Handler())
+	mux.Handle("GET /docs/", openapi.DocsHandler("/docs"))
 
-	fmt.Println("Server is running on port 8080")
-	err = http.ListenAndServe(":8080", muxWithMiddleware)
+	srv := server.New(cfg.Server, db, muxWithMiddleware)
+	srv.AddReadinessCheck("Database schema", func(ctx context.Context) error { return repositories.CheckSchema(ctx, db) })
+	srv.Handle("GET /metrics", metrics.Handler(metrics.NewRegistry(db)))
+
+	// Deleted users and content can be restored for a grace period, then they are purged for good
+	purger := jobs.NewPurger(db, media, cfg.Deletion.GracePeriod)
+	srv.Go(func(ctx context.Context) { purger.Run(ctx, time.Hour) })
+
+	// Data exports are built in the background and kept for the retention period
+	srv.Go(func(ctx context.Context) { exporter.Run(ctx, 10*time.Second) })
+
+	// Internal services use the gRPC API on its own port
+	if cfg.Server.GRPCAddr != "" {
+		grpcServer := grpcapi.New(cfg, db, app.Services)
+		srv.Go(func(ctx context.Context) {
+			if err := grpcServer.Run(ctx); err != nil {
+				slog.Error("gRPC server failed", "error", err)
+			}
+		})
+	}
+
+	// SIGTERM (or Ctrl+C) drains in-flight requests and background jobs before exiting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = srv.Run(ctx)
+	if closeErr := db.Close(); closeErr != nil {
+		slog.Error("Failed to close database", "error", closeErr)
+	}
+	// The server is stopped already, so the remaining spans get a fresh deadline to be flushed
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if tracingErr := shutdownTracing(flushCtx); tracingErr != nil {
+		slog.Error("Failed to flush traces", "error", tracingErr)
+	}
 	if err != nil {
-		panic(err)
+		slog.Error("Server failed", "error", err)
+		os.Exit(1)
 	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"instagram/internal/models"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GetUser returns a user
+func (c *Client) GetUser(ctx context.Context, userID int) (*models.User, error) {
+	var user models.User
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", userID), nil, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateUser replaces the username, email, bio and profile image of user.ID and returns the result
+func (c *Client) UpdateUser(ctx context.Context, user models.User) (*models.User, error) {
+	var updated models.User
+	err := c.do(ctx, http.MethodPatch, "/users/", user, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteUser deletes a user. The account can be restored until it is purged.
+func (c *Client) DeleteUser(ctx context.Context, userID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", userID), nil, nil)
+}
+
+// CreatePost publishes a post and returns it with its ID
+func (c *Client) CreatePost(ctx context.Context, post models.Post) (*models.Post, error) {
+	var created models.Post
+	err := c.do(ctx, http.MethodPost, "/post/", post, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetPost returns a post
+func (c *Client) GetPost(ctx context.Context, postID int) (*models.Post, error) {
+	var post models.Post
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/post/%d", postID), nil, &post)
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// DeletePost deletes a post. It can be restored with RestorePost until it is purged.
+func (c *Client) DeletePost(ctx context.Context, postID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/post/%d", postID), nil, nil)
+}
+
+// RestorePost undoes the deletion of a post
+func (c *Client) RestorePost(ctx context.Context, postID int) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/post/%d/restore", postID), nil, nil)
+}
+
+// PostsForUser iterates over the posts of a user, newest first
+func (c *Client) PostsForUser(ctx context.Context, userID int) iter.Seq2[models.Post, error] {
+	return paginate[models.Post](ctx, c, fmt.Sprintf("/post/user/%d", userID))
+}
+
+// Feed iterates over the posts of the users a user follows, newest first
+func (c *Client) Feed(ctx context.Context, userID int) iter.Seq2[models.FeedPost, error] {
+	return paginate[models.FeedPost](ctx, c, fmt.Sprintf("/post/feed/%d", userID))
+}
+
+// CreateComment comments on a post
+func (c *Client) CreateComment(ctx context.Context, comment models.Comment) error {
+	return c.do(ctx, http.MethodPost, "/comment/", comment, nil)
+}
+
+// GetComment returns a comment
+func (c *Client) GetComment(ctx context.Context, commentID int) (*models.Comment, error) {
+	var comment models.Comment
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/comment/%d", commentID), nil, &comment)
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// DeleteComment deletes a comment. It can be restored with RestoreComment until it is purged.
+func (c *Client) DeleteComment(ctx context.Context, commentID int) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/comment/%d", commentID), nil, nil)
+}
+
+// RestoreComment undoes the deletion of a comment
+func (c *Client) RestoreComment(ctx context.Context, commentID int) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/comment/%d/restore", commentID), nil, nil)
+}
+
+// CommentsForPost iterates over the comments of a post, oldest first
+func (c *Client) CommentsForPost(ctx context.Context, postID int) iter.Seq2[models.Comment, error] {
+	return paginate[models.Comment](ctx, c, fmt.Sprintf("/comment/post/%d", postID))
+}
+
+// Follow makes follow.FollowerID follow follow.FollowingID
+func (c *Client) Follow(ctx context.Context, follow models.Follow) error {
+	return c.do(ctx, http.MethodPost, "/follow/", follow, nil)
+}
+
+// Unfollow ends a follow
+func (c *Client) Unfollow(ctx context.Context, follow models.Follow) error {
+	return c.do(ctx, http.MethodDelete, "/follow/", follow, nil)
+}
+
+// paginate iterates over a list endpoint, fetching a page of PageSize entries at a time. Iteration
+// stops at the first error, which is yielded with a zero entry.
+func paginate[T any](ctx context.Context, c *Client, path string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		pageSize := min(c.PageSize, maxPageSize)
+		if pageSize <= 0 {
+			pageSize = DefaultPageSize
+		}
+
+		offset := 0
+		for {
+			query := url.Values{"limit": {strconv.Itoa(pageSize)}, "offset": {strconv.Itoa(offset)}}
+
+			var page []T
+			err := c.do(ctx, http.MethodGet, path+"?"+query.Encode(), nil, &page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, entry := range page {
+				if !yield(entry, nil) {
+					return
+				}
+			}
+			if len(page) < pageSize {
+				return
+			}
+			offset += len(page)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+// Package client is a typed client for the API, for tools and integration tests written in Go. It
+// speaks the models of the server, renews sessions it logged in with and reports error responses as
+// *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is the number of entries the list iterators fetch per request
+const DefaultPageSize = 100
+
+// maxPageSize is the most entries the server returns per page
+const maxPageSize = 500
+
+// refreshMargin is how long before it expires a session is renewed
+const refreshMargin = time.Minute
+
+type Client struct {
+	// PageSize is the number of entries the list iterators fetch per request
+	PageSize int
+
+	baseURL string
+	http    *http.Client
+
+	mu          sync.Mutex
+	token       string
+	expiresAt   time.Time    // expiresAt is zero for tokens that are not renewed, like personal access tokens
+	userID      int          // userID is the ID of the logged in user, 0 when authenticated with SetToken
+	credentials *models.User // credentials log in again when the session expires
+}
+
+// New returns a client for the API at baseURL, such as "https://api.example.com". A nil httpClient
+// uses http.DefaultClient.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		PageSize: DefaultPageSize,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		http:     httpClient,
+	}
+}
+
+// SetToken authenticates with a token that is not renewed, such as a personal access token
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.token = token
+	c.expiresAt = time.Time{}
+	c.userID = 0
+	c.credentials = nil
+}
+
+// UserID returns the ID of the logged in user
+func (c *Client) UserID() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.userID
+}
+
+// Signup creates an account and logs in with it
+func (c *Client) Signup(ctx context.Context, user models.User) (*models.TokenResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var session models.TokenResponse
+	err := c.send(ctx, http.MethodPost, "/auth/signup", "", user, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setSession(&session, &models.User{Auth: models.Auth{Email: user.Email}, Password: user.Password})
+	return &session, nil
+}
+
+// Login logs in with an email and password. The session is renewed with them when it expires. Accounts
+// with a second factor get a *MFARequiredError, to be completed with VerifyMFA.
+func (c *Client) Login(ctx context.Context, email, password string) (*models.TokenResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.login(ctx, &models.User{Auth: models.Auth{Email: email}, Password: password})
+}
+
+// VerifyMFA completes a login with a code of the authenticator or a recovery code. The session cannot
+// be renewed without another code, so it ends when it expires.
+func (c *Client) VerifyMFA(ctx context.Context, mfaToken, code string) (*models.TokenResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var session models.TokenResponse
+	err := c.send(ctx, http.MethodPost, "/auth/mfa/verify", "", models.MFAChallenge{MFAToken: mfaToken, Code: code}, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setSession(&session, nil)
+	return &session, nil
+}
+
+// login logs in with the credentials. c.mu must be held.
+func (c *Client) login(ctx context.Context, credentials *models.User) (*models.TokenResponse, error) {
+	var response json.RawMessage
+	err := c.send(ctx, http.MethodPost, "/auth/login", "", credentials, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var challenge models.MFARequired
+	err = json.Unmarshal(response, &challenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode login response: %w", err)
+	}
+	if challenge.MFARequired {
+		return nil, &MFARequiredError{MFAToken: challenge.MFAToken}
+	}
+
+	var session models.TokenResponse
+	err = json.Unmarshal(response, &session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode login response: %w", err)
+	}
+
+	c.setSession(&session, credentials)
+	return &session, nil
+}
+
+// setSession authenticates further requests with a new session. c.mu must be held.
+func (c *Client) setSession(session *models.TokenResponse, credentials *models.User) {
+	c.token = session.Token
+	c.expiresAt = session.ExpiresAt
+	c.userID = session.ID
+	c.credentials = credentials
+}
+
+// session returns the token to authenticate a request with. It logs in again when the session is about
+// to expire, or when the server rejected it.
+func (c *Client) session(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiring := !c.expiresAt.IsZero() && time.Until(c.expiresAt) < refreshMargin
+	if c.credentials != nil && (expiring || (rejected != "" && rejected == c.token)) {
+		_, err := c.login(ctx, c.credentials)
+		if err != nil {
+			return "", fmt.Errorf("failed to renew session: %w", err)
+		}
+	}
+	return c.token, nil
+}
+
+// do sends an authenticated request. A request rejected as unauthorized is retried once with a renewed
+// session, as sessions can end before they expire, e.g. when the password is changed elsewhere.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	token, err := c.session(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	err = c.send(ctx, method, path, token, in, out)
+	if err != nil && c.renewable(err) {
+		renewed, renewErr := c.session(ctx, token)
+		if renewErr == nil && renewed != token {
+			err = c.send(ctx, method, path, renewed, in, out)
+		}
+	}
+	return err
+}
+
+func (c *Client) renewable(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized && c.credentials != nil
+}
+
+// send sends a request with in encoded as JSON, and decodes the response into out. Error responses are
+// returned as *Error.
+func (c *Client) send(ctx context.Context, method, path, token string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if in != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set("Accept", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send %s %s: %w", method, path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		apiErr := &Error{StatusCode: response.StatusCode}
+		// Responses that are not problem details, e.g. from a proxy, are reported by status alone
+		_ = json.NewDecoder(response.Body).Decode(&apiErr.Problem)
+		return apiErr
+	}
+
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	err = json.NewDecoder(response.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
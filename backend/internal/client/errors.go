@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"instagram/internal/problem"
+	"net/http"
+)
+
+// Errors that responses are matched against with errors.Is
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrRateLimited  = errors.New("rate limited")
+
+	// ErrMFARequired is returned when a login needs a second factor, see MFARequiredError
+	ErrMFARequired = errors.New("second factor required")
+)
+
+var statusErrors = map[int]error{
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusConflict:            ErrConflict,
+	http.StatusUnprocessableEntity: ErrValidation,
+	http.StatusTooManyRequests:     ErrRateLimited,
+}
+
+// Error is an error response of the API. Problem holds the problem details of the response, including
+// the invalid fields of requests that failed validation.
+type Error struct {
+	StatusCode int
+	Problem    problem.Details
+}
+
+func (e *Error) Error() string {
+	if e.Problem.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Problem.Detail)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Is matches the error against the error of its status code, e.g. ErrNotFound for 404 responses
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// MFARequiredError is returned by Login for accounts with a second factor. The login is completed with
+// VerifyMFA and the MFA token.
+type MFARequiredError struct {
+	MFAToken string
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFARequiredError) Is(target error) bool {
+	return target == ErrMFARequired
+}
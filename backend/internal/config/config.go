@@ -0,0 +1,450 @@
+// Package config loads the server configuration. Values come from the defaults, then an optional YAML
+// file, then the environment, then command line flags, each overriding the previous one.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"instagram/internal/logging"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// MinJWTSecretLength is the shortest accepted JWT signing secret, in bytes
+const MinJWTSecretLength = 32
+
+// DefaultDeletionGracePeriod is how long deleted accounts and content can be restored before they are purged
+const DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+// DefaultExportRetention is how long a finished data export archive can be downloaded
+const DefaultExportRetention = 7 * 24 * time.Hour
+
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Auth     AuthConfig     `yaml:"auth"`
+	OIDC     OIDCConfig     `yaml:"oidc"`
+	Media    MediaConfig    `yaml:"media"`
+	Exports  ExportsConfig  `yaml:"exports"`
+	Deletion DeletionConfig `yaml:"deletion"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+}
+
+// ServerConfig holds the HTTP server settings. ShutdownTimeout bounds how long in-flight requests and
+// background jobs are waited for after SIGTERM. GRPCAddr is where the gRPC API is served, it is not
+// served when empty.
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	GRPCAddr          string        `yaml:"grpc_addr"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Path string `yaml:"path"`
+}
+
+// DSN returns the data source name of the SQLite database. Foreign keys are enforced on every connection
+// of the pool, which a PRAGMA on one connection would not do.
+func (c DatabaseConfig) DSN() string {
+	separator := "?"
+	if strings.Contains(c.Path, "?") {
+		separator = "&"
+	}
+	return c.Path + separator + "_foreign_keys=on"
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+}
+
+type AuthConfig struct {
+	JWTSecret  string `yaml:"jwt_secret"`
+	BcryptCost int    `yaml:"bcrypt_cost"`
+}
+
+// OIDCConfig lists the external OpenID Connect identity providers users can log in with
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig configures an identity provider. Name is part of the login and callback URLs,
+// RedirectURL is the provider's callback URL on this server. Scopes default to openid, email and profile.
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// oidcProviderName restricts provider names to what fits a URL path segment and an environment variable name
+var oidcProviderName = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+type MediaConfig struct {
+	Dir string `yaml:"dir"`
+}
+
+type ExportsConfig struct {
+	Dir       string        `yaml:"dir"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+type DeletionConfig struct {
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// LoggingConfig selects the log format, "json" or "text", and the lowest level that is logged
+type LoggingConfig struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// Trace exporters
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingFile   = "file"
+	TracingOTLP   = "otlp"
+)
+
+// TracingConfig selects where OpenTelemetry spans are exported. File is the path spans are written to
+// with the file exporter, Endpoint the OTLP/HTTP collector URL with the otlp exporter. SampleRatio is
+// the share of new traces that are recorded, traces started by callers follow the caller's decision.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"`
+	File        string  `yaml:"file"`
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Default returns the configuration used for everything that is not configured. There is no default
+// JWT secret, it has to be configured.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			GRPCAddr:          ":9090",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+			WriteTimeout:      time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{Path: "instagram.db"},
+		CORS:     CORSConfig{AllowedOrigins: []string{"*"}},
+		Auth:     AuthConfig{BcryptCost: 14},
+		Media:    MediaConfig{Dir: "media"},
+		Exports:  ExportsConfig{Dir: "exports", Retention: DefaultExportRetention},
+		Deletion: DeletionConfig{GracePeriod: DefaultDeletionGracePeriod},
+		Logging:  LoggingConfig{Format: "json", Level: "info"},
+		Tracing:  TracingConfig{Exporter: TracingNone, File: "traces.json", SampleRatio: 1},
+	}
+}
+
+// Load builds the configuration from the command line arguments (without the program name) and the
+// environment, and validates it. The file is given with -config or CONFIG_FILE.
+func Load(args []string) (*Config, error) {
+	flags := flag.NewFlagSet("instagram", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	addr := flags.String("addr", "", "address to listen on, e.g. :8080")
+	grpcAddr := flags.String("grpc-addr", "", "address the gRPC API listens on, e.g. :9090")
+	dbPath := flags.String("db", "", "path to the SQLite database")
+	mediaDir := flags.String("media-dir", "", "directory uploaded and imported media is stored in")
+	exportDir := flags.String("export-dir", "", "directory data exports are built in")
+	if err := flags.Parse(args); err != nil {
+		return nil, fmt.Errorf("invalid flags: %w", err)
+	}
+
+	cfg := Default()
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	// Only flags that were given override the file and the environment
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = *addr
+		case "grpc-addr":
+			cfg.Server.GRPCAddr = *grpcAddr
+		case "db":
+			cfg.Database.Path = *dbPath
+		case "media-dir":
+			cfg.Media.Dir = *mediaDir
+		case "export-dir":
+			cfg.Exports.Dir = *exportDir
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variables that are set. lookup is os.LookupEnv outside of tests.
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	stringVars := map[string]*string{
+		"SERVER_ADDR":      &c.Server.Addr,
+		"SERVER_GRPC_ADDR": &c.Server.GRPCAddr,
+		"DATABASE_PATH":    &c.Database.Path,
+		"JWT_SECRET":       &c.Auth.JWTSecret,
+		"MEDIA_DIR":        &c.Media.Dir,
+		"EXPORT_DIR":       &c.Exports.Dir,
+		"LOG_FORMAT":       &c.Logging.Format,
+		"LOG_LEVEL":        &c.Logging.Level,
+		"TRACING_EXPORTER": &c.Tracing.Exporter,
+		"TRACING_FILE":     &c.Tracing.File,
+		"TRACING_ENDPOINT": &c.Tracing.Endpoint,
+	}
+	for name, target := range stringVars {
+		if value, ok := lookup(name); ok {
+			*target = value
+		}
+	}
+
+	durationVars := map[string]*time.Duration{
+		"SERVER_READ_HEADER_TIMEOUT": &c.Server.ReadHeaderTimeout,
+		"SERVER_READ_TIMEOUT":        &c.Server.ReadTimeout,
+		"SERVER_WRITE_TIMEOUT":       &c.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        &c.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":    &c.Server.ShutdownTimeout,
+		"EXPORT_RETENTION":           &c.Exports.Retention,
+		"DELETION_GRACE_PERIOD":      &c.Deletion.GracePeriod,
+	}
+	for name, target := range durationVars {
+		if value, ok := lookup(name); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = duration
+		}
+	}
+
+	if value, ok := lookup("CORS_ALLOWED_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = strings.Fields(strings.ReplaceAll(value, ",", " "))
+	}
+	if value, ok := lookup("CORS_ALLOW_CREDENTIALS"); ok {
+		allow, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS: %w", err)
+		}
+		c.CORS.AllowCredentials = allow
+	}
+	if value, ok := lookup("TRACING_SAMPLE_RATIO"); ok {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TRACING_SAMPLE_RATIO: %w", err)
+		}
+		c.Tracing.SampleRatio = ratio
+	}
+	if value, ok := lookup("BCRYPT_COST"); ok {
+		cost, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid BCRYPT_COST: %w", err)
+		}
+		c.Auth.BcryptCost = cost
+	}
+	if value, ok := lookup("OIDC_PROVIDERS"); ok {
+		c.loadOIDCEnv(value, lookup)
+	}
+
+	return nil
+}
+
+// loadOIDCEnv replaces the identity providers with those listed in OIDC_PROVIDERS. Each provider is configured
+// with OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL and _SCOPES (space separated), which
+// override the settings of the provider with the same name in the file.
+func (c *Config) loadOIDCEnv(names string, lookup func(string) (string, bool)) {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Fields(strings.ReplaceAll(names, ",", " ")) {
+		provider := OIDCProviderConfig{Name: name}
+		for _, fromFile := range c.OIDC.Providers {
+			if fromFile.Name == name {
+				provider = fromFile
+			}
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		stringVars := map[string]*string{
+			"ISSUER":        &provider.Issuer,
+			"CLIENT_ID":     &provider.ClientID,
+			"CLIENT_SECRET": &provider.ClientSecret,
+			"REDIRECT_URL":  &provider.RedirectURL,
+		}
+		for suffix, target := range stringVars {
+			if value, ok := lookup(prefix + suffix); ok {
+				*target = value
+			}
+		}
+		if value, ok := lookup(prefix + "SCOPES"); ok {
+			provider.Scopes = strings.Fields(value)
+		}
+
+		providers = append(providers, provider)
+	}
+	c.OIDC.Providers = providers
+}
+
+// Validate reports every problem with the configuration at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.GRPCAddr != "" && c.Server.GRPCAddr == c.Server.Addr {
+		errs = append(errs, errors.New("server.grpc_addr must differ from server.addr"))
+	}
+	serverTimeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"read_timeout", c.Server.ReadTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+		{"shutdown_timeout", c.Server.ShutdownTimeout},
+	}
+	for _, timeout := range serverTimeouts {
+		if timeout.value <= 0 {
+			errs = append(errs, fmt.Errorf("server.%s must be positive", timeout.name))
+		}
+	}
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path is required"))
+	}
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allowed_origins must list at least one origin"))
+	}
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowedOrigins, "*") {
+		errs = append(errs, errors.New("cors.allow_credentials cannot be combined with the * origin"))
+	}
+	if len(c.Auth.JWTSecret) < MinJWTSecretLength {
+		errs = append(errs, fmt.Errorf("auth.jwt_secret (JWT_SECRET) must be at least %d bytes", MinJWTSecretLength))
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("auth.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	errs = append(errs, c.OIDC.validate()...)
+	if c.Media.Dir == "" {
+		errs = append(errs, errors.New("media.dir is required"))
+	}
+	if c.Exports.Dir == "" {
+		errs = append(errs, errors.New("exports.dir is required"))
+	}
+	if c.Exports.Retention <= 0 {
+		errs = append(errs, errors.New("exports.retention must be positive"))
+	}
+	if c.Deletion.GracePeriod <= 0 {
+		errs = append(errs, errors.New("deletion.grace_period must be positive"))
+	}
+
+	if _, err := logging.New(io.Discard, c.Logging.Format, slog.LevelInfo); err != nil {
+		errs = append(errs, fmt.Errorf("logging.format: %w", err))
+	}
+	if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+		errs = append(errs, fmt.Errorf("logging.level: %w", err))
+	}
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, errors.New("tracing.file is required with the file exporter"))
+		}
+	case TracingOTLP:
+		if c.Tracing.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required with the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be one of none, stdout, file or otlp"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c OIDCConfig) validate() []error {
+	var errs []error
+
+	names := map[string]bool{}
+	for i, provider := range c.Providers {
+		field := fmt.Sprintf("oidc.providers[%d]", i)
+		if !oidcProviderName.MatchString(provider.Name) {
+			errs = append(errs, fmt.Errorf("%s.name must be lower case letters, digits and underscores", field))
+		} else if names[provider.Name] {
+			errs = append(errs, fmt.Errorf("%s.name %s is configured twice", field, provider.Name))
+		}
+		names[provider.Name] = true
+
+		if issuer, err := url.Parse(provider.Issuer); err != nil || !isHTTPURL(issuer) {
+			errs = append(errs, fmt.Errorf("%s.issuer must be an http or https URL", field))
+		}
+		if provider.ClientID == "" {
+			errs = append(errs, fmt.Errorf("%s.client_id is required", field))
+		}
+		if provider.ClientSecret == "" {
+			errs = append(errs, fmt.Errorf("%s.client_secret is required", field))
+		}
+		// The login's state cookie is scoped to the provider's path, the callback has to be under it
+		callbackPath := "/auth/oidc/" + provider.Name + "/callback"
+		if redirect, err := url.Parse(provider.RedirectURL); err != nil || !isHTTPURL(redirect) || redirect.Path != callbackPath {
+			errs = append(errs, fmt.Errorf("%s.redirect_url must be an http or https URL with the path %s", field, callbackPath))
+		}
+	}
+
+	return errs
+}
+
+// isHTTPURL reports whether u is an absolute http or https URL
+func isHTTPURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
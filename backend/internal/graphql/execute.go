@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Execute runs a GraphQL request against Schema with the authenticated user of ctx. Single items and pages
+// are read through the services, nested fields are loaded in batches from the stores. Requests that do
+// not parse, are invalid or exceed the limits get a result without data.
+func Execute(ctx context.Context, svc *services.Services, stores *repositories.Stores, request models.GraphQLRequest) *gql.Result {
+	schema := Schema()
+
+	document, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := gql.ValidateDocument(&schema, document, nil)
+	if !validation.IsValid {
+		return &gql.Result{Errors: validation.Errors}
+	}
+
+	err = checkLimits(&schema, document, request.OperationName, request.Variables)
+	if err != nil {
+		return &gql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return gql.Execute(gql.ExecuteParams{
+		Schema:        schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       withLoaders(ctx, newLoaders(svc, stores)),
+	})
+}
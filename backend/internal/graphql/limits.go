@@ -0,0 +1,158 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	gql "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// MaxDepth is the deepest a query may nest fields, e.g. feed { author { followers { username } } } is 4
+	MaxDepth = 8
+
+	// MaxComplexity is the highest cost a query may have. Every field costs 1, and the fields selected
+	// under a list are counted once per entry the list may return.
+	MaxComplexity = 5000
+)
+
+// cost is the depth and complexity of a selection
+type cost struct {
+	depth      int
+	complexity int
+}
+
+// checkLimits rejects the operation of a query that is nested deeper than MaxDepth or more complex than
+// MaxComplexity, before any of it is resolved. Introspection fields are not counted, they do not touch the
+// database.
+func checkLimits(schema *gql.Schema, document *ast.Document, operationName string, variables map[string]interface{}) error {
+	fragments := map[string]*ast.FragmentDefinition{}
+	var operations []*ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operations = append(operations, definition)
+			}
+		}
+	}
+	// Unknown or ambiguous operations are reported by the executor
+	if len(operations) != 1 || operations[0].Operation != ast.OperationTypeQuery {
+		return nil
+	}
+
+	a := analyzer{fragments: fragments, variables: variables, visiting: map[string]bool{}}
+	total := a.selectionSet(operations[0].SelectionSet, schema.QueryType())
+	if total.depth > MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the maximum of %d", total.depth, MaxDepth)
+	}
+	if total.complexity > MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", total.complexity, MaxComplexity)
+	}
+	return nil
+}
+
+type analyzer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	// visiting holds the fragments being spread, cycles are reported by the validation
+	visiting map[string]bool
+}
+
+func (a *analyzer) selectionSet(selectionSet *ast.SelectionSet, parent *gql.Object) cost {
+	var total cost
+	if selectionSet == nil || parent == nil {
+		return total
+	}
+
+	for _, selection := range selectionSet.Selections {
+		var c cost
+		switch selection := selection.(type) {
+		case *ast.Field:
+			c = a.field(selection, parent)
+		case *ast.InlineFragment:
+			c = a.selectionSet(selection.SelectionSet, parent)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := a.fragments[name]
+			if !ok || a.visiting[name] {
+				continue
+			}
+			a.visiting[name] = true
+			c = a.selectionSet(fragment.SelectionSet, parent)
+			delete(a.visiting, name)
+		}
+		total.depth = max(total.depth, c.depth)
+		total.complexity += c.complexity
+	}
+	return total
+}
+
+func (a *analyzer) field(field *ast.Field, parent *gql.Object) cost {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return cost{}
+	}
+	definition, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		return cost{}
+	}
+
+	object, _ := gql.GetNamed(definition.Type).(*gql.Object)
+	children := a.selectionSet(field.SelectionSet, object)
+
+	entries := 1
+	if isList(definition.Type) {
+		entries = a.listSize(field, definition)
+	}
+	return cost{depth: 1 + children.depth, complexity: 1 + entries*children.complexity}
+}
+
+// listSize returns the number of entries a list field may return, from its limit or first argument
+func (a *analyzer) listSize(field *ast.Field, definition *gql.FieldDefinition) int {
+	for _, argument := range definition.Args {
+		if argument.Name() != "limit" && argument.Name() != "first" {
+			continue
+		}
+
+		size, _ := argument.DefaultValue.(int)
+		for _, given := range field.Arguments {
+			if given.Name.Value == argument.Name() {
+				size = a.intValue(given.Value, size)
+			}
+		}
+		return max(size, 1)
+	}
+	return defaultListLimit
+}
+
+// intValue returns the value of an Int argument given as a literal or a variable
+func (a *analyzer) intValue(value ast.Value, fallback int) int {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		n, err := strconv.Atoi(value.Value)
+		if err == nil {
+			return n
+		}
+	case *ast.Variable:
+		// Variables decoded from JSON are numbers
+		switch n := a.variables[value.Name.Value].(type) {
+		case float64:
+			return int(n)
+		case int:
+			return n
+		}
+	}
+	return fallback
+}
+
+func isList(t gql.Type) bool {
+	if nonNull, ok := t.(*gql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*gql.List)
+	return ok
+}
@@ -0,0 +1,126 @@
+package graphql
+
+import (
+	"context"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"slices"
+	"sync"
+)
+
+// loader batches loads by ID. Resolvers queue the IDs they need and return a thunk; the executor runs
+// the thunks of a level of the query after all of its resolvers, so the first thunk fetches every
+// queued ID in one query. Results are cached for the rest of the request.
+type loader[V any] struct {
+	fetch func(ctx context.Context, ids []int) (map[int]V, error)
+
+	mu      sync.Mutex
+	pending []int
+	values  map[int]V
+	errs    map[int]error
+}
+
+func newLoader[V any](fetch func(ctx context.Context, ids []int) (map[int]V, error)) *loader[V] {
+	return &loader[V]{fetch: fetch, values: map[int]V{}, errs: map[int]error{}}
+}
+
+// queue adds an ID to the next batch
+func (l *loader[V]) queue(id int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.values[id]; ok {
+		return
+	}
+	if _, ok := l.errs[id]; ok {
+		return
+	}
+	if !slices.Contains(l.pending, id) {
+		l.pending = append(l.pending, id)
+	}
+}
+
+// get returns the value of a queued ID, fetching the pending batch if it has not been yet. IDs without
+// a value get the zero value.
+func (l *loader[V]) get(ctx context.Context, id int) (V, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, loaded := l.values[id]
+	if _, failed := l.errs[id]; !loaded && !failed {
+		ids := l.pending
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+		l.pending = nil
+
+		values, err := l.fetch(ctx, ids)
+		for _, pendingID := range ids {
+			if err != nil {
+				l.errs[pendingID] = err
+				continue
+			}
+			l.values[pendingID] = values[pendingID]
+		}
+	}
+
+	return l.values[id], l.errs[id]
+}
+
+// loaders are the loaders of a request
+type loaders struct {
+	users           *loader[*models.User]
+	posts           *loader[*models.Post]
+	commentCounts   *loader[int]
+	likeCounts      *loader[int]
+	followerCounts  *loader[int]
+	followingCounts *loader[int]
+
+	mu sync.Mutex
+	// comments holds a loader of the first comments of posts per number of comments
+	comments map[int]*loader[[]models.Comment]
+
+	// services answer the queries for single items and pages, which apply who may see what
+	services *services.Services
+	stores   *repositories.Stores
+}
+
+func newLoaders(svc *services.Services, stores *repositories.Stores) *loaders {
+	return &loaders{
+		users:           newLoader(stores.Users.GetMany),
+		posts:           newLoader(stores.Posts.GetMany),
+		commentCounts:   newLoader(stores.Comments.CountForPosts),
+		likeCounts:      newLoader(stores.Likes.CountForPosts),
+		followerCounts:  newLoader(stores.Follows.CountFollowers),
+		followingCounts: newLoader(stores.Follows.CountFollowing),
+		comments:        map[int]*loader[[]models.Comment]{},
+		services:        svc,
+		stores:          stores,
+	}
+}
+
+// firstComments returns the loader of the first n comments of posts
+func (l *loaders) firstComments(n int) *loader[[]models.Comment] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	comments, ok := l.comments[n]
+	if !ok {
+		comments = newLoader(func(ctx context.Context, ids []int) (map[int][]models.Comment, error) {
+			return l.stores.Comments.FirstForPosts(ctx, ids, n)
+		})
+		l.comments[n] = comments
+	}
+	return comments
+}
+
+type loadersContextKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersContextKey{}, l)
+}
+
+func loadersFromContext(ctx context.Context) *loaders {
+	return ctx.Value(loadersContextKey{}).(*loaders)
+}
@@ -0,0 +1,408 @@
+// Package graphql serves a GraphQL schema over the repositories, for clients that need a post, its
+// author, comment previews and counts in one round trip. Related objects are loaded in batches per
+// level of the query, and queries are rejected up front when they are too deep or too costly.
+package graphql
+
+import (
+	"context"
+	"errors"
+	"instagram/internal/logging"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"slices"
+	"sync"
+
+	gql "github.com/graphql-go/graphql"
+)
+
+const (
+	// defaultListLimit is the number of entries of list fields without a limit argument
+	defaultListLimit = 20
+
+	// maxListLimit is the most entries a list field returns
+	maxListLimit = 100
+
+	// defaultCommentPreviews and maxCommentPreviews bound the comments shown with a post
+	defaultCommentPreviews = 3
+	maxCommentPreviews     = 20
+)
+
+// Schema is the schema served by the handler. It is built once, its definition is static.
+var Schema = sync.OnceValue(func() gql.Schema {
+	schema, err := gql.NewSchema(gql.SchemaConfig{Query: queryType()})
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	return schema
+})
+
+// errInternal hides the errors of the repositories from clients, they are logged instead
+var errInternal = errors.New("internal error")
+
+func queryType() *gql.Object {
+	userType := gql.NewObject(gql.ObjectConfig{Name: "User", Fields: gql.Fields{}})
+	postType := gql.NewObject(gql.ObjectConfig{Name: "Post", Fields: gql.Fields{}})
+	commentType := gql.NewObject(gql.ObjectConfig{Name: "Comment", Fields: gql.Fields{}})
+
+	addFields(userType, gql.Fields{
+		"id":           field(gql.NewNonNull(gql.Int), func(u *models.User) interface{} { return u.ID }),
+		"username":     field(gql.NewNonNull(gql.String), func(u *models.User) interface{} { return u.Username }),
+		"bio":          field(gql.String, func(u *models.User) interface{} { return u.Bio }),
+		"profileImage": field(gql.String, func(u *models.User) interface{} { return u.ProfileImage }),
+		"createdAt":    field(gql.NewNonNull(gql.DateTime), func(u *models.User) interface{} { return u.CreatedAt }),
+		"email": {
+			Type:        gql.String,
+			Description: "Only visible to the user and admins",
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				user := p.Source.(*models.User)
+				if !services.IsOwnerOrAdmin(p.Context, user.ID) {
+					return nil, nil
+				}
+				return user.Email, nil
+			},
+		},
+		"followerCount": {
+			Type: gql.NewNonNull(gql.Int),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadCount(p.Context, loadersFromContext(p.Context).followerCounts, p.Source.(*models.User).ID)
+			},
+		},
+		"followingCount": {
+			Type: gql.NewNonNull(gql.Int),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadCount(p.Context, loadersFromContext(p.Context).followingCounts, p.Source.(*models.User).ID)
+			},
+		},
+		"followers": {
+			Type:        listOf(userType),
+			Description: "Most recent follow first",
+			Args:        pageArgs(),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadFollows(p, repositories.FollowStore.FollowerIDs)
+			},
+		},
+		"following": {
+			Type:        listOf(userType),
+			Description: "Most recent follow first",
+			Args:        pageArgs(),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadFollows(p, repositories.FollowStore.FollowingIDs)
+			},
+		},
+		"posts": {
+			Type:        listOf(postType),
+			Description: "Newest first",
+			Args:        pageArgs(),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				if err := requireScope(p.Context, models.ScopePostsRead); err != nil {
+					return nil, err
+				}
+
+				posts, err := loadersFromContext(p.Context).services.Posts.ListForUser(p.Context, p.Source.(*models.User).ID, pageFromArgs(p.Args))
+				if err != nil {
+					return nil, internalError(p.Context, err)
+				}
+				return pointers(posts), nil
+			},
+		},
+	})
+
+	addFields(postType, gql.Fields{
+		"id":        field(gql.NewNonNull(gql.Int), func(p *models.Post) interface{} { return p.ID }),
+		"imageUrl":  field(gql.NewNonNull(gql.String), func(p *models.Post) interface{} { return p.ImageURL }),
+		"caption":   field(gql.String, func(p *models.Post) interface{} { return p.Caption }),
+		"createdAt": field(gql.NewNonNull(gql.DateTime), func(p *models.Post) interface{} { return p.CreatedAt }),
+		"author": {
+			Type: userType,
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadUser(p.Context, p.Source.(*models.Post).UserID)
+			},
+		},
+		"commentCount": {
+			Type: gql.NewNonNull(gql.Int),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				if err := requireScope(p.Context, models.ScopeCommentsRead); err != nil {
+					return nil, err
+				}
+				return loadCount(p.Context, loadersFromContext(p.Context).commentCounts, p.Source.(*models.Post).ID)
+			},
+		},
+		"likeCount": {
+			Type: gql.NewNonNull(gql.Int),
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadCount(p.Context, loadersFromContext(p.Context).likeCounts, p.Source.(*models.Post).ID)
+			},
+		},
+		"comments": {
+			Type:        listOf(commentType),
+			Description: "The first comments, oldest first",
+			Args: gql.FieldConfigArgument{
+				"first": {Type: gql.Int, DefaultValue: defaultCommentPreviews},
+			},
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				if err := requireScope(p.Context, models.ScopeCommentsRead); err != nil {
+					return nil, err
+				}
+
+				first := min(intArg(p.Args, "first", defaultCommentPreviews), maxCommentPreviews)
+				if first <= 0 {
+					return []*models.Comment{}, nil
+				}
+
+				comments := loadersFromContext(p.Context).firstComments(first)
+				postID := p.Source.(*models.Post).ID
+				comments.queue(postID)
+				return func() (interface{}, error) {
+					loaded, err := comments.get(p.Context, postID)
+					if err != nil {
+						return nil, internalError(p.Context, err)
+					}
+					return pointers(loaded), nil
+				}, nil
+			},
+		},
+	})
+
+	addFields(commentType, gql.Fields{
+		"id":        field(gql.NewNonNull(gql.Int), func(c *models.Comment) interface{} { return c.ID }),
+		"content":   field(gql.NewNonNull(gql.String), func(c *models.Comment) interface{} { return c.Content }),
+		"createdAt": field(gql.NewNonNull(gql.DateTime), func(c *models.Comment) interface{} { return c.CreatedAt }),
+		"author": {
+			Type: userType,
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadUser(p.Context, p.Source.(*models.Comment).UserID)
+			},
+		},
+		"post": {
+			Type: postType,
+			Resolve: func(p gql.ResolveParams) (interface{}, error) {
+				return loadPost(p.Context, p.Source.(*models.Comment).PostID)
+			},
+		},
+	})
+
+	return gql.NewObject(gql.ObjectConfig{
+		Name: "Query",
+		Fields: gql.Fields{
+			"viewer": {
+				Type:        userType,
+				Description: "The authenticated user",
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					userID, _ := middleware.GetUserIDFromContext(p.Context)
+					return loadUser(p.Context, userID)
+				},
+			},
+			"user": {
+				Type: userType,
+				Args: idArgs(),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					return loadUser(p.Context, p.Args["id"].(int))
+				},
+			},
+			"post": {
+				Type: postType,
+				Args: idArgs(),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					return loadPost(p.Context, p.Args["id"].(int))
+				},
+			},
+			"comment": {
+				Type: commentType,
+				Args: idArgs(),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					if err := requireScope(p.Context, models.ScopeCommentsRead); err != nil {
+						return nil, err
+					}
+
+					comment, err := loadersFromContext(p.Context).services.Comments.Get(p.Context, p.Args["id"].(int))
+					if errors.Is(err, repositories.ErrNotFound) {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, internalError(p.Context, err)
+					}
+					return comment, nil
+				},
+			},
+			"feed": {
+				Type:        listOf(postType),
+				Description: "The posts of the users the authenticated user follows, newest first",
+				Args:        pageArgs(),
+				Resolve: func(p gql.ResolveParams) (interface{}, error) {
+					if err := requireScope(p.Context, models.ScopePostsRead); err != nil {
+						return nil, err
+					}
+
+					userID, _ := middleware.GetUserIDFromContext(p.Context)
+					feed, err := loadersFromContext(p.Context).services.Posts.Feed(p.Context, userID, pageFromArgs(p.Args))
+					if err != nil {
+						return nil, internalError(p.Context, err)
+					}
+
+					posts := make([]*models.Post, len(feed))
+					for i := range feed {
+						posts[i] = &feed[i].Post
+					}
+					return posts, nil
+				},
+			},
+		},
+	})
+}
+
+// addFields adds fields to an object after it was created, for types that refer to each other
+func addFields(object *gql.Object, fields gql.Fields) {
+	for name, f := range fields {
+		object.AddFieldConfig(name, f)
+	}
+}
+
+// field returns a field resolved from its source object
+func field[T any](output gql.Output, get func(*T) interface{}) *gql.Field {
+	return &gql.Field{
+		Type: output,
+		Resolve: func(p gql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(*T)), nil
+		},
+	}
+}
+
+func listOf(object *gql.Object) gql.Output {
+	return gql.NewNonNull(gql.NewList(gql.NewNonNull(object)))
+}
+
+func idArgs() gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{"id": {Type: gql.NewNonNull(gql.Int)}}
+}
+
+func pageArgs() gql.FieldConfigArgument {
+	return gql.FieldConfigArgument{
+		"limit":  {Type: gql.Int, DefaultValue: defaultListLimit},
+		"offset": {Type: gql.Int, DefaultValue: 0},
+	}
+}
+
+func pageFromArgs(args map[string]interface{}) models.Page {
+	limit := min(intArg(args, "limit", defaultListLimit), maxListLimit)
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	return models.Page{Limit: limit, Offset: max(intArg(args, "offset", 0), 0)}
+}
+
+// intArg returns an optional Int argument, which is missing when it is passed as null
+func intArg(args map[string]interface{}, name string, defaultValue int) int {
+	value, ok := args[name].(int)
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+// loadUser returns a thunk resolving to a user, or to null when the user does not exist or is hidden
+// from the authenticated user
+func loadUser(ctx context.Context, userID int) (interface{}, error) {
+	if err := requireScope(ctx, models.ScopeUsersRead); err != nil {
+		return nil, err
+	}
+
+	users := loadersFromContext(ctx).users
+	users.queue(userID)
+	return func() (interface{}, error) {
+		user, err := users.get(ctx, userID)
+		if err != nil {
+			return nil, internalError(ctx, err)
+		}
+		// Deactivated profiles are hidden from everyone else
+		if user == nil || (user.DeactivatedAt != nil && !services.IsOwnerOrAdmin(ctx, user.ID)) {
+			return nil, nil
+		}
+		return user, nil
+	}, nil
+}
+
+// loadPost returns a thunk resolving to a post, or to null when it does not exist or is not visible
+func loadPost(ctx context.Context, postID int) (interface{}, error) {
+	if err := requireScope(ctx, models.ScopePostsRead); err != nil {
+		return nil, err
+	}
+
+	posts := loadersFromContext(ctx).posts
+	posts.queue(postID)
+	return func() (interface{}, error) {
+		post, err := posts.get(ctx, postID)
+		if err != nil {
+			return nil, internalError(ctx, err)
+		}
+		if post == nil {
+			return nil, nil
+		}
+		return post, nil
+	}, nil
+}
+
+func loadCount(ctx context.Context, counts *loader[int], id int) (interface{}, error) {
+	counts.queue(id)
+	return func() (interface{}, error) {
+		count, err := counts.get(ctx, id)
+		if err != nil {
+			return nil, internalError(ctx, err)
+		}
+		return count, nil
+	}, nil
+}
+
+// loadFollows resolves a page of followers or followed users. The page of IDs is read per user, the
+// users themselves are loaded in one batch.
+func loadFollows(p gql.ResolveParams, getIDs func(repositories.FollowStore, context.Context, int, models.Page) ([]int, error)) (interface{}, error) {
+	if err := requireScope(p.Context, models.ScopeUsersRead); err != nil {
+		return nil, err
+	}
+
+	l := loadersFromContext(p.Context)
+	ids, err := getIDs(l.stores.Follows, p.Context, p.Source.(*models.User).ID, pageFromArgs(p.Args))
+	if err != nil {
+		return nil, internalError(p.Context, err)
+	}
+
+	for _, id := range ids {
+		l.users.queue(id)
+	}
+	return func() (interface{}, error) {
+		users := make([]*models.User, 0, len(ids))
+		for _, id := range ids {
+			user, err := l.users.get(p.Context, id)
+			if err != nil {
+				return nil, internalError(p.Context, err)
+			}
+			if user != nil {
+				users = append(users, user)
+			}
+		}
+		return users, nil
+	}, nil
+}
+
+func pointers[T any](values []T) []*T {
+	result := make([]*T, len(values))
+	for i := range values {
+		result[i] = &values[i]
+	}
+	return result
+}
+
+// requireScope rejects fields that personal access tokens need a scope for, like middleware.RequireScope
+// does for routes
+func requireScope(ctx context.Context, scope string) error {
+	if scopes, ok := middleware.GetScopesFromContext(ctx); ok && !slices.Contains(scopes, scope) {
+		return errors.New("access token is missing the " + scope + " scope")
+	}
+	return nil
+}
+
+func internalError(ctx context.Context, err error) error {
+	logging.FromContext(ctx).Error("GraphQL resolver failed", "error", err)
+	return errInternal
+}
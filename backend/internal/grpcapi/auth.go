@@ -0,0 +1,168 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/logging"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// access is what a method requires besides an authenticated user, like the scope and session checks of
+// the HTTP routes
+type access struct {
+	// scope is the scope personal access tokens need
+	scope string
+	// session rejects personal access tokens
+	session bool
+}
+
+// methodAccess holds the access of every method. New panics if a registered method is missing.
+var methodAccess = map[string]access{
+	pb.UserService_CreateUser_FullMethodName:     {scope: models.ScopeUsersWrite},
+	pb.UserService_GetUser_FullMethodName:        {scope: models.ScopeUsersRead},
+	pb.UserService_UpdateUser_FullMethodName:     {scope: models.ScopeUsersWrite},
+	pb.UserService_DeleteUser_FullMethodName:     {scope: models.ScopeUsersWrite},
+	pb.UserService_DeactivateUser_FullMethodName: {session: true},
+
+	pb.PostService_CreatePost_FullMethodName:    {scope: models.ScopePostsWrite},
+	pb.PostService_GetPost_FullMethodName:       {scope: models.ScopePostsRead},
+	pb.PostService_DeletePost_FullMethodName:    {scope: models.ScopePostsWrite},
+	pb.PostService_RestorePost_FullMethodName:   {scope: models.ScopePostsWrite},
+	pb.PostService_ListUserPosts_FullMethodName: {scope: models.ScopePostsRead},
+
+	pb.CommentService_CreateComment_FullMethodName:    {scope: models.ScopeCommentsWrite},
+	pb.CommentService_GetComment_FullMethodName:       {scope: models.ScopeCommentsRead},
+	pb.CommentService_DeleteComment_FullMethodName:    {scope: models.ScopeCommentsWrite},
+	pb.CommentService_RestoreComment_FullMethodName:   {scope: models.ScopeCommentsWrite},
+	pb.CommentService_ListPostComments_FullMethodName: {scope: models.ScopeCommentsRead},
+
+	pb.FollowService_Follow_FullMethodName:   {scope: models.ScopeFollowsWrite},
+	pb.FollowService_Unfollow_FullMethodName: {scope: models.ScopeFollowsWrite},
+
+	pb.FeedService_GetFeed_FullMethodName:   {scope: models.ScopePostsRead},
+	pb.FeedService_WatchFeed_FullMethodName: {scope: models.ScopePostsRead},
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate verifies the bearer token in the authorization metadata and checks the access of the
+// method, like middleware.JWTMiddleware with RequireScope or RequireSession does for the HTTP routes
+func (s *Server) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	required, ok := methodAccess[fullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method has no access policy")
+	}
+
+	values := metadata.ValueFromIncomingContext(ctx, "authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata is required")
+	}
+	tokenString, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok || tokenString == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata format")
+	}
+
+	authenticated, err := middleware.Authenticate(ctx, s.db, s.cfg.Auth.JWTSecret, tokenString)
+	var authErr *middleware.AuthError
+	switch {
+	case errors.As(err, &authErr):
+		code := codes.Unauthenticated
+		if authErr.Status == http.StatusForbidden {
+			code = codes.PermissionDenied
+		}
+		return nil, status.Error(code, authErr.Message)
+	case err != nil:
+		return nil, statusError(ctx, err)
+	}
+	ctx = authenticated
+
+	if required.session {
+		if _, ok := middleware.GetSessionIDFromContext(ctx); !ok {
+			return nil, status.Error(codes.PermissionDenied, "this method requires a logged in session")
+		}
+	}
+	if scopes, ok := middleware.GetScopesFromContext(ctx); ok && !slices.Contains(scopes, required.scope) {
+		return nil, status.Error(codes.PermissionDenied, "access token is missing the "+required.scope+" scope")
+	}
+	return ctx, nil
+}
+
+func (s *Server) logUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx, requestID := withRequestID(ctx, info.FullMethod)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, requestID))
+
+	resp, err := handler(ctx, req)
+	logCall(ctx, start, err)
+	return resp, err
+}
+
+func (s *Server) logStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx, requestID := withRequestID(stream.Context(), info.FullMethod)
+	_ = stream.SetHeader(metadata.Pairs(requestIDKey, requestID))
+
+	err := handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	logCall(ctx, start, err)
+	return err
+}
+
+// requestIDKey is the metadata key of the request ID, like middleware.RequestIDHeader for HTTP requests
+const requestIDKey = "x-request-id"
+
+// withRequestID gives a call the request ID sent by the client, or a new one, and a logger with the
+// method and the request ID
+func withRequestID(ctx context.Context, fullMethod string) (context.Context, string) {
+	values := metadata.ValueFromIncomingContext(ctx, requestIDKey)
+	sent := ""
+	if len(values) > 0 {
+		sent = values[0]
+	}
+	requestID := middleware.RequestID(sent)
+
+	ctx = middleware.WithRequestID(ctx, requestID)
+	return logging.With(ctx, "grpc_method", fullMethod, "request_id", requestID), requestID
+}
+
+// logCall writes the access log entry of a call
+func logCall(ctx context.Context, start time.Time, err error) {
+	logging.FromContext(ctx).Info("gRPC call",
+		"code", status.Code(err).String(),
+		"latency_ms", float64(time.Since(start).Microseconds())/1000,
+	)
+}
+
+// contextStream is a server stream with the context of the interceptors
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,69 @@
+package grpcapi
+
+import (
+	"context"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/models"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type commentService struct {
+	pb.UnimplementedCommentServiceServer
+	*Server
+}
+
+func (s *commentService) CreateComment(ctx context.Context, req *pb.CreateCommentRequest) (*emptypb.Empty, error) {
+	comment := models.Comment{
+		PostID:  int(req.GetPostId()),
+		UserID:  int(req.GetUserId()),
+		Content: req.GetContent(),
+	}
+	err := s.services.Comments.Create(ctx, &comment)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *commentService) GetComment(ctx context.Context, req *pb.GetCommentRequest) (*pb.Comment, error) {
+	comment, err := s.services.Comments.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return commentToProto(comment), nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, req *pb.DeleteCommentRequest) (*emptypb.Empty, error) {
+	commentID := int(req.GetId())
+	err := s.services.Comments.Delete(ctx, commentID)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	s.recordAudit(ctx, models.AuditCommentDeleted, models.TargetComment, commentID, "")
+	return &emptypb.Empty{}, nil
+}
+
+// RestoreComment restores one of the user's deleted comments before it is purged
+func (s *commentService) RestoreComment(ctx context.Context, req *pb.RestoreCommentRequest) (*emptypb.Empty, error) {
+	return s.restoreContent(ctx, models.TargetComment, int(req.GetId()), s.services.Comments.Restore, models.AuditCommentRestored)
+}
+
+func (s *commentService) ListPostComments(ctx context.Context, req *pb.ListPostCommentsRequest) (*pb.ListCommentsResponse, error) {
+	page, err := page(req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := s.services.Comments.ListForPost(ctx, int(req.GetPostId()), page)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	resp := &pb.ListCommentsResponse{Comments: make([]*pb.Comment, 0, len(comments))}
+	for i := range comments {
+		resp.Comments = append(resp.Comments, commentToProto(&comments[i]))
+	}
+	return resp, nil
+}
@@ -0,0 +1,164 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/logging"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"instagram/internal/validation"
+	"net"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// statusError converts an error to a gRPC status like problem.Error does to a response: invalid fields
+// are listed in a BadRequest detail, domain errors keep their message and other errors are logged and
+// reported without details
+func statusError(ctx context.Context, err error) error {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		badRequest := &errdetails.BadRequest{}
+		for _, fieldErr := range fieldErrs {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       fieldErr.Field,
+				Description: fieldErr.Message,
+			})
+		}
+		st, detailsErr := status.New(codes.InvalidArgument, "The request has invalid fields").WithDetails(badRequest)
+		if detailsErr != nil {
+			return status.Error(codes.InvalidArgument, fieldErrs.Error())
+		}
+		return st.Err()
+	}
+
+	var domainErr *repositories.Error
+	if errors.As(err, &domainErr) {
+		code := codes.Internal
+		switch {
+		case errors.Is(err, repositories.ErrNotFound):
+			code = codes.NotFound
+		case errors.Is(err, repositories.ErrConflict):
+			code = codes.AlreadyExists
+		case errors.Is(err, repositories.ErrValidation), errors.Is(err, repositories.ErrInvalid):
+			code = codes.InvalidArgument
+		case errors.Is(err, repositories.ErrForbidden):
+			code = codes.PermissionDenied
+		}
+		return status.Error(code, domainErr.Message)
+	}
+
+	logging.FromContext(ctx).Error("gRPC call failed", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// userToProto converts a user. The email is only included for the user themselves and admins.
+func userToProto(ctx context.Context, user *models.User) *pb.User {
+	converted := &pb.User{
+		Id:            int64(user.ID),
+		Username:      user.Username,
+		Bio:           user.Bio,
+		ProfileImage:  user.ProfileImage,
+		Role:          user.Role,
+		CreatedAt:     timestamp(user.CreatedAt),
+		DeactivatedAt: optionalTimestamp(user.DeactivatedAt),
+	}
+	if services.IsOwnerOrAdmin(ctx, user.ID) {
+		converted.Email = user.Email
+	}
+	return converted
+}
+
+func postToProto(post *models.Post) *pb.Post {
+	return &pb.Post{
+		Id:        int64(post.ID),
+		UserId:    int64(post.UserID),
+		ImageUrl:  post.ImageURL,
+		Caption:   post.Caption,
+		CreatedAt: timestamp(post.CreatedAt),
+	}
+}
+
+func commentToProto(comment *models.Comment) *pb.Comment {
+	return &pb.Comment{
+		Id:        int64(comment.ID),
+		PostId:    int64(comment.PostID),
+		UserId:    int64(comment.UserID),
+		Content:   comment.Content,
+		CreatedAt: timestamp(comment.CreatedAt),
+	}
+}
+
+func feedPostToProto(ctx context.Context, feedPost *models.FeedPost) *pb.FeedPost {
+	return &pb.FeedPost{
+		Post:   postToProto(&feedPost.Post),
+		Author: userToProto(ctx, &feedPost.User),
+	}
+}
+
+// timestamp converts a time, leaving zero times unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
+
+// maxListLimit caps the limit of list requests, like it does for the HTTP list endpoints
+const maxListLimit = 500
+
+// page converts the limit and offset of a list request. Without a limit the rest of the list is returned.
+func page(limit, offset int32) (models.Page, error) {
+	if limit < 0 {
+		return models.Page{}, status.Error(codes.InvalidArgument, "Invalid limit")
+	}
+	if offset < 0 {
+		return models.Page{}, status.Error(codes.InvalidArgument, "Invalid offset")
+	}
+	return models.Page{Limit: min(int(limit), maxListLimit), Offset: int(offset)}, nil
+}
+
+// recordAudit appends an action the authenticated user took on a target to the audit log, with the
+// address of the peer and the request ID. Like for the HTTP handlers a failure is logged but does not fail the call.
+func (s *Server) recordAudit(ctx context.Context, action, targetType string, targetID int, details string) {
+	var actorID *int
+	if userID, ok := middleware.GetUserIDFromContext(ctx); ok {
+		actorID = &userID
+	}
+	requestID, _ := middleware.GetRequestIDFromContext(ctx)
+	var ipAddress string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ipAddress = p.Addr.String()
+		if host, _, err := net.SplitHostPort(ipAddress); err == nil {
+			ipAddress = host
+		}
+	}
+
+	err := repositories.AddAuditEvent(ctx, s.db, &models.AuditEvent{
+		Action:     action,
+		ActorID:    actorID,
+		TargetType: targetType,
+		TargetID:   &targetID,
+		IPAddress:  ipAddress,
+		RequestID:  requestID,
+		Details:    details,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record audit event", "action", action, "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package grpcapi
+
+import (
+	"context"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/middleware"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// watchBatchSize is the most posts WatchFeed reads per check, the rest are sent on the next one
+const watchBatchSize = 100
+
+type feedService struct {
+	pb.UnimplementedFeedServiceServer
+	*Server
+}
+
+func (s *feedService) GetFeed(ctx context.Context, req *pb.GetFeedRequest) (*pb.GetFeedResponse, error) {
+	page, err := page(req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	feed, err := s.services.Posts.Feed(ctx, int(req.GetUserId()), page)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	resp := &pb.GetFeedResponse{Posts: make([]*pb.FeedPost, 0, len(feed))}
+	for i := range feed {
+		resp.Posts = append(resp.Posts, feedPostToProto(ctx, &feed[i]))
+	}
+	return resp, nil
+}
+
+// WatchFeed checks for new posts in the feed every WatchInterval and sends them until the call is
+// cancelled or the server shuts down
+func (s *feedService) WatchFeed(req *pb.WatchFeedRequest, stream grpc.ServerStreamingServer[pb.FeedPost]) error {
+	ctx := stream.Context()
+
+	userID := int(req.GetUserId())
+	if userID == 0 {
+		userID, _ = middleware.GetUserIDFromContext(ctx)
+	}
+
+	afterID := int(req.GetAfterPostId())
+	if afterID == 0 {
+		latestID, err := s.services.Posts.LatestID(ctx)
+		if err != nil {
+			return statusError(ctx, err)
+		}
+		afterID = latestID
+	}
+
+	ticker := time.NewTicker(s.WatchInterval)
+	defer ticker.Stop()
+	for watching := false; ; watching = true {
+		posts, err := s.services.Posts.FeedAfter(ctx, userID, afterID, watchBatchSize)
+		if err != nil {
+			return statusError(ctx, err)
+		}
+		// Once the first check passed, sending the headers tells the client that posts published from now
+		// on will be sent
+		if !watching {
+			if err := stream.SendHeader(metadata.MD{}); err != nil {
+				return err
+			}
+		}
+		for i := range posts {
+			if err := stream.Send(feedPostToProto(ctx, &posts[i])); err != nil {
+				return err
+			}
+			afterID = posts[i].Post.ID
+		}
+
+		// A full batch may have more posts behind it
+		if len(posts) == watchBatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.shutdown:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
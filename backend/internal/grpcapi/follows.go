@@ -0,0 +1,38 @@
+package grpcapi
+
+import (
+	"context"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/metrics"
+	"instagram/internal/models"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type followService struct {
+	pb.UnimplementedFollowServiceServer
+	*Server
+}
+
+func (s *followService) Follow(ctx context.Context, req *pb.FollowRequest) (*emptypb.Empty, error) {
+	err := s.services.Follows.Follow(ctx, followFromProto(req), metrics.SourceAPI)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *followService) Unfollow(ctx context.Context, req *pb.FollowRequest) (*emptypb.Empty, error) {
+	err := s.services.Follows.Unfollow(ctx, followFromProto(req))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func followFromProto(req *pb.FollowRequest) *models.Follow {
+	return &models.Follow{
+		FollowerID:  int(req.GetFollowerId()),
+		FollowingID: int(req.GetFollowingId()),
+	}
+}
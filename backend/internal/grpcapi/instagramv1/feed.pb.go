@@ -0,0 +1,344 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        (unknown)
+// source: instagram/v1/feed.proto
+
+package instagramv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type FeedPost struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Post   *Post `protobuf:"bytes,1,opt,name=post,proto3" json:"post,omitempty"`
+	Author *User `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+}
+
+func (x *FeedPost) Reset() {
+	*x = FeedPost{}
+	mi := &file_instagram_v1_feed_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FeedPost) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FeedPost) ProtoMessage() {}
+
+func (x *FeedPost) ProtoReflect() protoreflect.Message {
+	mi := &file_instagram_v1_feed_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FeedPost.ProtoReflect.Descriptor instead.
+func (*FeedPost) Descriptor() ([]byte, []int) {
+	return file_instagram_v1_feed_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *FeedPost) GetPost() *Post {
+	if x != nil {
+		return x.Post
+	}
+	return nil
+}
+
+func (x *FeedPost) GetAuthor() *User {
+	if x != nil {
+		return x.Author
+	}
+	return nil
+}
+
+// GetFeedRequest selects a page of the feed. A limit of 0 returns every post from the offset on.
+type GetFeedRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	UserId int64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Limit  int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset int32 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+}
+
+func (x *GetFeedRequest) Reset() {
+	*x = GetFeedRequest{}
+	mi := &file_instagram_v1_feed_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFeedRequest) ProtoMessage() {}
+
+func (x *GetFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_instagram_v1_feed_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFeedRequest.ProtoReflect.Descriptor instead.
+func (*GetFeedRequest) Descriptor() ([]byte, []int) {
+	return file_instagram_v1_feed_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetFeedRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *GetFeedRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetFeedRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type GetFeedResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Posts []*FeedPost `protobuf:"bytes,1,rep,name=posts,proto3" json:"posts,omitempty"`
+}
+
+func (x *GetFeedResponse) Reset() {
+	*x = GetFeedResponse{}
+	mi := &file_instagram_v1_feed_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetFeedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFeedResponse) ProtoMessage() {}
+
+func (x *GetFeedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_instagram_v1_feed_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFeedResponse.ProtoReflect.Descriptor instead.
+func (*GetFeedResponse) Descriptor() ([]byte, []int) {
+	return file_instagram_v1_feed_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetFeedResponse) GetPosts() []*FeedPost {
+	if x != nil {
+		return x.Posts
+	}
+	return nil
+}
+
+type WatchFeedRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// user_id is the user whose feed is watched, the authenticated user when it is 0
+	UserId      int64 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	AfterPostId int64 `protobuf:"varint,2,opt,name=after_post_id,json=afterPostId,proto3" json:"after_post_id,omitempty"`
+}
+
+func (x *WatchFeedRequest) Reset() {
+	*x = WatchFeedRequest{}
+	mi := &file_instagram_v1_feed_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchFeedRequest) ProtoMessage() {}
+
+func (x *WatchFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_instagram_v1_feed_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchFeedRequest.ProtoReflect.Descriptor instead.
+func (*WatchFeedRequest) Descriptor() ([]byte, []int) {
+	return file_instagram_v1_feed_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *WatchFeedRequest) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *WatchFeedRequest) GetAfterPostId() int64 {
+	if x != nil {
+		return x.AfterPostId
+	}
+	return 0
+}
+
+var File_instagram_v1_feed_proto protoreflect.FileDescriptor
+
+var file_instagram_v1_feed_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2f, 0x76, 0x31, 0x2f, 0x66,
+	0x65, 0x65, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c, 0x69, 0x6e, 0x73, 0x74, 0x61,
+	0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x1a, 0x18, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72,
+	0x61, 0x6d, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x6f, 0x73, 0x74, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x18, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2f, 0x76, 0x31, 0x2f,
+	0x75, 0x73, 0x65, 0x72, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5e, 0x0a, 0x08, 0x46,
+	0x65, 0x65, 0x64, 0x50, 0x6f, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x04, 0x70, 0x6f, 0x73, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61,
+	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x6f, 0x73, 0x74, 0x52, 0x04, 0x70, 0x6f, 0x73, 0x74, 0x12,
+	0x2a, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x12, 0x2e, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x55,
+	0x73, 0x65, 0x72, 0x52, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x22, 0x57, 0x0a, 0x0e, 0x47,
+	0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a,
+	0x07, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06,
+	0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06,
+	0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6f, 0x66,
+	0x66, 0x73, 0x65, 0x74, 0x22, 0x3f, 0x0a, 0x0f, 0x47, 0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x05, 0x70, 0x6f, 0x73, 0x74, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72,
+	0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x65, 0x65, 0x64, 0x50, 0x6f, 0x73, 0x74, 0x52, 0x05,
+	0x70, 0x6f, 0x73, 0x74, 0x73, 0x22, 0x4f, 0x0a, 0x10, 0x57, 0x61, 0x74, 0x63, 0x68, 0x46, 0x65,
+	0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65,
+	0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x22, 0x0a, 0x0d, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x70, 0x6f, 0x73, 0x74,
+	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x61, 0x66, 0x74, 0x65, 0x72,
+	0x50, 0x6f, 0x73, 0x74, 0x49, 0x64, 0x32, 0x9c, 0x01, 0x0a, 0x0b, 0x46, 0x65, 0x65, 0x64, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x46, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x46, 0x65, 0x65,
+	0x64, 0x12, 0x1c, 0x2e, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31,
+	0x2e, 0x47, 0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1d, 0x2e, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x45,
+	0x0a, 0x09, 0x57, 0x61, 0x74, 0x63, 0x68, 0x46, 0x65, 0x65, 0x64, 0x12, 0x1e, 0x2e, 0x69, 0x6e,
+	0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68,
+	0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x69, 0x6e,
+	0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x46, 0x65, 0x65, 0x64, 0x50,
+	0x6f, 0x73, 0x74, 0x30, 0x01, 0x42, 0x34, 0x5a, 0x32, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72,
+	0x61, 0x6d, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x72, 0x70, 0x63,
+	0x61, 0x70, 0x69, 0x2f, 0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x76, 0x31, 0x3b,
+	0x69, 0x6e, 0x73, 0x74, 0x61, 0x67, 0x72, 0x61, 0x6d, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_instagram_v1_feed_proto_rawDescOnce sync.Once
+	file_instagram_v1_feed_proto_rawDescData = file_instagram_v1_feed_proto_rawDesc
+)
+
+func file_instagram_v1_feed_proto_rawDescGZIP() []byte {
+	file_instagram_v1_feed_proto_rawDescOnce.Do(func() {
+		file_instagram_v1_feed_proto_rawDescData = protoimpl.X.CompressGZIP(file_instagram_v1_feed_proto_rawDescData)
+	})
+	return file_instagram_v1_feed_proto_rawDescData
+}
+
+var file_instagram_v1_feed_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_instagram_v1_feed_proto_goTypes = []any{
+	(*FeedPost)(nil),         // 0: instagram.v1.FeedPost
+	(*GetFeedRequest)(nil),   // 1: instagram.v1.GetFeedRequest
+	(*GetFeedResponse)(nil),  // 2: instagram.v1.GetFeedResponse
+	(*WatchFeedRequest)(nil), // 3: instagram.v1.WatchFeedRequest
+	(*Post)(nil),             // 4: instagram.v1.Post
+	(*User)(nil),             // 5: instagram.v1.User
+}
+var file_instagram_v1_feed_proto_depIdxs = []int32{
+	4, // 0: instagram.v1.FeedPost.post:type_name -> instagram.v1.Post
+	5, // 1: instagram.v1.FeedPost.author:type_name -> instagram.v1.User
+	0, // 2: instagram.v1.GetFeedResponse.posts:type_name -> instagram.v1.FeedPost
+	1, // 3: instagram.v1.FeedService.GetFeed:input_type -> instagram.v1.GetFeedRequest
+	3, // 4: instagram.v1.FeedService.WatchFeed:input_type -> instagram.v1.WatchFeedRequest
+	2, // 5: instagram.v1.FeedService.GetFeed:output_type -> instagram.v1.GetFeedResponse
+	0, // 6: instagram.v1.FeedService.WatchFeed:output_type -> instagram.v1.FeedPost
+	5, // [5:7] is the sub-list for method output_type
+	3, // [3:5] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_instagram_v1_feed_proto_init() }
+func file_instagram_v1_feed_proto_init() {
+	if File_instagram_v1_feed_proto != nil {
+		return
+	}
+	file_instagram_v1_posts_proto_init()
+	file_instagram_v1_users_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_instagram_v1_feed_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_instagram_v1_feed_proto_goTypes,
+		DependencyIndexes: file_instagram_v1_feed_proto_depIdxs,
+		MessageInfos:      file_instagram_v1_feed_proto_msgTypes,
+	}.Build()
+	File_instagram_v1_feed_proto = out.File
+	file_instagram_v1_feed_proto_rawDesc = nil
+	file_instagram_v1_feed_proto_goTypes = nil
+	file_instagram_v1_feed_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: instagram/v1/feed.proto
+
+package instagramv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FeedService_GetFeed_FullMethodName   = "/instagram.v1.FeedService/GetFeed"
+	FeedService_WatchFeed_FullMethodName = "/instagram.v1.FeedService/WatchFeed"
+)
+
+// FeedServiceClient is the client API for FeedService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FeedService reads the posts of the users a user follows
+type FeedServiceClient interface {
+	// GetFeed returns a page of the feed of a user, newest first
+	GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*GetFeedResponse, error)
+	// WatchFeed streams the posts published to the feed of a user, oldest first, until the call is
+	// cancelled. Posts up to after_post_id are skipped; without it only posts published after the call
+	// started are sent.
+	WatchFeed(ctx context.Context, in *WatchFeedRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FeedPost], error)
+}
+
+type feedServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFeedServiceClient(cc grpc.ClientConnInterface) FeedServiceClient {
+	return &feedServiceClient{cc}
+}
+
+func (c *feedServiceClient) GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*GetFeedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetFeedResponse)
+	err := c.cc.Invoke(ctx, FeedService_GetFeed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *feedServiceClient) WatchFeed(ctx context.Context, in *WatchFeedRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FeedPost], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FeedService_ServiceDesc.Streams[0], FeedService_WatchFeed_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchFeedRequest, FeedPost]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FeedService_WatchFeedClient = grpc.ServerStreamingClient[FeedPost]
+
+// FeedServiceServer is the server API for FeedService service.
+// All implementations must embed UnimplementedFeedServiceServer
+// for forward compatibility.
+//
+// FeedService reads the posts of the users a user follows
+type FeedServiceServer interface {
+	// GetFeed returns a page of the feed of a user, newest first
+	GetFeed(context.Context, *GetFeedRequest) (*GetFeedResponse, error)
+	// WatchFeed streams the posts published to the feed of a user, oldest first, until the call is
+	// cancelled. Posts up to after_post_id are skipped; without it only posts published after the call
+	// started are sent.
+	WatchFeed(*WatchFeedRequest, grpc.ServerStreamingServer[FeedPost]) error
+	mustEmbedUnimplementedFeedServiceServer()
+}
+
+// UnimplementedFeedServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFeedServiceServer struct{}
+
+func (UnimplementedFeedServiceServer) GetFeed(context.Context, *GetFeedRequest) (*GetFeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFeed not implemented")
+}
+func (UnimplementedFeedServiceServer) WatchFeed(*WatchFeedRequest, grpc.ServerStreamingServer[FeedPost]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchFeed not implemented")
+}
+func (UnimplementedFeedServiceServer) mustEmbedUnimplementedFeedServiceServer() {}
+func (UnimplementedFeedServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeFeedServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FeedServiceServer will
+// result in compilation errors.
+type UnsafeFeedServiceServer interface {
+	mustEmbedUnimplementedFeedServiceServer()
+}
+
+func RegisterFeedServiceServer(s grpc.ServiceRegistrar, srv FeedServiceServer) {
+	// If the following call pancis, it indicates UnimplementedFeedServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FeedService_ServiceDesc, srv)
+}
+
+func _FeedService_GetFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FeedServiceServer).GetFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FeedService_GetFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FeedServiceServer).GetFeed(ctx, req.(*GetFeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FeedService_WatchFeed_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchFeedRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FeedServiceServer).WatchFeed(m, &grpc.GenericServerStream[WatchFeedRequest, FeedPost]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FeedService_WatchFeedServer = grpc.ServerStreamingServer[FeedPost]
+
+// FeedService_ServiceDesc is the grpc.ServiceDesc for FeedService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FeedService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "instagram.v1.FeedService",
+	HandlerType: (*FeedServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetFeed",
+			Handler:    _FeedService_GetFeed_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchFeed",
+			Handler:       _FeedService_WatchFeed_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "instagram/v1/feed.proto",
+}
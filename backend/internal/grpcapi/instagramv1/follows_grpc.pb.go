@@ -0,0 +1,168 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: instagram/v1/follows.proto
+
+package instagramv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FollowService_Follow_FullMethodName   = "/instagram.v1.FollowService/Follow"
+	FollowService_Unfollow_FullMethodName = "/instagram.v1.FollowService/Unfollow"
+)
+
+// FollowServiceClient is the client API for FollowService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FollowService follows and unfollows users, like the /follow/ routes
+type FollowServiceClient interface {
+	// Follow makes follower_id follow following_id
+	Follow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Unfollow ends a follow
+	Unfollow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type followServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFollowServiceClient(cc grpc.ClientConnInterface) FollowServiceClient {
+	return &followServiceClient{cc}
+}
+
+func (c *followServiceClient) Follow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, FollowService_Follow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *followServiceClient) Unfollow(ctx context.Context, in *FollowRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, FollowService_Unfollow_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FollowServiceServer is the server API for FollowService service.
+// All implementations must embed UnimplementedFollowServiceServer
+// for forward compatibility.
+//
+// FollowService follows and unfollows users, like the /follow/ routes
+type FollowServiceServer interface {
+	// Follow makes follower_id follow following_id
+	Follow(context.Context, *FollowRequest) (*emptypb.Empty, error)
+	// Unfollow ends a follow
+	Unfollow(context.Context, *FollowRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedFollowServiceServer()
+}
+
+// UnimplementedFollowServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFollowServiceServer struct{}
+
+func (UnimplementedFollowServiceServer) Follow(context.Context, *FollowRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Follow not implemented")
+}
+func (UnimplementedFollowServiceServer) Unfollow(context.Context, *FollowRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Unfollow not implemented")
+}
+func (UnimplementedFollowServiceServer) mustEmbedUnimplementedFollowServiceServer() {}
+func (UnimplementedFollowServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeFollowServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FollowServiceServer will
+// result in compilation errors.
+type UnsafeFollowServiceServer interface {
+	mustEmbedUnimplementedFollowServiceServer()
+}
+
+func RegisterFollowServiceServer(s grpc.ServiceRegistrar, srv FollowServiceServer) {
+	// If the following call pancis, it indicates UnimplementedFollowServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FollowService_ServiceDesc, srv)
+}
+
+func _FollowService_Follow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FollowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FollowServiceServer).Follow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FollowService_Follow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FollowServiceServer).Follow(ctx, req.(*FollowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FollowService_Unfollow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FollowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FollowServiceServer).Unfollow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FollowService_Unfollow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FollowServiceServer).Unfollow(ctx, req.(*FollowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FollowService_ServiceDesc is the grpc.ServiceDesc for FollowService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FollowService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "instagram.v1.FollowService",
+	HandlerType: (*FollowServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Follow",
+			Handler:    _FollowService_Follow_Handler,
+		},
+		{
+			MethodName: "Unfollow",
+			Handler:    _FollowService_Unfollow_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "instagram/v1/follows.proto",
+}
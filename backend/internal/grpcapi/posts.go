@@ -0,0 +1,80 @@
+package grpcapi
+
+import (
+	"context"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/models"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type postService struct {
+	pb.UnimplementedPostServiceServer
+	*Server
+}
+
+func (s *postService) CreatePost(ctx context.Context, req *pb.CreatePostRequest) (*emptypb.Empty, error) {
+	post := models.Post{
+		UserID:   int(req.GetUserId()),
+		ImageURL: req.GetImageUrl(),
+		Caption:  req.GetCaption(),
+	}
+	err := s.services.Posts.Create(ctx, &post)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *postService) GetPost(ctx context.Context, req *pb.GetPostRequest) (*pb.Post, error) {
+	post, err := s.services.Posts.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return postToProto(post), nil
+}
+
+func (s *postService) DeletePost(ctx context.Context, req *pb.DeletePostRequest) (*emptypb.Empty, error) {
+	postID := int(req.GetId())
+	err := s.services.Posts.Delete(ctx, postID)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	s.recordAudit(ctx, models.AuditPostDeleted, models.TargetPost, postID, "")
+	return &emptypb.Empty{}, nil
+}
+
+// RestorePost restores one of the user's deleted posts before it is purged
+func (s *postService) RestorePost(ctx context.Context, req *pb.RestorePostRequest) (*emptypb.Empty, error) {
+	return s.restoreContent(ctx, models.TargetPost, int(req.GetId()), s.services.Posts.Restore, models.AuditPostRestored)
+}
+
+func (s *postService) ListUserPosts(ctx context.Context, req *pb.ListUserPostsRequest) (*pb.ListPostsResponse, error) {
+	page, err := page(req.GetLimit(), req.GetOffset())
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := s.services.Posts.ListForUser(ctx, int(req.GetUserId()), page)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	resp := &pb.ListPostsResponse{Posts: make([]*pb.Post, 0, len(posts))}
+	for i := range posts {
+		resp.Posts = append(resp.Posts, postToProto(&posts[i]))
+	}
+	return resp, nil
+}
+
+// restoreContent restores a deleted post or comment on behalf of its owner or an admin
+func (s *Server) restoreContent(ctx context.Context, targetType string, id int, restore func(context.Context, int) error, auditAction string) (*emptypb.Empty, error) {
+	err := restore(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	s.recordAudit(ctx, auditAction, targetType, id, "")
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,115 @@
+// Package grpcapi serves the API over gRPC for internal services, such as recommendations and moderation.
+// The services apply the same business rules as the HTTP handlers, those of the services package, and
+// accept the same session JWTs and personal access tokens. The protobuf definitions are in proto/instagram/v1.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=instagram --go-grpc_out=../.. --go-grpc_opt=module=instagram instagram/v1/users.proto instagram/v1/posts.proto instagram/v1/comments.proto instagram/v1/follows.proto instagram/v1/feed.proto
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/config"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/services"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// DefaultWatchInterval is how often WatchFeed checks for new posts
+const DefaultWatchInterval = 2 * time.Second
+
+// Server serves the gRPC services. Shutting it down ends the WatchFeed streams, then waits for the
+// other calls to finish.
+type Server struct {
+	// WatchInterval is how often WatchFeed checks for new posts
+	WatchInterval time.Duration
+
+	cfg      *config.Config
+	db       *sql.DB
+	services *services.Services
+	grpc     *grpc.Server
+	shutdown chan struct{}
+}
+
+// New returns a server whose users, posts, comments and follows services apply the rules of svc, the
+// same services as those of the HTTP handlers
+func New(cfg *config.Config, db *sql.DB, svc *services.Services) *Server {
+	s := &Server{
+		WatchInterval: DefaultWatchInterval,
+		cfg:           cfg,
+		db:            db,
+		services:      svc,
+		shutdown:      make(chan struct{}),
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.logUnary, s.authenticateUnary),
+		grpc.ChainStreamInterceptor(s.logStream, s.authenticateStream),
+	)
+	pb.RegisterUserServiceServer(s.grpc, &userService{Server: s})
+	pb.RegisterPostServiceServer(s.grpc, &postService{Server: s})
+	pb.RegisterCommentServiceServer(s.grpc, &commentService{Server: s})
+	pb.RegisterFollowServiceServer(s.grpc, &followService{Server: s})
+	pb.RegisterFeedServiceServer(s.grpc, &feedService{Server: s})
+
+	for name, service := range s.grpc.GetServiceInfo() {
+		for _, method := range service.Methods {
+			if _, ok := methodAccess["/"+name+"/"+method.Name]; !ok {
+				panic("gRPC method " + name + "/" + method.Name + " has no access policy")
+			}
+		}
+	}
+	return s
+}
+
+// Run listens on the configured gRPC address and serves until ctx is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.cfg.Server.GRPCAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.cfg.Server.GRPCAddr, err)
+	}
+	slog.Info("gRPC server is running", "addr", listener.Addr().String())
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on the listener until ctx is cancelled. In-flight calls are then given the shutdown
+// timeout to finish before they are cancelled.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.grpc.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("gRPC server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Streams would otherwise keep the graceful stop waiting until the timeout
+	close(s.shutdown)
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(s.cfg.Server.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		s.grpc.Stop()
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
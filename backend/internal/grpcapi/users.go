@@ -0,0 +1,78 @@
+package grpcapi
+
+import (
+	"context"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/models"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type userService struct {
+	pb.UnimplementedUserServiceServer
+	*Server
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.User, error) {
+	user := models.User{
+		Auth:         models.Auth{Username: req.GetUsername(), Email: req.GetEmail()},
+		Password:     req.GetPassword(),
+		Bio:          req.GetBio(),
+		ProfileImage: req.GetProfileImage(),
+	}
+	savedUser, err := s.services.Users.Create(ctx, &user)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	return userToProto(ctx, savedUser), nil
+}
+
+func (s *userService) GetUser(ctx context.Context, req *pb.GetUserRequest) (*pb.User, error) {
+	user, err := s.services.Users.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return userToProto(ctx, user), nil
+}
+
+func (s *userService) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.User, error) {
+	user := models.User{
+		Auth:         models.Auth{ID: int(req.GetId()), Username: req.GetUsername(), Email: req.GetEmail()},
+		Bio:          req.GetBio(),
+		ProfileImage: req.GetProfileImage(),
+	}
+	updatedUser, previousUser, err := s.services.Users.Update(ctx, &user)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	if updatedUser.Email != previousUser.Email {
+		s.recordAudit(ctx, models.AuditEmailChanged, models.TargetUser, user.ID,
+			"from "+previousUser.Email+" to "+updatedUser.Email)
+	}
+	return userToProto(ctx, updatedUser), nil
+}
+
+func (s *userService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*emptypb.Empty, error) {
+	id := int(req.GetId())
+	err := s.services.Users.Delete(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	s.recordAudit(ctx, models.AuditUserDeleted, models.TargetUser, id, "")
+	return &emptypb.Empty{}, nil
+}
+
+// DeactivateUser hides the authenticated user's profile and content and logs them out everywhere
+func (s *userService) DeactivateUser(ctx context.Context, req *pb.DeactivateUserRequest) (*emptypb.Empty, error) {
+	id := int(req.GetId())
+	err := s.services.Users.Deactivate(ctx, id)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	s.recordAudit(ctx, models.AuditUserDeactivated, models.TargetUser, id, "")
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"encoding/json"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of entries returned by the admin list endpoints when no limit is given, and the most they return
+const (
+	defaultModerationActionsLimit = 50
+	defaultReportQueueLimit       = 50
+	maxListLimit                  = 500
+)
+
+// HandleSuspendUser suspends a user, optionally for a limited number of hours
+func (a *App) HandleSuspendUser(w http.ResponseWriter, r *http.Request) {
+	moderatorID, target, request, ok := a.moderateUser(w, r)
+	if !ok {
+		return
+	}
+
+	if request.DurationHours < 0 {
+		problem.Write(w, r, http.StatusBadRequest, "duration_hours must not be negative")
+		return
+	}
+
+	var until *time.Time
+	if request.DurationHours > 0 {
+		suspendedUntil := time.Now().UTC().Add(time.Duration(request.DurationHours) * time.Hour)
+		until = &suspendedUntil
+	}
+
+	err := repositories.SuspendUser(r.Context(), a.DB, target.ID, until)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionSuspendUser, models.TargetUser, target.ID, request.Reason)
+}
+
+func (a *App) HandleUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	moderatorID, target, request, ok := a.moderateUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := repositories.UnsuspendUser(r.Context(), a.DB, target.ID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionUnsuspendUser, models.TargetUser, target.ID, request.Reason)
+}
+
+// HandlePutUserRole changes a user's role. Nobody can grant a role above their own.
+func (a *App) HandlePutUserRole(w http.ResponseWriter, r *http.Request) {
+	moderatorID, target, request, ok := a.moderateUser(w, r)
+	if !ok {
+		return
+	}
+
+	role, _ := middleware.GetRoleFromContext(r.Context())
+	if !models.IsValidRole(request.Role) || !models.RoleAtLeast(role, request.Role) {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	err := repositories.SetUserRole(r.Context(), a.DB, target.ID, request.Role)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionChangeRole, models.TargetUser, target.ID,
+		request.Reason+" (role: "+request.Role+")")
+}
+
+// HandleRestoreUser restores a deleted account that has not been purged yet
+func (a *App) HandleRestoreUser(w http.ResponseWriter, r *http.Request) {
+	moderatorID, userID, request, ok := a.moderateContent(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Stores.Users.Restore(r.Context(), userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionRestoreUser, models.TargetUser, userID, request.Reason)
+}
+
+// HandleRemovePost removes any user's post
+func (a *App) HandleRemovePost(w http.ResponseWriter, r *http.Request) {
+	moderatorID, postID, request, ok := a.moderateContent(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Stores.Posts.Remove(r.Context(), postID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionRemovePost, models.TargetPost, postID, request.Reason)
+}
+
+// HandleRemoveComment removes any user's comment
+func (a *App) HandleRemoveComment(w http.ResponseWriter, r *http.Request) {
+	moderatorID, commentID, request, ok := a.moderateContent(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Stores.Comments.Remove(r.Context(), commentID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordModerationAction(w, r, moderatorID, models.ActionRemoveComment, models.TargetComment, commentID, request.Reason)
+}
+
+// HandleGetModerationActions lists the most recent moderation actions
+func (a *App) HandleGetModerationActions(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(w, r, defaultModerationActionsLimit)
+	if !ok {
+		return
+	}
+
+	actions, err := repositories.GetRecentModerationActions(r.Context(), a.DB, limit)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(actions)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleGetReportQueue lists reported targets with open reports, the most urgent first
+func (a *App) HandleGetReportQueue(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(w, r, defaultReportQueueLimit)
+	if !ok {
+		return
+	}
+
+	queue, err := repositories.GetReportQueue(r.Context(), a.DB, limit)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(queue)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleGetReportsForTarget lists the individual reports about a user, post or comment
+func (a *App) HandleGetReportsForTarget(w http.ResponseWriter, r *http.Request) {
+	targetType, targetID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	reports, err := repositories.GetReportsForTarget(r.Context(), a.DB, targetType, targetID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(reports)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleResolveReports closes the open reports about a target. Hidden content is left out of feeds,
+// profiles and comment lists, and hiding a user hides everything they posted.
+func (a *App) HandleResolveReports(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	targetType, targetID, ok := parseReportTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var request models.ResolutionRequest
+	if !decodeValid(w, r, &request) {
+		return
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		problem.Write(w, r, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	if !models.IsValidResolution(request.Resolution) {
+		problem.Write(w, r, http.StatusBadRequest, "Unknown resolution: "+request.Resolution)
+		return
+	}
+
+	err := repositories.ResolveReports(r.Context(), a.DB, targetType, targetID, request.Resolution, moderatorID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	action := map[string]string{
+		models.ResolutionOpen:      models.ActionReopenReports,
+		models.ResolutionDismissed: models.ActionDismissReports,
+		models.ResolutionHidden:    models.ActionHideContent,
+	}[request.Resolution]
+	a.recordModerationAction(w, r, moderatorID, action, targetType, targetID, request.Reason)
+}
+
+// parseReportTarget reads the target type and ID of the report endpoints from the path
+func parseReportTarget(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	targetType := r.PathValue("target_type")
+	if !models.IsValidTarget(targetType) {
+		problem.Write(w, r, http.StatusBadRequest, "Unknown target type: "+targetType)
+		return "", 0, false
+	}
+
+	targetID, err := strconv.Atoi(r.PathValue("target_id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return "", 0, false
+	}
+
+	return targetType, targetID, true
+}
+
+// parseLimit reads the optional limit query parameter, capped at maxListLimit
+func parseLimit(w http.ResponseWriter, r *http.Request, defaultLimit int) (int, bool) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return defaultLimit, true
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid limit")
+		return 0, false
+	}
+	return min(limit, maxListLimit), true
+}
+
+// moderateContent parses the content ID and moderation request shared by the content removal endpoints
+func (a *App) moderateContent(w http.ResponseWriter, r *http.Request) (int, int, *models.ModerationRequest, bool) {
+	moderatorID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return 0, 0, nil, false
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return 0, 0, nil, false
+	}
+
+	request, ok := decodeModerationRequest(w, r)
+	if !ok {
+		return 0, 0, nil, false
+	}
+
+	return moderatorID, id, request, true
+}
+
+// moderateUser loads the target user of a moderation request. Moderators can only act on users
+// with a lower role than their own, which also stops them from acting on themselves.
+func (a *App) moderateUser(w http.ResponseWriter, r *http.Request) (int, *models.User, *models.ModerationRequest, bool) {
+	moderatorID, targetID, request, ok := a.moderateContent(w, r)
+	if !ok {
+		return 0, nil, nil, false
+	}
+
+	target, err := a.Stores.Users.Get(r.Context(), targetID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return 0, nil, nil, false
+	}
+
+	role, _ := middleware.GetRoleFromContext(r.Context())
+	if models.RoleAtLeast(target.Role, role) {
+		problem.Write(w, r, http.StatusForbidden, "Cannot moderate a user with an equal or higher role")
+		return 0, nil, nil, false
+	}
+
+	return moderatorID, target, request, true
+}
+
+// decodeModerationRequest reads the request body, every moderation action needs a reason
+func decodeModerationRequest(w http.ResponseWriter, r *http.Request) (*models.ModerationRequest, bool) {
+	var request models.ModerationRequest
+	if !decodeValid(w, r, &request) {
+		return nil, false
+	}
+
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" {
+		problem.Write(w, r, http.StatusBadRequest, "A reason is required")
+		return nil, false
+	}
+
+	return &request, true
+}
+
+// recordModerationAction records a completed moderation action both for moderators and in the audit log
+func (a *App) recordModerationAction(w http.ResponseWriter, r *http.Request, moderatorID int, action, targetType string, targetID int, reason string) {
+	err := repositories.AddModerationAction(r.Context(), a.DB, &models.ModerationAction{
+		ModeratorID: moderatorID,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Reason:      reason,
+	})
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordAudit(r, models.AuditAdminPrefix+action, &moderatorID, targetType, &targetID, reason)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"database/sql"
+	"instagram/internal/config"
+	"instagram/internal/oidc"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"instagram/internal/storage"
+	"log/slog"
+)
+
+// App holds what the HTTP handlers depend on, the handlers are its methods. main builds a single App,
+// tests build their own and can replace any of the stores with a fake.
+type App struct {
+	Config *config.Config
+	// Logger is the base logger. Handlers log through the logger of their request, which the logging
+	// middleware derives from it.
+	Logger *slog.Logger
+	// DB is used by the repositories that do not have a store yet
+	DB     *sql.DB
+	Stores *repositories.Stores
+	// Services hold the business rules, they share the stores above
+	Services *services.Services
+	Media    *storage.Local
+	// OIDC are the configured identity providers users can log in with
+	OIDC oidc.Providers
+}
+
+// NewApp returns an App whose stores are those of the SQLite database
+func NewApp(cfg *config.Config, logger *slog.Logger, db *sql.DB, media *storage.Local) *App {
+	stores := repositories.NewSQLiteStores(db)
+	return &App{
+		Config:   cfg,
+		Logger:   logger,
+		DB:       db,
+		Stores:   stores,
+		Services: services.New(stores, cfg.Auth.BcryptCost),
+		Media:    media,
+		OIDC:     oidc.NewProviders(cfg.OIDC.Providers),
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"instagram/internal/logging"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultAuditEventsLimit = 100
+
+// recordAudit appends an event to the audit log with the client IP and request ID of r.
+// A failure to write the audit log is logged but does not fail the request, the action has already happened.
+func (a *App) recordAudit(r *http.Request, action string, actorID *int, targetType string, targetID *int, details string) {
+	requestID, _ := middleware.GetRequestIDFromContext(r.Context())
+	err := repositories.AddAuditEvent(r.Context(), a.DB, &models.AuditEvent{
+		Action:     action,
+		ActorID:    actorID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  utils.ClientIP(r),
+		RequestID:  requestID,
+		Details:    details,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("Failed to record audit event", "action", action, "error", err)
+	}
+}
+
+// recordAuditByUser records an action the authenticated user took on a target
+func (a *App) recordAuditByUser(r *http.Request, action, targetType string, targetID int, details string) {
+	var actorID *int
+	if userID, ok := middleware.GetUserIDFromContext(r.Context()); ok {
+		actorID = &userID
+	}
+	a.recordAudit(r, action, actorID, targetType, &targetID, details)
+}
+
+// HandleGetAuditEvents searches the audit log. Filters are given as query parameters:
+// action, actor_id, target_type, target_id, request_id, since and until (RFC 3339) and limit.
+func (a *App) HandleGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		RequestID:  query.Get("request_id"),
+	}
+
+	for name, dest := range map[string]*int{"actor_id": &filter.ActorID, "target_id": &filter.TargetID} {
+		if value := query.Get(name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				problem.Write(w, r, http.StatusBadRequest, "Invalid "+name)
+				return
+			}
+			*dest = id
+		}
+	}
+
+	for name, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				problem.Write(w, r, http.StatusBadRequest, "Invalid "+name+", expected an RFC 3339 timestamp")
+				return
+			}
+			*dest = t
+		}
+	}
+
+	limit, ok := parseLimit(w, r, defaultAuditEventsLimit)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+
+	events, err := repositories.GetAuditEvents(r.Context(), a.DB, filter)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(events)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
@@ -1,67 +1,199 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
+	"instagram/internal/metrics"
 	"instagram/internal/middleware"
 	"instagram/internal/models"
+	"instagram/internal/problem"
 	"instagram/internal/repositories"
 	"instagram/internal/utils"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-func HandleLogin(w http.ResponseWriter, r *http.Request) {
-	// Get the database connection from the context
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	// Decode the user from the request body
 	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &user) {
 		return
 	}
 
 	// Ensure email and password are provided
 	if user.Email == "" || user.Password == "" {
-		http.Error(w, "Email and Password are required", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Email and Password are required")
 		return
 	}
 
-	// Hash the plaintext password
-	user.PasswordHash, _ = utils.HashPassword(user.Password)
-
 	// Get the user metadata like ID from the database if it matches the email and passwordHash
-	auth, err := repositories.GetUserAuth(db, user.Email)
+	auth, err := repositories.GetUserAuth(r.Context(), a.DB, user.Email)
 	if err != nil {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		// The address is left out, it may be personal data of somebody who never signed up
+		a.recordAudit(r, models.AuditLoginFailed, nil, "", nil, "unknown email")
+		metrics.FailedLogins.WithLabelValues(metrics.LoginUnknownEmail).Inc()
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	// Refuse to check passwords while the account is locked after repeated failures
+	if !a.checkLoginLockout(w, r, auth.ID) {
 		return
 	}
 
 	// Compare the password hash from the database with the hashed password
 	isCorrectPassword := utils.VerifyPassword(user.Password, auth.PasswordHash)
 	if !isCorrectPassword {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+		a.recordAudit(r, models.AuditLoginFailed, nil, models.TargetUser, &auth.ID, "invalid password")
+		metrics.FailedLogins.WithLabelValues(metrics.LoginInvalidPassword).Inc()
+		if _, err := repositories.RecordFailedLogin(r.Context(), a.DB, auth.ID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	// Users with two-factor authentication must complete a second step before receiving a JWT
+	a.completeLogin(w, r, auth.ID, false)
+}
+
+// completeLogin issues either an MFA challenge or the final JWT for a user whose password has been verified.
+// restore is set when the user logs in to restore their deleted account.
+func (a *App) completeLogin(w http.ResponseWriter, r *http.Request, userID int, restore bool) {
+	var user *models.User
+	var err error
+	if restore {
+		user, err = repositories.GetDeletedUserByID(r.Context(), a.DB, userID)
+	} else {
+		user, err = a.Stores.Users.Get(r.Context(), userID)
+	}
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if user.SuspendedAtTime(time.Now()) {
+		problem.Write(w, r, http.StatusForbidden, "Account suspended")
+		return
+	}
+
+	totp, err := repositories.GetTOTP(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if totp.Enabled() {
+		mfaToken, claims, err := utils.GenerateMFAChallengeJWT([]byte(a.Config.Auth.JWTSecret), userID, restore)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+
+		response := models.MFARequired{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+			ExpiresAt:   claims.ExpiresAt.Time,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			problem.Error(w, r, err)
+		}
+		return
+	}
+
+	a.finishLogin(w, r, userID, restore)
+}
+
+// finishLogin logs in a user who passed every factor. Deleted accounts are restored and deactivated ones
+// reactivated only now, so a password alone cannot undo either for accounts with two-factor authentication.
+func (a *App) finishLogin(w http.ResponseWriter, r *http.Request, userID int, restore bool) {
+	if restore {
+		if err := a.Stores.Users.Restore(r.Context(), userID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		a.recordAudit(r, models.AuditUserRestored, &userID, models.TargetUser, &userID, "")
+	}
+
+	user, err := a.Stores.Users.Get(r.Context(), userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Logging in again is how a user reactivates their account
+	if user.DeactivatedAt != nil {
+		if err := a.Stores.Users.Reactivate(r.Context(), userID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		a.recordAudit(r, models.AuditUserReactivated, &userID, models.TargetUser, &userID, "")
+	}
+
+	a.writeTokenResponse(w, r, userID)
+}
+
+// checkLoginLockout writes a 429 response and returns false if the account is currently locked
+func (a *App) checkLoginLockout(w http.ResponseWriter, r *http.Request, userID int) bool {
+	lockout, err := repositories.GetLoginLockout(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return false
+	}
+
+	now := time.Now()
+	if lockout.LockedAt(now) {
+		retryAfter := int(math.Ceil(lockout.LockedUntil.Sub(now).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		problem.Write(w, r, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+		return false
+	}
+
+	return true
+}
+
+// writeTokenResponse starts a new session for the user and writes a JWT bound to it to the client
+func (a *App) writeTokenResponse(w http.ResponseWriter, r *http.Request, userID int) {
+	// A completed login clears any failed attempts
+	err := repositories.ResetFailedLogins(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Record the device the user is logging in from
+	session, err := repositories.CreateSession(r.Context(), a.DB, &models.Session{
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IPAddress: utils.ClientIP(r),
+		ExpiresAt: time.Now().UTC().Add(utils.SessionDuration),
+	})
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
 
-	// Generate the JWT token with the user's ID
-	token, claims, err := utils.GenerateJWT(auth.ID)
+	a.recordAudit(r, models.AuditLogin, &userID, models.TargetUser, &userID, fmt.Sprintf("session %d", session.ID))
+
+	// Generate the JWT token with the user's ID and session
+	token, claims, err := utils.GenerateJWT([]byte(a.Config.Auth.JWTSecret), userID, session.ID)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, "Failed to generate token")
 		return
 	}
 
 	// Return the token and expiration time to the client
-	response := map[string]interface{}{
-		"token":      token,
-		"expires_at": claims.ExpiresAt.Time.Format(time.RFC3339),
-		"id":         auth.ID,
+	response := models.TokenResponse{
+		Token:     token,
+		ExpiresAt: claims.ExpiresAt.Time,
+		ID:        userID,
 	}
 
 	// Set the response headers and write the response
@@ -69,63 +201,116 @@ func HandleLogin(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 	}
 }
 
-func HandleSignup(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
+func (a *App) HandleSignup(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if !decodeJSON(w, r, &user) {
 		return
 	}
 
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	// The service validates the user and hashes the password before storing it
+	newUser, err := a.Services.Users.Create(r.Context(), &user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Start a session and send back the JWT token and expiration to the client
+	a.writeTokenResponse(w, r, newUser.ID)
+}
+
+// HandleChangePassword changes the authenticated user's password after checking their current one
+func (a *App) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var change models.PasswordChange
+	if !decodeValid(w, r, &change) {
+		return
+	}
+
+	if change.CurrentPassword == "" || change.NewPassword == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Current and new password are required")
 		return
 	}
 
-	// Validate that necessary fields are provided
-	if user.Username == "" || user.Email == "" || user.Password == "" {
-		http.Error(w, "Username, Email, and Password are required", http.StatusBadRequest)
+	user, err := a.Stores.Users.Get(r.Context(), userID)
+	if err != nil {
+		problem.Error(w, r, err)
 		return
 	}
 
-	// Hash the user's password before storing it in the database
-	hashedPassword, err := utils.HashPassword(user.Password)
+	auth, err := repositories.GetUserAuth(r.Context(), a.DB, user.Email)
 	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
-	user.PasswordHash = string(hashedPassword) // Store hashed password
-	user.Password = ""                         // Don't store plain-text password
 
-	// Save the user to the database
-	newUser, err := repositories.SaveUser(db, &user)
+	if !utils.VerifyPassword(change.CurrentPassword, auth.PasswordHash) {
+		problem.Write(w, r, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(change.NewPassword, a.Config.Auth.BcryptCost)
 	if err != nil {
-		http.Error(w, "Failed to register user: "+err.Error(), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
-	// Generate a JWT token for the new user
-	token, claims, err := utils.GenerateJWT(newUser.ID)
+	err = repositories.UpdatePasswordHash(r.Context(), a.DB, userID, hashedPassword)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
-	// Send back the JWT token and expiration to the client
-	response := map[string]interface{}{
-		"token":      token,
-		"expires_at": claims.ExpiresAt.Time.Format(time.RFC3339),
+	a.recordAuditByUser(r, models.AuditPasswordChanged, models.TargetUser, userID, "")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRestoreAccount restores a deleted account that has not been purged yet and logs the user in
+func (a *App) HandleRestoreAccount(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if !decodeJSON(w, r, &user) {
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(response)
+	if user.Email == "" || user.Password == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Email and Password are required")
+		return
+	}
+
+	auth, err := repositories.GetDeletedUserAuth(r.Context(), a.DB, user.Email)
 	if err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		problem.Error(w, r, err)
+		return
+	}
+	if auth == nil {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
+	// Restoring is a login, so it is subject to the same lockout
+	if !a.checkLoginLockout(w, r, auth.ID) {
+		return
+	}
+
+	if !utils.VerifyPassword(user.Password, auth.PasswordHash) {
+		a.recordAudit(r, models.AuditLoginFailed, nil, models.TargetUser, &auth.ID, "invalid password")
+		metrics.FailedLogins.WithLabelValues(metrics.LoginInvalidPassword).Inc()
+		if _, err := repositories.RecordFailedLogin(r.Context(), a.DB, auth.ID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid email or password")
+		return
 	}
+
+	// The account is restored once the user has passed two-factor authentication, if they use it
+	a.completeLogin(w, r, auth.ID, true)
 }
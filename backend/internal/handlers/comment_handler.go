@@ -1,109 +1,83 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"instagram/internal/middleware"
 	"instagram/internal/models"
-	"instagram/internal/repositories"
+	"instagram/internal/problem"
 	"net/http"
 	"strconv"
 )
 
-func HandlePostComment(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandlePostComment(w http.ResponseWriter, r *http.Request) {
 	var comment models.Comment
-	err := json.NewDecoder(r.Body).Decode(&comment)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if comment.UserID == 0 || comment.PostID == 0 {
-		http.Error(w, "Invalid user or post ID", http.StatusBadRequest)
+	if !decodeJSON(w, r, &comment) {
 		return
 	}
 
-	err = repositories.AddComment(db, &comment)
+	err := a.Services.Comments.Create(r.Context(), &comment)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleGetComment(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandleGetComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	comments, err := repositories.GetComment(db, commentID)
+	comments, err := a.Services.Comments.Get(r.Context(), commentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(comments)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleDeleteComment(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandleDeleteComment(w http.ResponseWriter, r *http.Request) {
 	commentID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	err = repositories.DeleteComment(db, commentID)
+	err = a.Services.Comments.Delete(r.Context(), commentID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
+
+	a.recordAuditByUser(r, models.AuditCommentDeleted, models.TargetComment, commentID, "")
 }
 
-func HandleGetCommentsForPost(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
+func (a *App) HandleGetCommentsForPost(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(r.PathValue("post_id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	postID, err := strconv.Atoi(r.PathValue("post_id"))
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+	page, ok := parsePage(w, r)
+	if !ok {
 		return
 	}
 
-	comments, err := repositories.GetCommentsForPost(db, postID)
+	comments, err := a.Services.Comments.ListForPost(r.Context(), postID, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	err = json.NewEncoder(w).Encode(comments)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// exportDownloadTimeout replaces the server's write timeout for downloads of export archives
+const exportDownloadTimeout = 30 * time.Minute
+
+// HandlePostDataExport starts building an archive of everything stored about the authenticated user
+func (a *App) HandlePostDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	active, err := repositories.HasActiveDataExport(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if active {
+		problem.Write(w, r, http.StatusConflict, "An export is already in progress")
+		return
+	}
+
+	export, err := repositories.CreateDataExport(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/export/%d", export.ID))
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleGetDataExport reports the status of one of the user's exports. Completed exports include
+// a download link that is valid for utils.ExportDownloadTTL.
+func (a *App) HandleGetDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	exportID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	export, err := repositories.GetDataExport(r.Context(), a.DB, exportID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if export == nil || export.UserID != userID {
+		problem.Write(w, r, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	if export.Status == models.ExportCompleted {
+		token, _, err := utils.GenerateExportDownloadJWT([]byte(a.Config.Auth.JWTSecret), userID, export.ID)
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, "Failed to generate token")
+			return
+		}
+		export.DownloadURL = fmt.Sprintf("/export/%d/download?token=%s", export.ID, url.QueryEscape(token))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleDownloadDataExport serves an export archive to the holder of a download link
+func (a *App) HandleDownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	exportID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	userID, tokenExportID, err := utils.VerifyExportDownloadJWT([]byte(a.Config.Auth.JWTSecret), r.URL.Query().Get("token"))
+	if err != nil || tokenExportID != exportID {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired download link")
+		return
+	}
+
+	export, err := repositories.GetDataExport(r.Context(), a.DB, exportID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if export == nil || export.UserID != userID || export.Status != models.ExportCompleted {
+		problem.Write(w, r, http.StatusNotFound, "Export not found")
+		return
+	}
+
+	file, err := os.Open(export.FilePath)
+	if err != nil {
+		problem.Write(w, r, http.StatusNotFound, "Export not found")
+		return
+	}
+	defer file.Close()
+
+	a.recordAudit(r, models.AuditDataExported, &userID, models.TargetUser, &userID, fmt.Sprintf("export %d", export.ID))
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="instagram-export-%d.zip"`, export.ID))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Archives with a lot of media take longer to download than the server's write timeout allows
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportDownloadTimeout))
+
+	modTime := time.Time{}
+	if export.CompletedAt != nil {
+		modTime = *export.CompletedAt
+	}
+	http.ServeContent(w, r, "", modTime, file)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"instagram/internal/problem"
+	"instagram/internal/validation"
+	"net/http"
+)
+
+// decodeJSON decodes the request body into v. It writes a 400 response, or 413 for oversized bodies,
+// and returns false if the body is not acceptable.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := validation.Decode(w, r, v)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		problem.Write(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBytesErr.Limit))
+		return false
+	case err != nil:
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// decodeValid is decodeJSON followed by the validation rules of v, which are reported as a 422 response
+// listing the invalid fields
+func decodeValid(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !decodeJSON(w, r, v) {
+		return false
+	}
+	if err := validation.Struct(v); err != nil {
+		problem.Error(w, r, err)
+		return false
+	}
+	return true
+}
@@ -1,67 +1,34 @@
 package handlers
 
 import (
-	"database/sql"
-	"encoding/json"
-	"instagram/internal/middleware"
+	"instagram/internal/metrics"
 	"instagram/internal/models"
-	"instagram/internal/repositories"
+	"instagram/internal/problem"
 	"net/http"
 )
 
-func HandlePostFollow(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandlePostFollow(w http.ResponseWriter, r *http.Request) {
 	var follow models.Follow
-	err := json.NewDecoder(r.Body).Decode(&follow)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &follow) {
 		return
 	}
 
-	if follow.FollowerID == follow.FollowingID {
-		http.Error(w, "You can't follow yourself", http.StatusBadRequest)
-		return
-	}
-
-	if follow.FollowerID == 0 || follow.FollowingID == 0 {
-		http.Error(w, "Invalid follower or following ID", http.StatusBadRequest)
-		return
-	}
-
-	err = repositories.AddFollow(db, &follow)
+	err := a.Services.Follows.Follow(r.Context(), &follow, metrics.SourceAPI)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleDeleteFollow(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandleDeleteFollow(w http.ResponseWriter, r *http.Request) {
 	var follow models.Follow
-	err := json.NewDecoder(r.Body).Decode(&follow)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if follow.FollowerID == 0 || follow.FollowingID == 0 {
-		http.Error(w, "Invalid follower or following ID", http.StatusBadRequest)
+	if !decodeJSON(w, r, &follow) {
 		return
 	}
 
-	err = repositories.RemoveFollow(db, &follow)
+	err := a.Services.Follows.Unfollow(r.Context(), &follow)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"instagram/internal/graphql"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"net/http"
+)
+
+// HandleGraphQL runs a GraphQL query. Queries that cannot be run get a 400 response with their errors,
+// the others a 200 response with their data and the errors of the fields that failed.
+func (a *App) HandleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request models.GraphQLRequest
+	if !decodeValid(w, r, &request) {
+		return
+	}
+
+	result := graphql.Execute(r.Context(), a.Services, a.Stores, request)
+
+	status := http.StatusOK
+	if result.Data == nil && result.HasErrors() {
+		status = http.StatusBadRequest
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
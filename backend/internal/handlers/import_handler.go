@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"instagram/internal/importer"
+	"instagram/internal/logging"
+	"instagram/internal/metrics"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImportSize bounds uploaded archives, larger accounts can be exported in date ranges
+	maxImportSize = 512 << 20
+	// maxImportMemory is how much of an upload is kept in memory before it is spooled to disk
+	maxImportMemory = 32 << 20
+	// maxImportMediaSize bounds each media file copied out of an archive, a few kilobytes of ZIP can expand to gigabytes
+	maxImportMediaSize = 32 << 20
+	// importTimeout replaces the server's read and write timeouts, uploading and importing an archive takes longer
+	importTimeout = 15 * time.Minute
+)
+
+// importImageTypes are the media extensions that can become posts. Posts only have a single image, so videos are skipped.
+var importImageTypes = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true}
+
+// importContentTypes are the sniffed content types accepted for those extensions, so other files can't be
+// served from the media directory under an image name
+var importContentTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true}
+
+// HandleImportInstagram imports the posts and follows of an Instagram "Download your information" archive
+// in JSON format, uploaded as the "archive" field of a multipart form. Items already imported are
+// reported as duplicates, so an archive can safely be imported again.
+func (a *App) HandleImportInstagram(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	// Not every ResponseWriter supports deadlines, the server timeouts apply then
+	controller := http.NewResponseController(w)
+	_ = controller.SetReadDeadline(time.Now().Add(importTimeout))
+	_ = controller.SetWriteDeadline(time.Now().Add(importTimeout))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, "Archive is too large")
+			return
+		}
+		problem.Write(w, r, http.StatusBadRequest, "Invalid multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Missing archive")
+		return
+	}
+	defer file.Close()
+
+	zipReader, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Archive is not a ZIP file")
+		return
+	}
+
+	archive, err := importer.ParseInstagramArchive(zipReader)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summary := &models.ImportSummary{Source: models.ImportSourceInstagram, Items: []models.ImportItem{}}
+	for _, post := range archive.Posts {
+		summary.Add(a.importInstagramPost(r.Context(), archive, userID, post))
+	}
+	for _, username := range archive.Following {
+		summary.Add(a.importInstagramFollow(r.Context(), userID, username))
+	}
+
+	a.recordAuditByUser(r, models.AuditDataImported, "user", userID,
+		fmt.Sprintf("%s: %d imported, %d duplicate, %d skipped, %d failed",
+			summary.Source, summary.Imported, summary.Duplicate, summary.Skipped, summary.Failed))
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(summary)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// importInstagramPost copies the first image of the post into storage and creates the post with its original time.
+// The post's media path identifies it, Instagram archives have no post IDs.
+func (a *App) importInstagramPost(ctx context.Context, archive *importer.InstagramArchive, userID int, post importer.InstagramPost) models.ImportItem {
+	item := models.ImportItem{Type: models.ImportTypePost, Status: models.ImportStatusSkipped}
+	if len(post.Media) == 0 {
+		item.Reason = "post has no media"
+		return item
+	}
+	item.Source = post.Media[0]
+
+	if !importImageTypes[strings.ToLower(path.Ext(item.Source))] {
+		item.Reason = "only photos can be imported"
+		return item
+	}
+
+	postID, err := a.Stores.Imports.PostID(ctx, userID, models.ImportSourceInstagram, item.Source)
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+	if postID != 0 {
+		item.Status, item.ID = models.ImportStatusDuplicate, postID
+		return item
+	}
+
+	size, err := archive.Size(item.Source)
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+	if size > maxImportMediaSize {
+		item.Reason = fmt.Sprintf("media files larger than %d MB can't be imported", maxImportMediaSize>>20)
+		return item
+	}
+
+	content, err := archive.Open(item.Source)
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+	defer content.Close()
+
+	limited := io.LimitReader(content, maxImportMediaSize)
+	head := make([]byte, 512)
+	n, err := io.ReadFull(limited, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return failedImport(ctx, item, err)
+	}
+	head = head[:n]
+	if !importContentTypes[http.DetectContentType(head)] {
+		item.Reason = "media file is not an image"
+		return item
+	}
+
+	imageURL, err := a.Media.Save(item.Source, io.MultiReader(bytes.NewReader(head), limited))
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+
+	createdAt := post.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	imported := &models.Post{
+		UserID:    userID,
+		ImageURL:  imageURL,
+		Caption:   post.Caption,
+		CreatedAt: createdAt,
+	}
+	err = a.Services.Posts.Import(ctx, imported, models.ImportSourceInstagram, item.Source)
+	var invalid validation.Errors
+	if errors.As(err, &invalid) {
+		a.Media.Delete(imageURL)
+		item.Reason = invalid.Error()
+		return item
+	}
+	if err != nil {
+		a.Media.Delete(imageURL)
+		return failedImport(ctx, item, err)
+	}
+
+	item.Status, item.ID = models.ImportStatusImported, imported.ID
+	if len(post.Media) > 1 {
+		item.Reason = fmt.Sprintf("only the first of %d media files was imported", len(post.Media))
+	}
+	return item
+}
+
+// importInstagramFollow follows the account if somebody with the same username is registered here
+func (a *App) importInstagramFollow(ctx context.Context, userID int, username string) models.ImportItem {
+	item := models.ImportItem{Type: models.ImportTypeFollow, Source: username, Status: models.ImportStatusSkipped}
+
+	followingID, err := repositories.GetUserIDByUsername(ctx, a.DB, username)
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+	if followingID == 0 {
+		item.Reason = "no user with this username"
+		return item
+	}
+	if followingID == userID {
+		item.Reason = "cannot follow yourself"
+		return item
+	}
+	item.ID = followingID
+
+	exists, err := a.Stores.Follows.Exists(ctx, userID, followingID)
+	if err != nil {
+		return failedImport(ctx, item, err)
+	}
+	if exists {
+		item.Status = models.ImportStatusDuplicate
+		return item
+	}
+
+	follow := &models.Follow{FollowerID: userID, FollowingID: followingID}
+	if err := a.Services.Follows.Follow(ctx, follow, metrics.SourceImport); err != nil {
+		return failedImport(ctx, item, err)
+	}
+	item.Status = models.ImportStatusImported
+	return item
+}
+
+// failedImport marks the item as failed. Only domain errors are shown as the reason, anything else
+// may contain internals and is logged instead.
+func failedImport(ctx context.Context, item models.ImportItem, err error) models.ImportItem {
+	item.Status, item.Reason = models.ImportStatusFailed, "Internal error"
+	var domainErr *repositories.Error
+	if errors.As(err, &domainErr) {
+		item.Reason = domainErr.Message
+	} else {
+		logging.FromContext(ctx).Error("Failed to import item", "source", item.Source, "error", err)
+	}
+	return item
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"instagram/internal/metrics"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"time"
+)
+
+// TOTPIssuer is the issuer name shown in authenticator apps
+const TOTPIssuer = "Instagram"
+
+// recoveryCodeCount is the number of recovery codes handed out when TOTP is confirmed
+const recoveryCodeCount = 10
+
+// HandleTOTPEnroll starts TOTP enrollment and returns the secret and provisioning URI for the QR code
+func (a *App) HandleTOTPEnroll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	existing, err := repositories.GetTOTP(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if existing.Enabled() {
+		problem.Write(w, r, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	user, err := a.Stores.Users.Get(r.Context(), userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	err = repositories.SaveTOTPSecret(r.Context(), a.DB, userID, secret)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	response := models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(TOTPIssuer, user.Email, secret),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		problem.Error(w, r, err)
+	}
+}
+
+// HandleTOTPConfirm enables TOTP once the user proves their authenticator works, and returns recovery codes
+func (a *App) HandleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var challenge models.MFAChallenge
+	if !decodeValid(w, r, &challenge) {
+		return
+	}
+
+	totp, err := repositories.GetTOTP(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if totp == nil {
+		problem.Write(w, r, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		return
+	}
+
+	if totp.Enabled() {
+		problem.Write(w, r, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	verified, err := a.useTOTPCode(r.Context(), userID, totp.Secret, challenge.Code)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !verified {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	err = repositories.ConfirmTOTP(r.Context(), a.DB, userID, hashes)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	writeRecoveryCodes(w, r, codes)
+}
+
+// HandleTOTPRecoveryCodes replaces the user's recovery codes after verifying a current TOTP code
+func (a *App) HandleTOTPRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var challenge models.MFAChallenge
+	if !decodeValid(w, r, &challenge) {
+		return
+	}
+
+	totp, err := repositories.GetTOTP(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !totp.Enabled() {
+		problem.Write(w, r, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	verified, err := a.useTOTPCode(r.Context(), userID, totp.Secret, challenge.Code)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !verified {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	err = repositories.ReplaceRecoveryCodes(r.Context(), a.DB, userID, hashes)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	writeRecoveryCodes(w, r, codes)
+}
+
+// HandleTOTPDisable turns off TOTP after verifying a current code or an unused recovery code
+func (a *App) HandleTOTPDisable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var challenge models.MFAChallenge
+	if !decodeValid(w, r, &challenge) {
+		return
+	}
+
+	verified, err := a.verifySecondFactor(r.Context(), userID, &challenge)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !verified {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	err = repositories.DeleteTOTP(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMFAVerify exchanges an MFA challenge token and a valid code for a real JWT. Both the token and
+// the code are accepted once.
+func (a *App) HandleMFAVerify(w http.ResponseWriter, r *http.Request) {
+	var challenge models.MFAChallenge
+	if !decodeValid(w, r, &challenge) {
+		return
+	}
+
+	if challenge.MFAToken == "" || (challenge.Code == "" && challenge.RecoveryCode == "") {
+		problem.Write(w, r, http.StatusBadRequest, "MFA token and code are required")
+		return
+	}
+
+	claims, err := utils.VerifyMFAChallengeJWT([]byte(a.Config.Auth.JWTSecret), challenge.MFAToken)
+	if err != nil {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+	userID := claims.UserID
+
+	// Used challenges are rejected before the second factor is checked, so they can't consume recovery codes
+	used, err := repositories.MFAChallengeUsed(r.Context(), a.DB, claims.ID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if used {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	if !a.checkLoginLockout(w, r, userID) {
+		return
+	}
+
+	verified, err := a.verifySecondFactor(r.Context(), userID, &challenge)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !verified {
+		a.recordAudit(r, models.AuditLoginFailed, nil, models.TargetUser, &userID, "invalid second factor")
+		metrics.FailedLogins.WithLabelValues(metrics.LoginInvalidSecondFactor).Inc()
+		if _, err := repositories.RecordFailedLogin(r.Context(), a.DB, userID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid code")
+		return
+	}
+
+	// A challenge is exchanged for one session only, even if its token was intercepted
+	unused, err := repositories.UseMFAChallenge(r.Context(), a.DB, claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !unused {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return
+	}
+
+	a.finishLogin(w, r, userID, claims.Restore)
+}
+
+// verifySecondFactor checks a TOTP code, falling back to consuming a recovery code
+func (a *App) verifySecondFactor(ctx context.Context, userID int, challenge *models.MFAChallenge) (bool, error) {
+	totp, err := repositories.GetTOTP(ctx, a.DB, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if !totp.Enabled() {
+		return false, nil
+	}
+
+	if challenge.Code != "" {
+		return a.useTOTPCode(ctx, userID, totp.Secret, challenge.Code)
+	}
+
+	if challenge.RecoveryCode != "" {
+		return repositories.UseRecoveryCode(ctx, a.DB, userID, utils.HashRecoveryCode(challenge.RecoveryCode))
+	}
+
+	return false, nil
+}
+
+// useTOTPCode checks a TOTP code and records its time step, so the code and earlier ones can't be used again
+func (a *App) useTOTPCode(ctx context.Context, userID int, secret, code string) (bool, error) {
+	step, ok := utils.MatchTOTPCode(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return repositories.UseTOTPStep(ctx, a.DB, userID, step)
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	response := models.RecoveryCodes{RecoveryCodes: codes}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		problem.Error(w, r, err)
+	}
+}
@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/metrics"
+	"instagram/internal/models"
+	"instagram/internal/oidc"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// oidcLoginStateTTL is how long a user has to complete the login at the identity provider
+	oidcLoginStateTTL = 10 * time.Minute
+	// oidcStateCookie ties a login to the browser that started it. It holds the hash of the login's
+	// state, so nobody can complete a login they started themselves in somebody else's browser.
+	oidcStateCookie = "oidc_state"
+)
+
+// HandleOIDCLogin redirects the user to the identity provider to start an authorization code + PKCE login
+func (a *App) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := a.OIDC.Get(r.PathValue("provider"))
+	if !ok {
+		problem.Write(w, r, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	loginState := models.OIDCLoginState{Provider: provider.Name()}
+	for _, value := range []*string{&loginState.State, &loginState.Nonce, &loginState.CodeVerifier} {
+		token, err := oidc.RandomToken()
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		*value = token
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), loginState.State, loginState.Nonce, loginState.CodeVerifier)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+
+	err = repositories.SaveOIDCLoginState(r.Context(), a.DB, &loginState)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Lax cookies are sent along with the provider's top-level redirect to the callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    oidcStateHash(loginState.State),
+		Path:     "/auth/oidc/" + provider.Name() + "/",
+		MaxAge:   int(oidcLoginStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(provider.RedirectURL(), "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// HandleOIDCCallback completes the login when the identity provider redirects back with an authorization code
+func (a *App) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := a.OIDC.Get(r.PathValue("provider"))
+	if !ok {
+		problem.Write(w, r, http.StatusNotFound, "Unknown identity provider")
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		problem.Write(w, r, http.StatusUnauthorized, "Login failed at identity provider: "+providerErr)
+		return
+	}
+
+	code, state := query.Get("code"), query.Get("state")
+	if code == "" || state == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Code and state are required")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(oidcStateHash(state))) != 1 {
+		problem.Write(w, r, http.StatusBadRequest, "Login was not started in this browser")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/auth/oidc/" + provider.Name() + "/", MaxAge: -1})
+
+	loginState, err := repositories.ConsumeOIDCLoginState(r.Context(), a.DB, provider.Name(), state, oidcLoginStateTTL)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid or expired login state")
+		return
+	}
+
+	token, err := provider.Exchange(r.Context(), code, loginState.CodeVerifier)
+	if err != nil {
+		problem.Write(w, r, http.StatusUnauthorized, "Failed to exchange authorization code")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), token.IDToken, loginState.Nonce)
+	if err != nil {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid ID token")
+		return
+	}
+
+	identity, err := repositories.GetExternalIdentity(r.Context(), a.DB, provider.Name(), claims.Subject)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// External logins go through the same second factor as password logins
+	if identity != nil {
+		a.completeLogin(w, r, identity.UserID, false)
+		return
+	}
+
+	// New identities are matched to accounts by email, which is only meaningful when the provider vouches for it
+	if claims.Email == "" || !bool(claims.EmailVerified) {
+		problem.Write(w, r, http.StatusForbidden, "Identity provider did not return a verified email")
+		return
+	}
+
+	auth, err := repositories.FindUserAuthByEmail(r.Context(), a.DB, claims.Email)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Local accounts don't verify their email, so somebody may have signed up with the address ahead of its
+	// owner. An existing account is only linked once the user also proves they know its password.
+	if auth != nil {
+		a.writeOIDCLinkRequired(w, r, auth.ID, provider.Name(), claims)
+		return
+	}
+
+	userID, err := a.createExternalUser(r.Context(), provider.Name(), claims)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.completeLogin(w, r, userID, false)
+}
+
+// HandleOIDCLink links an external identity to the existing account with its verified email once the user
+// gives the account's password, and logs them in
+func (a *App) HandleOIDCLink(w http.ResponseWriter, r *http.Request) {
+	var link models.OIDCLink
+	if !decodeJSON(w, r, &link) {
+		return
+	}
+
+	if link.LinkToken == "" || link.Password == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Link token and password are required")
+		return
+	}
+
+	claims, err := utils.VerifyOIDCLinkJWT([]byte(a.Config.Auth.JWTSecret), link.LinkToken)
+	if err != nil {
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired link token")
+		return
+	}
+	userID := claims.UserID
+
+	// Linking checks the password, so it is subject to the same lockout as logging in
+	if !a.checkLoginLockout(w, r, userID) {
+		return
+	}
+
+	user, err := a.Stores.Users.Get(r.Context(), userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	auth, err := repositories.GetUserAuth(r.Context(), a.DB, user.Email)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if !utils.VerifyPassword(link.Password, auth.PasswordHash) {
+		a.recordAudit(r, models.AuditLoginFailed, nil, models.TargetUser, &userID, "invalid password")
+		metrics.FailedLogins.WithLabelValues(metrics.LoginInvalidPassword).Inc()
+		if _, err := repositories.RecordFailedLogin(r.Context(), a.DB, userID); err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Invalid password")
+		return
+	}
+
+	identity, err := repositories.GetExternalIdentity(r.Context(), a.DB, claims.Provider, claims.Subject)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	if identity == nil {
+		err = repositories.LinkExternalIdentity(r.Context(), a.DB, &models.ExternalIdentity{
+			Provider: claims.Provider,
+			Subject:  claims.Subject,
+			UserID:   userID,
+			Email:    claims.Email,
+		})
+		if err != nil {
+			problem.Error(w, r, err)
+			return
+		}
+		a.recordAudit(r, models.AuditIdentityLinked, &userID, models.TargetUser, &userID, "provider "+claims.Provider)
+	} else if identity.UserID != userID {
+		problem.Write(w, r, http.StatusConflict, "Identity is already linked to another account")
+		return
+	}
+
+	a.completeLogin(w, r, userID, false)
+}
+
+// oidcStateHash returns the value of the state cookie of a login
+func oidcStateHash(state string) string {
+	hash := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(hash[:])
+}
+
+// writeOIDCLinkRequired answers an external login of an existing account with a token for linking the identity
+func (a *App) writeOIDCLinkRequired(w http.ResponseWriter, r *http.Request, userID int, provider string, claims *oidc.IDTokenClaims) {
+	linkToken, registered, err := utils.GenerateOIDCLinkJWT([]byte(a.Config.Auth.JWTSecret), userID, provider, claims.Subject, claims.Email)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	response := models.OIDCLinkRequired{
+		LinkRequired: true,
+		LinkToken:    linkToken,
+		ExpiresAt:    registered.ExpiresAt.Time,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		problem.Error(w, r, err)
+	}
+}
+
+// createExternalUser creates a user for an external identity whose verified email nobody has yet, and links the identity
+func (a *App) createExternalUser(ctx context.Context, provider string, claims *oidc.IDTokenClaims) (int, error) {
+	username, err := a.availableUsername(ctx, claims)
+	if err != nil {
+		return 0, err
+	}
+
+	newUser, err := a.Services.Users.CreateExternal(ctx, &models.User{
+		Auth: models.Auth{Username: username, Email: claims.Email},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = repositories.LinkExternalIdentity(ctx, a.DB, &models.ExternalIdentity{
+		Provider: provider,
+		Subject:  claims.Subject,
+		UserID:   newUser.ID,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return newUser.ID, nil
+}
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._]+`)
+
+// availableUsername derives an unused username from the ID token's preferred username or email
+func (a *App) availableUsername(ctx context.Context, claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = usernameInvalidChars.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) > 24 {
+		base = base[:24]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for attempt := 0; attempt < 10; attempt++ {
+		exists, err := repositories.UsernameExists(ctx, a.DB, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s%d", base, rand.IntN(100000))
+	}
+
+	return "", fmt.Errorf("failed to find an available username")
+}
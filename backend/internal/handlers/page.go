@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"net/http"
+	"strconv"
+)
+
+// parsePage reads the optional limit and offset query parameters of list endpoints. Without a limit
+// the rest of the list is returned, a given limit is capped at maxListLimit.
+func parsePage(w http.ResponseWriter, r *http.Request) (models.Page, bool) {
+	var page models.Page
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			problem.Write(w, r, http.StatusBadRequest, "Invalid limit")
+			return page, false
+		}
+		page.Limit = min(limit, maxListLimit)
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			problem.Write(w, r, http.StatusBadRequest, "Invalid offset")
+			return page, false
+		}
+		page.Offset = offset
+	}
+
+	return page, true
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// HandlePostPersonalAccessToken creates a new personal access token. The token itself is only returned once.
+func (a *App) HandlePostPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var token models.PersonalAccessToken
+	if !decodeValid(w, r, &token) {
+		return
+	}
+
+	if token.Name == "" || len(token.Scopes) == 0 {
+		problem.Write(w, r, http.StatusBadRequest, "Name and at least one scope are required")
+		return
+	}
+
+	for _, scope := range token.Scopes {
+		if !models.IsValidScope(scope) {
+			problem.Write(w, r, http.StatusBadRequest, "Unknown scope: "+scope)
+			return
+		}
+	}
+
+	if token.ExpiresInDays < 0 {
+		problem.Write(w, r, http.StatusBadRequest, "expires_in_days must not be negative")
+		return
+	}
+
+	plaintext, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	token.UserID = userID
+	token.TokenHash = utils.HashPersonalAccessToken(plaintext)
+	slices.Sort(token.Scopes)
+	token.Scopes = slices.Compact(token.Scopes)
+	if token.ExpiresInDays > 0 {
+		expiresAt := time.Now().UTC().AddDate(0, 0, token.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	savedToken, err := repositories.CreatePersonalAccessToken(r.Context(), a.DB, &token)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	savedToken.Token = plaintext
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(savedToken)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleGetPersonalAccessTokens lists the authenticated user's active personal access tokens
+func (a *App) HandleGetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	tokens, err := repositories.GetPersonalAccessTokensForUser(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(tokens)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleDeletePersonalAccessToken revokes one of the authenticated user's personal access tokens
+func (a *App) HandleDeletePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	err = repositories.RevokePersonalAccessToken(r.Context(), a.DB, userID, tokenID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
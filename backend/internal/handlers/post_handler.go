@@ -1,72 +1,59 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"instagram/internal/middleware"
 	"instagram/internal/models"
-	"instagram/internal/repositories"
+	"instagram/internal/problem"
 	"net/http"
 	"strconv"
 )
 
-func HandlePostPost(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
+func (a *App) HandlePostPost(w http.ResponseWriter, r *http.Request) {
+	var post models.Post
+	if !decodeJSON(w, r, &post) {
 		return
 	}
 
-	var post models.Post
-	err := json.NewDecoder(r.Body).Decode(&post)
+	err := a.Services.Posts.Create(r.Context(), &post)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 
-	err = repositories.AddPost(db, &post)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(post)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		problem.Error(w, r, err)
 	}
 }
 
-func HandleDeletePost(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandleDeletePost(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	err = repositories.DeletePost(db, postID)
+	err = a.Services.Posts.Delete(r.Context(), postID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
+
+	a.recordAuditByUser(r, models.AuditPostDeleted, models.TargetPost, postID, "")
 }
 
-func HandleGetPostById(w http.ResponseWriter, r *http.Request) {
+func (a *App) HandleGetPostById(w http.ResponseWriter, r *http.Request) {
 	postID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
-	}
-
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	post, err := repositories.GetPostByID(db, postID)
+	post, err := a.Services.Posts.Get(r.Context(), postID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -74,27 +61,26 @@ func HandleGetPostById(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(post)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleGetPostsForUser(w http.ResponseWriter, r *http.Request) {
+func (a *App) HandleGetPostsForUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.PathValue("user_id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
+	page, ok := parsePage(w, r)
 	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
 		return
 	}
 
-	posts, err := repositories.GetPostsForUser(db, userID)
+	posts, err := a.Services.Posts.ListForUser(r.Context(), userID, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -102,27 +88,26 @@ func HandleGetPostsForUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(posts)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleGetFeedForUser(w http.ResponseWriter, r *http.Request) {
+func (a *App) HandleGetFeedForUser(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.PathValue("user_id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
+	page, ok := parsePage(w, r)
 	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
 		return
 	}
 
-	feed, err := repositories.GetPostsForUserFeed(db, userID)
+	feed, err := a.Services.Posts.Feed(r.Context(), userID, page)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -130,7 +115,7 @@ func HandleGetFeedForUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(feed)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
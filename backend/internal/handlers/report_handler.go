@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"net/http"
+	"strings"
+)
+
+// maxReportDetailsLength limits the free text a reporter can attach to a report
+const maxReportDetailsLength = 1000
+
+// HandlePostReport flags a user, post or comment for moderators. Each user can report a target once.
+func (a *App) HandlePostReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	var report models.Report
+	if !decodeValid(w, r, &report) {
+		return
+	}
+
+	if !models.IsValidTarget(report.TargetType) || report.TargetID == 0 {
+		problem.Write(w, r, http.StatusBadRequest, "A target_type of user, post or comment and a target_id are required")
+		return
+	}
+
+	report.Severity = models.ReasonSeverity(report.Reason)
+	if report.Severity == 0 {
+		problem.Write(w, r, http.StatusBadRequest, "Unknown reason: "+report.Reason)
+		return
+	}
+
+	report.Details = strings.TrimSpace(report.Details)
+	if len(report.Details) > maxReportDetailsLength {
+		problem.Write(w, r, http.StatusBadRequest, "Details are too long")
+		return
+	}
+
+	if report.TargetType == models.TargetUser && report.TargetID == userID {
+		problem.Write(w, r, http.StatusBadRequest, "You cannot report yourself")
+		return
+	}
+
+	exists, err := repositories.ReportTargetExists(r.Context(), a.DB, report.TargetType, report.TargetID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if !exists {
+		problem.Write(w, r, http.StatusNotFound, "Reported "+report.TargetType+" not found")
+		return
+	}
+
+	report.ReporterID = userID
+	created, err := repositories.AddReport(r.Context(), a.DB, &report)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+	if !created {
+		problem.Write(w, r, http.StatusConflict, "You have already reported this "+report.TargetType)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
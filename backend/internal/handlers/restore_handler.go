@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"net/http"
+	"strconv"
+)
+
+// HandleRestorePost restores one of the user's deleted posts before it is purged
+func (a *App) HandleRestorePost(w http.ResponseWriter, r *http.Request) {
+	a.restoreContent(w, r, models.TargetPost, a.Services.Posts.Restore, models.AuditPostRestored)
+}
+
+// HandleRestoreComment restores one of the user's deleted comments before it is purged
+func (a *App) HandleRestoreComment(w http.ResponseWriter, r *http.Request) {
+	a.restoreContent(w, r, models.TargetComment, a.Services.Comments.Restore, models.AuditCommentRestored)
+}
+
+// restoreContent restores a deleted post or comment on behalf of its owner or an admin
+func (a *App) restoreContent(w http.ResponseWriter, r *http.Request, targetType string, restore func(context.Context, int) error, auditAction string) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	err = restore(r.Context(), id)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	a.recordAuditByUser(r, auditAction, targetType, id, "")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"instagram/internal/middleware"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"net/http"
+	"strconv"
+)
+
+// HandleGetSessions lists the devices the authenticated user is currently logged in on
+func (a *App) HandleGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	sessions, err := repositories.GetActiveSessionsForUser(r.Context(), a.DB, userID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	// Mark the session making this request so clients can label "this device"
+	currentSessionID, _ := middleware.GetSessionIDFromContext(r.Context())
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(sessions)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+}
+
+// HandleDeleteSession revokes one of the authenticated user's sessions, logging that device out
+func (a *App) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		problem.Write(w, r, http.StatusUnauthorized, "User not found")
+		return
+	}
+
+	err = repositories.RevokeSession(r.Context(), a.DB, userID, sessionID)
+	if err != nil {
+		problem.Error(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
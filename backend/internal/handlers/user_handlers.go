@@ -1,72 +1,46 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"instagram/internal/middleware"
 	"instagram/internal/models"
-	"instagram/internal/repositories"
-	"instagram/internal/utils"
+	"instagram/internal/problem"
 	"net/http"
 	"strconv"
 )
 
-func HandlePostUser(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
+func (a *App) HandlePostUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &user) {
 		return
 	}
 
-	if user.Username == "" || user.Email == "" || user.Password == "" {
-		http.Error(w, "Username, Email, and Password are required", http.StatusBadRequest)
-		return
-	}
-
-	// Set the Hashed Password
-	user.PasswordHash, err = utils.HashPassword(user.Password)
-	user.Password = "" // Clear the password from memory so it's never accidentally exposed
-
-	savedUser, err := repositories.SaveUser(db, &user)
+	savedUser, err := a.Services.Users.Create(r.Context(), &user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
 	savedUser.PasswordHash = "" // Clear the password hash from the response
 
-	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(w).Encode(savedUser)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleGetUserById(w http.ResponseWriter, r *http.Request) {
+func (a *App) HandleGetUserById(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
-	user, err := repositories.GetUserByID(db, id)
+	user, err := a.Services.Users.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
@@ -74,67 +48,72 @@ func HandleGetUserById(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	err = json.NewEncoder(w).Encode(user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 }
 
-func HandleDeleteUserById(w http.ResponseWriter, r *http.Request) {
+func (a *App) HandleDeleteUserById(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
 		return
 	}
 
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
-		return
-	}
-
-	err = repositories.DeleteUserByID(db, id)
+	err = a.Services.Users.Delete(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
 
+	a.recordAuditByUser(r, models.AuditUserDeleted, models.TargetUser, id, "")
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func HandlePatchUser(w http.ResponseWriter, r *http.Request) {
-	db, ok := r.Context().Value(middleware.DBContextKey).(*sql.DB)
-	if !ok {
-		http.Error(w, "Database not found", http.StatusInternalServerError)
+func (a *App) HandlePatchUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if !decodeJSON(w, r, &user) {
 		return
 	}
 
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	updatedUser, previousUser, err := a.Services.Users.Update(r.Context(), &user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Error(w, r, err)
 		return
 	}
 
-	if user.Username == "" && user.Email == "" && user.Password == "" {
-		http.Error(w, "At least one field is required", http.StatusBadRequest)
-		return
+	// The addresses are personal data and are left out of the append-only log
+	if updatedUser.Email != previousUser.Email {
+		a.recordAuditByUser(r, models.AuditEmailChanged, models.TargetUser, user.ID, "email changed")
 	}
 
-	user.Password = "" // Password cannot be updated via PATCH
+	updatedUser.PasswordHash = "" // Clear the password hash from the response
 
-	updatedUser, err := repositories.UpdateUser(db, &user)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(updatedUser)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
+}
 
-	updatedUser.PasswordHash = "" // Clear the password hash from the response
+// HandleDeactivateUser hides the authenticated user's profile and content and logs them out everywhere.
+// Logging in again reactivates the account.
+func (a *App) HandleDeactivateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(updatedUser)
+	err = a.Services.Users.Deactivate(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Error(w, r, err)
 		return
 	}
+
+	a.recordAuditByUser(r, models.AuditUserDeactivated, models.TargetUser, id, "")
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,220 @@
+// Package importer reads account history exported from other services
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxJSONFileSize bounds the JSON documents read from an archive
+const maxJSONFileSize = 64 << 20
+
+// InstagramPost is a post found in an Instagram archive. Media holds the paths of its files within the archive.
+type InstagramPost struct {
+	Caption   string
+	CreatedAt time.Time
+	Media     []string
+}
+
+// InstagramArchive is the content of an Instagram "Download your information" archive in JSON format
+type InstagramArchive struct {
+	Posts     []InstagramPost
+	Following []string // Following holds usernames
+
+	files map[string]*zip.File
+}
+
+// Open opens a file of the archive, e.g. the media of a post
+func (a *InstagramArchive) Open(name string) (io.ReadCloser, error) {
+	file, ok := a.files[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the archive", name)
+	}
+	return file.Open()
+}
+
+// Size returns the uncompressed size of a file of the archive as recorded in the archive, reading the file
+// fails if the content turns out to be larger
+func (a *InstagramArchive) Size(name string) (uint64, error) {
+	file, ok := a.files[path.Clean(name)]
+	if !ok {
+		return 0, fmt.Errorf("%s is not in the archive", name)
+	}
+	return file.UncompressedSize64, nil
+}
+
+type instagramMedia struct {
+	URI               string `json:"uri"`
+	Title             string `json:"title"`
+	CreationTimestamp int64  `json:"creation_timestamp"`
+}
+
+type instagramPostEntry struct {
+	Title             string           `json:"title"`
+	CreationTimestamp int64            `json:"creation_timestamp"`
+	Media             []instagramMedia `json:"media"`
+}
+
+type instagramRelationship struct {
+	Title          string `json:"title"`
+	StringListData []struct {
+		Href  string `json:"href"`
+		Value string `json:"value"`
+	} `json:"string_list_data"`
+}
+
+// ParseInstagramArchive reads the posts and followed accounts of an Instagram archive. Both the current
+// layout (your_instagram_activity/content/...) and the older one (content/...) are understood, as are
+// archives that were unpacked and zipped again inside a top level folder.
+func ParseInstagramArchive(r *zip.Reader) (*InstagramArchive, error) {
+	archive := &InstagramArchive{files: map[string]*zip.File{}}
+
+	// Strip a common top level folder so paths match the media URIs in the JSON files
+	prefix := commonPrefix(r.File)
+	for _, file := range r.File {
+		if !file.FileInfo().IsDir() {
+			archive.files[path.Clean(strings.TrimPrefix(file.Name, prefix))] = file
+		}
+	}
+
+	var postFiles, followingFiles []string
+	for name := range archive.files {
+		base := path.Base(name)
+		switch {
+		case path.Base(path.Dir(name)) == "content" && strings.HasPrefix(base, "posts_") && path.Ext(base) == ".json":
+			postFiles = append(postFiles, name)
+		case path.Base(path.Dir(name)) == "followers_and_following" && base == "following.json":
+			followingFiles = append(followingFiles, name)
+		}
+	}
+	sort.Strings(postFiles)
+
+	if len(postFiles) == 0 && len(followingFiles) == 0 {
+		return nil, fmt.Errorf("no posts or follows found, is this an Instagram archive in JSON format?")
+	}
+
+	for _, name := range postFiles {
+		var entries []instagramPostEntry
+		if err := archive.decode(name, &entries); err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			archive.Posts = append(archive.Posts, entry.post())
+		}
+	}
+
+	for _, name := range followingFiles {
+		var following struct {
+			Relationships []instagramRelationship `json:"relationships_following"`
+		}
+		if err := archive.decode(name, &following); err != nil {
+			return nil, err
+		}
+		for _, relationship := range following.Relationships {
+			if username := relationship.username(); username != "" {
+				archive.Following = append(archive.Following, username)
+			}
+		}
+	}
+
+	return archive, nil
+}
+
+func (a *InstagramArchive) decode(name string, v interface{}) error {
+	file, err := a.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(io.LimitReader(file, maxJSONFileSize)).Decode(v); err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return nil
+}
+
+// post converts an archive entry. Posts with a single photo keep their caption and time on the media item,
+// posts with several on the entry itself.
+func (e instagramPostEntry) post() InstagramPost {
+	post := InstagramPost{Caption: fixEncoding(e.Title)}
+	timestamp := e.CreationTimestamp
+
+	for _, media := range e.Media {
+		post.Media = append(post.Media, media.URI)
+		if post.Caption == "" {
+			post.Caption = fixEncoding(media.Title)
+		}
+		if timestamp == 0 {
+			timestamp = media.CreationTimestamp
+		}
+	}
+
+	if timestamp != 0 {
+		post.CreatedAt = time.Unix(timestamp, 0).UTC()
+	}
+	return post
+}
+
+// username returns the followed account's username. Older archives put it in the value of the string
+// list, newer ones in the title.
+func (r instagramRelationship) username() string {
+	for _, data := range r.StringListData {
+		if data.Value != "" {
+			return data.Value
+		}
+	}
+	if r.Title != "" {
+		return r.Title
+	}
+	for _, data := range r.StringListData {
+		if username := path.Base(strings.TrimSuffix(data.Href, "/")); username != "." && username != "/" {
+			return username
+		}
+	}
+	return ""
+}
+
+// fixEncoding repairs Instagram's text encoding. The archives escape each byte of the UTF-8 encoding
+// as its own code point, so "é" arrives as "Ã©".
+func fixEncoding(s string) string {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xff {
+			return s
+		}
+		b = append(b, byte(r))
+	}
+	if !utf8.Valid(b) {
+		return s
+	}
+	return string(b)
+}
+
+// commonPrefix returns the top level folder shared by every file in the archive, if there is one
+func commonPrefix(files []*zip.File) string {
+	prefix := ""
+	for i, file := range files {
+		first, _, found := strings.Cut(file.Name, "/")
+		if !found {
+			return ""
+		}
+		if i == 0 {
+			prefix = first + "/"
+		} else if prefix != first+"/" {
+			return ""
+		}
+	}
+
+	// A top level folder that is part of the archive layout itself must be kept
+	if prefix == "content/" || prefix == "connections/" || prefix == "your_instagram_activity/" {
+		return ""
+	}
+	return prefix
+}
@@ -0,0 +1,212 @@
+package jobs
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/storage"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Exporter builds the ZIP archives of data export jobs in the background
+type Exporter struct {
+	db        *sql.DB
+	media     *storage.Local
+	dir       string
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewExporter creates an exporter writing archives to dir
+func NewExporter(db *sql.DB, media *storage.Local, dir string, retention time.Duration) (*Exporter, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	return &Exporter{db: db, media: media, dir: dir, retention: retention, now: time.Now}, nil
+}
+
+// SetClock replaces the exporter's clock, for tests
+func (e *Exporter) SetClock(now func() time.Time) {
+	e.now = now
+}
+
+// RunOnce builds every pending export and deletes expired archives
+func (e *Exporter) RunOnce(ctx context.Context) error {
+	// Exports not started yet wait for the next run when shutting down
+	for ctx.Err() == nil {
+		export, err := repositories.ClaimNextDataExport(ctx, e.db)
+		if err != nil {
+			return err
+		}
+		if export == nil {
+			break
+		}
+
+		filePath, err := e.build(ctx, export)
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to build data export", "export_id", export.ID, "error", err)
+			if err := repositories.FailDataExport(ctx, e.db, export.ID, "the export could not be created"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := repositories.CompleteDataExport(ctx, e.db, export.ID, filePath, e.now().Add(e.retention)); err != nil {
+			os.Remove(filePath)
+			return err
+		}
+	}
+
+	expired, err := repositories.GetExpiredDataExports(ctx, e.db, e.now())
+	if err != nil {
+		return err
+	}
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			logging.FromContext(ctx).Error("Failed to delete expired data export", "export_id", export.ID, "error", err)
+			continue
+		}
+		if err := repositories.ExpireDataExport(ctx, e.db, export.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run processes exports every interval until ctx is cancelled
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	if err := repositories.RequeueRunningDataExports(ctx, e.db); err != nil {
+		logging.FromContext(ctx).Error("Failed to requeue data exports", "error", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.RunOnce(ctx); err != nil {
+			logging.FromContext(ctx).Error("Failed to process data exports", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// build writes the archive for an export and returns its path. Media is stored under media/ in the
+// archive and the exported posts point to their file there.
+func (e *Exporter) build(ctx context.Context, export *models.DataExport) (string, error) {
+	data, err := repositories.GetExportData(ctx, e.db, export.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	filePath := filepath.Join(e.dir, fmt.Sprintf("export-%d-%d.zip", export.UserID, export.ID))
+	file, err := os.Create(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	err = e.writeArchive(file, data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+
+	return filePath, nil
+}
+
+func (e *Exporter) writeArchive(w io.Writer, data *models.ExportData) error {
+	archive := zip.NewWriter(w)
+
+	if data.Profile.ProfileImage != "" {
+		archivePath, err := e.copyMedia(archive, data.Profile.ProfileImage)
+		if err != nil {
+			return err
+		}
+		data.Profile.ProfileImage = archivePath
+	}
+
+	for i, post := range data.Posts {
+		archivePath, err := e.copyMedia(archive, post.ImageURL)
+		if err != nil {
+			return err
+		}
+		data.Posts[i].ImageURL = archivePath
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"posts.json", nonNil(data.Posts)},
+		{"comments.json", nonNil(data.Comments)},
+		{"likes.json", nonNil(data.Likes)},
+		{"following.json", nonNil(data.Following)},
+		{"followers.json", nonNil(data.Followers)},
+	}
+	for _, f := range files {
+		entry, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.content); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// copyMedia adds a locally stored file to the archive and returns its path there.
+// Media hosted elsewhere is not copied and keeps its URL.
+func (e *Exporter) copyMedia(archive *zip.Writer, url string) (string, error) {
+	if !e.media.Owns(url) {
+		return url, nil
+	}
+
+	source, err := e.media.Open(url)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return url, nil
+		}
+		return "", err
+	}
+	defer source.Close()
+
+	archivePath := "media/" + path.Base(url)
+	entry, err := archive.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(entry, source); err != nil {
+		return "", err
+	}
+	return archivePath, nil
+}
+
+// nonNil makes empty lists encode as [] rather than null
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
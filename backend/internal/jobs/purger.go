@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"instagram/internal/logging"
+	"instagram/internal/repositories"
+	"instagram/internal/storage"
+	"os"
+	"time"
+)
+
+// Purger permanently removes soft deleted users, posts and comments, their stored media and the data
+// exports of the users, once their grace period has passed. It also removes expired sessions and the
+// records of used MFA challenges that expired.
+type Purger struct {
+	db          *sql.DB
+	media       *storage.Local
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+func NewPurger(db *sql.DB, media *storage.Local, gracePeriod time.Duration) *Purger {
+	return &Purger{db: db, media: media, gracePeriod: gracePeriod, now: time.Now}
+}
+
+// SetClock replaces the purger's clock, for tests
+func (p *Purger) SetClock(now func() time.Time) {
+	p.now = now
+}
+
+// PurgeOnce removes everything deleted more than the grace period ago, and the sessions that expired
+func (p *Purger) PurgeOnce(ctx context.Context) error {
+	sessions, err := repositories.DeleteExpiredSessions(ctx, p.db, p.now())
+	if err != nil {
+		return err
+	}
+	if sessions > 0 {
+		logging.FromContext(ctx).Info("Removed expired sessions", "sessions", sessions)
+	}
+	if _, err := repositories.DeleteExpiredMFAChallenges(ctx, p.db, p.now()); err != nil {
+		return err
+	}
+
+	result, err := repositories.PurgeDeletedBefore(ctx, p.db, p.now().Add(-p.gracePeriod))
+	if err != nil {
+		return err
+	}
+
+	// The rows are gone, so a file that fails to delete is only logged and never retried.
+	// Leaving an orphaned file behind is preferable to restoring the rows.
+	for _, url := range result.MediaURLs {
+		if err := p.media.Delete(url); err != nil {
+			logging.FromContext(ctx).Error("Failed to delete purged media", "url", url, "error", err)
+		}
+	}
+	for _, path := range result.ExportFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logging.FromContext(ctx).Error("Failed to delete purged data export", "path", path, "error", err)
+		}
+	}
+
+	if result.Users > 0 || result.Posts > 0 || result.Comments > 0 {
+		logging.FromContext(ctx).Info("Purged deleted data", "users", result.Users, "posts", result.Posts, "comments", result.Comments)
+	}
+	return nil
+}
+
+// Run purges every interval until ctx is cancelled
+func (p *Purger) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.PurgeOnce(ctx); err != nil {
+			logging.FromContext(ctx).Error("Failed to purge deleted data", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Package logging provides the structured logger and carries request-scoped loggers in contexts
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// loggerContextKey is the context key of request-scoped loggers, use NewContext and FromContext
+type loggerContextKey struct{}
+
+// New returns a logger writing records in the given format ("json" or "text") at the given level
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error"
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// NewContext returns a copy of ctx carrying the logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger. Loggers of requests include
+// the request ID and, once authenticated, the user ID.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger has the given attributes added
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
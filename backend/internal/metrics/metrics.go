@@ -0,0 +1,102 @@
+// Package metrics defines the Prometheus metrics of the server. The collectors are package level so
+// handlers can count events directly, they are exposed through a registry created with NewRegistry.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "instagram"
+
+// Sources of created content
+const (
+	SourceAPI    = "api"
+	SourceImport = "import"
+)
+
+// Signup methods
+const (
+	SignupPassword = "password"
+	SignupOIDC     = "oidc"
+)
+
+// Reasons for failed logins
+const (
+	LoginUnknownEmail        = "unknown_email"
+	LoginInvalidPassword     = "invalid_password"
+	LoginInvalidSecondFactor = "invalid_second_factor"
+)
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	Signups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signups_total",
+		Help:      "Users created, by signup method.",
+	}, []string{"method"})
+
+	PostsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Posts created, by source.",
+	}, []string{"source"})
+
+	Follows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "follows_total",
+		Help:      "Follows created, by source.",
+	}, []string{"source"})
+
+	CommentsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Comments created.",
+	})
+
+	FailedLogins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "failed_logins_total",
+		Help:      "Failed login attempts, by reason.",
+	}, []string{"reason"})
+)
+
+// NewRegistry returns a registry with the server's metrics, the connection pool stats of db and the
+// Go runtime and process metrics
+func NewRegistry(db *sql.DB) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "instagram"),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Signups,
+		PostsCreated,
+		Follows,
+		CommentsCreated,
+		FailedLogins,
+	)
+	return registry
+}
+
+// Handler serves the metrics of the registry in the Prometheus text format
+func Handler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
@@ -2,16 +2,18 @@ package middleware
 
 import (
 	"github.com/rs/cors"
+	"instagram/internal/config"
 	"net/http"
 )
 
-func CORSMiddleware(next http.Handler) http.Handler {
-	// Create a new CORS handler with permissive settings
+func CORSMiddleware(next http.Handler, cfg config.CORSConfig) http.Handler {
+	// Create a new CORS handler with the configured origins
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Allow all origins
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
-		AllowCredentials: true, // Allow credentials (e.g., cookies, authorization headers)
+		AllowedHeaders:   []string{"Authorization", "Content-Type", RequestIDHeader},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", RequestIDHeader},
+		AllowCredentials: cfg.AllowCredentials, // Allow credentials (e.g., cookies), never together with the * origin
 	})
 
 	return c.Handler(next) // Apply the CORS middleware to the next handler
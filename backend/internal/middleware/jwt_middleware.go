@@ -2,46 +2,216 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
+	"instagram/internal/logging"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
 	"instagram/internal/utils"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
-// JWTMiddleware verifies the JWT token and allows the request to proceed if valid
-func JWTMiddleware(next http.Handler) http.Handler {
+// Context keys of what the authentication middleware learns about a request. They are unexported so no
+// other package can set them by accident, use the With* and Get*FromContext functions.
+type (
+	userIDContextKey    struct{}
+	sessionIDContextKey struct{}
+	scopesContextKey    struct{}
+)
+
+// JWTMiddleware verifies the JWT token and allows the request to proceed if valid.
+// Personal access tokens are accepted in the same Authorization header.
+func JWTMiddleware(next http.Handler, db *sql.DB, jwtSecret string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Extract the token from the Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+			problem.Write(w, r, http.StatusUnauthorized, "Authorization header is required")
 			return
 		}
 
 		// Split the header to get the token part
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			http.Error(w, "Invalid Authorization header format", http.StatusUnauthorized)
+			problem.Write(w, r, http.StatusUnauthorized, "Invalid Authorization header format")
 			return
 		}
 		tokenString := tokenParts[1]
 
-		// Verify the token
-		token, err := utils.VerifyJWT(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+		ctx, err := Authenticate(r.Context(), db, jwtSecret, tokenString)
+		var authErr *AuthError
+		switch {
+		case errors.As(err, &authErr):
+			problem.Write(w, r, authErr.Status, authErr.Message)
+		case err != nil:
+			problem.Error(w, r, err)
+		default:
+			// Proceed to the next handler with the modified context
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+	})
+}
+
+// AuthError is a rejected credential, with the HTTP status and message it is reported with
+type AuthError struct {
+	Status  int
+	Message string
+}
+
+func (e *AuthError) Error() string {
+	return e.Message
+}
+
+// Authenticate verifies a session JWT or a personal access token, for the HTTP API and other transports
+// such as gRPC. It returns ctx with the session ID or the token's scopes, and the ID and role of the
+// user. Rejected credentials are reported as *AuthError, like a nil db once a token needs to be looked up.
+func Authenticate(ctx context.Context, db *sql.DB, jwtSecret, tokenString string) (context.Context, error) {
+	errNoDB := &AuthError{http.StatusInternalServerError, "Database not found"}
+
+	if utils.IsPersonalAccessToken(tokenString) {
+		if db == nil {
+			return nil, errNoDB
+		}
+		return authenticatePersonalAccessToken(ctx, db, tokenString)
+	}
+
+	// Verify the token
+	token, err := utils.VerifyJWT([]byte(jwtSecret), tokenString)
+	if err != nil {
+		return nil, &AuthError{http.StatusUnauthorized, "Invalid token: " + err.Error()}
+	}
+
+	// Extract the claims (e.g., userID) from the token
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, &AuthError{http.StatusUnauthorized, "Invalid token claims"}
+	}
+
+	// MFA challenge tokens only prove the password step and cannot be used as a session
+	if _, hasPurpose := claims["purpose"]; hasPurpose {
+		return nil, &AuthError{http.StatusUnauthorized, "Invalid token purpose"}
+	}
+
+	// The session the token was issued for must still be active
+	if db == nil {
+		return nil, errNoDB
+	}
+	userID, hasUser := claims["user_id"].(float64)
+	sessionID, hasSession := claims["sid"].(float64)
+	if !hasUser || !hasSession {
+		return nil, &AuthError{http.StatusUnauthorized, "Invalid token claims"}
+	}
+
+	session, err := repositories.GetSession(ctx, db, int(sessionID))
+	if err != nil || !session.Active() || session.UserID != int(userID) {
+		return nil, &AuthError{http.StatusUnauthorized, "Session has been revoked"}
+	}
+
+	// Most requests of an active session don't need to write anything
+	if time.Since(session.LastSeenAt) >= repositories.SessionTouchInterval {
+		if err := repositories.TouchSession(ctx, db, session.ID); err != nil {
+			logging.FromContext(ctx).Warn("Failed to update session", "session_id", session.ID, "error", err)
+		}
+	}
+
+	// Add the session ID to the context, the user is added once their account is checked
+	return authenticated(WithSessionID(ctx, session.ID), db, int(userID))
+}
+
+// authenticatePersonalAccessToken looks up a personal access token and proceeds with its user and scopes
+func authenticatePersonalAccessToken(ctx context.Context, db *sql.DB, tokenString string) (context.Context, error) {
+	token, err := repositories.GetPersonalAccessTokenByHash(ctx, db, utils.HashPersonalAccessToken(tokenString))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.ActiveAt(time.Now()) {
+		return nil, &AuthError{http.StatusUnauthorized, "Invalid or revoked access token"}
+	}
+
+	if err := repositories.TouchPersonalAccessToken(ctx, db, token.ID); err != nil {
+		logging.FromContext(ctx).Warn("Failed to update access token", "token_id", token.ID, "error", err)
+	}
+
+	// Requests made with an access token are limited to the token's scopes
+	return authenticated(WithScopes(ctx, token.Scopes), db, token.UserID)
+}
+
+// authenticated loads the authenticated user, rejects suspended accounts and returns ctx with the
+// user's ID and role
+func authenticated(ctx context.Context, db *sql.DB, userID int) (context.Context, error) {
+	user, err := repositories.GetUserByID(ctx, db, userID)
+	if err != nil {
+		return nil, &AuthError{http.StatusUnauthorized, "User not found"}
+	}
+
+	if user.SuspendedAtTime(time.Now()) {
+		return nil, &AuthError{http.StatusForbidden, "Account suspended"}
+	}
+
+	return withLoggedUser(WithUser(ctx, user.ID, user.Role), user.ID), nil
+}
+
+// RequireScope rejects requests made with a personal access token that was not granted scope.
+// Requests authenticated with a session JWT have every scope.
+func RequireScope(next http.Handler, scope string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if scopes, ok := GetScopesFromContext(r.Context()); ok && !slices.Contains(scopes, scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			problem.Write(w, r, http.StatusForbidden, "Access token is missing the "+scope+" scope")
 			return
 		}
+		next.ServeHTTP(w, r)
+	})
+}
 
-		// Extract the claims (e.g., userID) from the token if necessary
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Add the user ID from the claims to the request context
-			userID := int(claims["user_id"].(float64))
-			ctx := context.WithValue(r.Context(), "user_id", userID)
-			// Proceed to the next handler with the modified context
-			next.ServeHTTP(w, r.WithContext(ctx))
-		} else {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+// RequireSession rejects requests made with a personal access token, for account management
+// routes that must only be reachable by a logged in user
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetSessionIDFromContext(r.Context()); !ok {
+			problem.Write(w, r, http.StatusForbidden, "This endpoint requires a logged in session")
+			return
 		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+// WithUser returns a copy of ctx authenticated as the user, who has the given role
+func WithUser(ctx context.Context, userID int, role string) context.Context {
+	ctx = context.WithValue(ctx, userIDContextKey{}, userID)
+	return context.WithValue(ctx, roleContextKey{}, role)
+}
+
+// WithSessionID returns a copy of ctx made by the given session of the user
+func WithSessionID(ctx context.Context, sessionID int) context.Context {
+	return context.WithValue(ctx, sessionIDContextKey{}, sessionID)
+}
+
+// WithScopes returns a copy of ctx made with a personal access token that was granted scopes
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesContextKey{}, scopes)
+}
+
+// GetUserIDFromContext Helper function to retrieve the authenticated user's ID from the context
+func GetUserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDContextKey{}).(int)
+	return userID, ok
+}
+
+// GetSessionIDFromContext Helper function to retrieve the ID of the session making the request
+func GetSessionIDFromContext(ctx context.Context) (int, bool) {
+	sessionID, ok := ctx.Value(sessionIDContextKey{}).(int)
+	return sessionID, ok
+}
+
+// GetScopesFromContext Helper function to retrieve the scopes of the access token making the request.
+// It returns false for requests authenticated with a session JWT, which are not limited by scopes.
+func GetScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesContextKey{}).([]string)
+	return scopes, ok
+}
@@ -1,21 +1,139 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"instagram/internal/logging"
+	"instagram/internal/tracing"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-// LoggingMiddleware logs the details of each request
-func LoggingMiddleware(next http.Handler) http.Handler {
+type requestInfoContextKey struct{}
+
+// requestInfo collects what inner handlers learn about a request for the access log and metrics: the
+// route pattern it matched and the authenticated user. Inner middleware works on copies of the request,
+// so they report back through this shared value in the context.
+type requestInfo struct {
+	route  string
+	userID int
+}
+
+// withRequestInfo returns the request info of ctx, adding one to the context if there is none yet
+func withRequestInfo(ctx context.Context) (context.Context, *requestInfo) {
+	if info, ok := requestInfoFromContext(ctx); ok {
+		return ctx, info
+	}
+	info := &requestInfo{}
+	return context.WithValue(ctx, requestInfoContextKey{}, info), info
+}
+
+// requestInfoFromContext returns the request info of ctx, requests outside of the logging middleware have none
+func requestInfoFromContext(ctx context.Context) (*requestInfo, bool) {
+	info, ok := ctx.Value(requestInfoContextKey{}).(*requestInfo)
+	return info, ok
+}
+
+// statusRecorder remembers the status code and size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to extend deadlines
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LoggingMiddleware makes a request-scoped logger carrying the request ID available through
+// logging.FromContext, and writes one access log record per request. It must run inside
+// RequestIDMiddleware.
+func LoggingMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("Request: %s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
 
-		// Pass request to the next handler
-		next.ServeHTTP(w, r)
+		requestLogger := logger
+		if requestID, ok := GetRequestIDFromContext(r.Context()); ok {
+			requestLogger = logger.With("request_id", requestID)
+		}
+		// Lets the logs of a request be found from its trace, and the other way around
+		if spanContext := trace.SpanContextFromContext(r.Context()); spanContext.IsValid() {
+			requestLogger = requestLogger.With("trace_id", spanContext.TraceID().String())
+		}
+
+		ctx, info := withRequestInfo(logging.NewContext(r.Context(), requestLogger))
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", info.route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", recorder.bytes),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if info.userID != 0 {
+			attrs = append(attrs, slog.Int("user_id", info.userID))
+		}
 
-		// Log the time taken to process the request
-		log.Printf("Completed in %s", time.Since(start))
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		requestLogger.LogAttrs(r.Context(), level, "request", attrs...)
 	})
 }
+
+// RecordRoute records the pattern of mux that matches the request for the access log, metrics and
+// traces. Wrapping nested routers records the most specific pattern, e.g. "GET /users/{id}" rather than
+// "/users/". Every matched pattern also gets its own span, so the innermost one times the handler.
+func RecordRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if info, ok := requestInfoFromContext(r.Context()); ok {
+			info.route = pattern
+		}
+		ctx, span := tracing.Tracer().Start(r.Context(), pattern)
+		defer span.End()
+		mux.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withLoggedUser adds the authenticated user to the access log record and the request-scoped logger
+func withLoggedUser(ctx context.Context, userID int) context.Context {
+	if info, ok := requestInfoFromContext(ctx); ok {
+		info.userID = userID
+	}
+	return logging.With(ctx, "user_id", userID)
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"instagram/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests no route matched, so arbitrary paths cannot create new series
+const unmatchedRoute = "unmatched"
+
+// metricMethods are the methods used as labels, others are counted as "OTHER"
+var metricMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// MetricsMiddleware counts requests and measures their latency per route pattern. Routes are recorded
+// by the routers wrapped with RecordRoute.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ctx, info := withRequestInfo(r.Context())
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := info.route
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := r.Method
+		if !metricMethods[method] {
+			method = "OTHER"
+		}
+
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	})
+}
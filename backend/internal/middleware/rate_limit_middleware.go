@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"fmt"
+	"instagram/internal/problem"
+	"instagram/internal/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitKey selects what a rate limit is counted against
+type RateLimitKey int
+
+const (
+	// KeyByIP counts requests per client IP address
+	KeyByIP RateLimitKey = iota
+	// KeyByUser counts requests per authenticated user, falling back to the IP for anonymous requests
+	KeyByUser
+)
+
+// RateLimitPolicy describes a token bucket: Limit requests are allowed in a burst,
+// and the bucket refills completely over Window.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    RateLimitKey
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter enforces a RateLimitPolicy with one token bucket per key
+type RateLimiter struct {
+	policy    RateLimitPolicy
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewRateLimiter(policy RateLimitPolicy) *RateLimiter {
+	return &RateLimiter{
+		policy:  policy,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the limiter's time source, for tests
+func (l *RateLimiter) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+// rateLimitResult is the outcome of taking a token from a bucket
+type rateLimitResult struct {
+	allowed   bool
+	remaining int
+	reset     time.Duration // time until the bucket is full again
+	retry     time.Duration // time until the next token is available when denied
+}
+
+// refillRate returns the number of tokens added per second
+func (l *RateLimiter) refillRate() float64 {
+	return float64(l.policy.Limit) / l.policy.Window.Seconds()
+}
+
+// take removes a token from the bucket for key if one is available
+func (l *RateLimiter) take(key string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.policy.Limit), updated: now}
+		l.buckets[key] = b
+	}
+
+	// Refill the bucket for the time that passed since it was last used
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(l.policy.Limit), b.tokens+elapsed*l.refillRate())
+	b.updated = now
+
+	result := rateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.allowed = true
+	} else {
+		result.retry = time.Duration((1 - b.tokens) / l.refillRate() * float64(time.Second))
+	}
+
+	result.remaining = int(b.tokens)
+	result.reset = time.Duration((float64(l.policy.Limit) - b.tokens) / l.refillRate() * float64(time.Second))
+	return result
+}
+
+// sweep drops buckets that have refilled completely, so idle clients don't use memory forever
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.policy.Window {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.policy.Window {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// keyFor builds the bucket key for a request according to the policy
+func (l *RateLimiter) keyFor(r *http.Request) string {
+	if l.policy.Key == KeyByUser {
+		if userID, ok := GetUserIDFromContext(r.Context()); ok {
+			return "user:" + strconv.Itoa(userID)
+		}
+	}
+	return "ip:" + utils.ClientIP(r)
+}
+
+// RateLimitMiddleware rejects requests with 429 Too Many Requests once any of the limiters is exhausted.
+// The RateLimit-* headers describe the most restrictive limiter.
+func RateLimitMiddleware(next http.Handler, limiters ...*RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var limiting *RateLimiter
+		var limitingResult rateLimitResult
+
+		for _, limiter := range limiters {
+			result := limiter.take(limiter.keyFor(r))
+			if limiting == nil || !result.allowed || (limitingResult.allowed && result.remaining < limitingResult.remaining) {
+				limiting, limitingResult = limiter, result
+			}
+			if !result.allowed {
+				break
+			}
+		}
+
+		if limiting != nil {
+			policy := limiting.policy
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(limitingResult.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(limitingResult.reset)))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;name=%q", policy.Limit, ceilSeconds(policy.Window), policy.Name))
+
+			if !limitingResult.allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(limitingResult.retry)))
+				problem.Write(w, r, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds rounds a duration up to whole seconds, as required by the RateLimit and Retry-After headers
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
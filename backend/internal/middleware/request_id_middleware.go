@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type requestIDContextKey struct{}
+
+// RequestIDHeader carries the ID of a request between clients, proxies and the server
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an ID, reusing the one sent by a proxy or client if it is
+// well formed. The ID is echoed in the response so a client can quote it when reporting a problem.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := RequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequestID returns the ID sent by a proxy or client if it is well formed, or a new ID otherwise
+func RequestID(sent string) string {
+	if !validRequestID(sent) {
+		return newRequestID()
+	}
+	return sent
+}
+
+// validRequestID only accepts short printable IDs, so client supplied values are safe to store and log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of the current request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// GetRequestIDFromContext Helper function to retrieve the ID of the current request
+func GetRequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDContextKey{}).(string)
+	return requestID, ok
+}
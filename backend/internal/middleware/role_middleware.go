@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"net/http"
+)
+
+// roleContextKey is the context key of the authenticated user's role, set by WithUser
+type roleContextKey struct{}
+
+// RequireRole only lets users through whose role grants at least the privileges of minimum.
+// It must be used behind JWTMiddleware.
+func RequireRole(next http.Handler, minimum string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, ok := GetRoleFromContext(r.Context())
+		if !ok || !models.RoleAtLeast(role, minimum) {
+			problem.Write(w, r, http.StatusForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetRoleFromContext Helper function to retrieve the authenticated user's role from the context
+func GetRoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleContextKey{}).(string)
+	return role, ok
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"fmt"
+	"instagram/internal/tracing"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware starts a server span for every request, continuing the trace of the caller if the
+// request carries a W3C traceparent header. The span is named after the route pattern recorded by
+// RecordRoute. It must be the outermost middleware so the others are part of the trace.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		ctx, info := withRequestInfo(ctx)
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if info.route != "" {
+			span.SetName(spanName(r.Method, info.route))
+			span.SetAttributes(semconv.HTTPRoute(info.route))
+		}
+		if info.userID != 0 {
+			span.SetAttributes(semconv.EnduserID(strconv.Itoa(info.userID)))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	})
+}
+
+// Traced records the time spent in a middleware, including the handlers it calls, as a span named name
+func Traced(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(r.Context(), name)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// spanName names a server span "{method} {route}" as the semantic conventions ask. Patterns that
+// already start with a method are used as is.
+func spanName(method, route string) string {
+	if strings.HasPrefix(route, "/") {
+		return method + " " + route
+	}
+	return route
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Audited actions. Moderation actions are recorded with AuditAdminPrefix followed by the moderation action.
+const (
+	AuditLogin           = "auth.login"
+	AuditLoginFailed     = "auth.login_failed"
+	AuditPasswordChanged = "user.password_changed"
+	AuditEmailChanged    = "user.email_changed"
+	AuditIdentityLinked  = "user.identity_linked"
+	AuditUserDeleted     = "user.deleted"
+	AuditUserRestored    = "user.restored"
+	AuditUserDeactivated = "user.deactivated"
+	AuditUserReactivated = "user.reactivated"
+	AuditDataExported    = "user.data_exported"
+	AuditDataImported    = "user.data_imported"
+	AuditPostDeleted     = "post.deleted"
+	AuditPostRestored    = "post.restored"
+	AuditCommentDeleted  = "comment.deleted"
+	AuditCommentRestored = "comment.restored"
+	AuditAdminPrefix     = "admin."
+)
+
+// AuditEvent is an entry in the append-only audit log. ActorID is nil when nobody is
+// authenticated, e.g. for failed logins, and TargetID is nil for actions without a target.
+type AuditEvent struct {
+	ID         int       `json:"id" db:"id"`
+	Action     string    `json:"action" db:"action"`
+	ActorID    *int      `json:"actor_id,omitempty" db:"actor_id"`
+	TargetType string    `json:"target_type,omitempty" db:"target_type"`
+	TargetID   *int      `json:"target_id,omitempty" db:"target_id"`
+	IPAddress  string    `json:"ip_address" db:"ip_address"`
+	RequestID  string    `json:"request_id" db:"request_id"`
+	Details    string    `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter selects audit events, zero values match everything
+type AuditFilter struct {
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   int
+	RequestID  string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
@@ -4,8 +4,10 @@ import "time"
 
 type Comment struct {
 	ID        int       `json:"id" db:"id"`
-	PostID    int       `json:"post_id" db:"post_id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	Content   string    `json:"content" db:"content"`
+	PostID    int       `json:"post_id" db:"post_id" validate:"required,gt=0"`
+	UserID    int       `json:"user_id" db:"user_id" validate:"required,gt=0"`
+	Content   string    `json:"content" db:"content" validate:"required,max=2200"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// Hidden is set when only the author and admins may see the comment, e.g. after a moderator hid it
+	Hidden bool `json:"-" db:"-"`
 }
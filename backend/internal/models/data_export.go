@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Statuses of a data export job
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired"
+)
+
+// DataExport is a job that packages everything stored about a user into a ZIP archive
+type DataExport struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Status      string     `json:"status" db:"status"`
+	FilePath    string     `json:"-" db:"file_path"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"` // ExpiresAt is when the archive is deleted
+	DownloadURL string     `json:"download_url,omitempty" db:"-"`        // DownloadURL is a short-lived link, only set on completed exports
+}
+
+// ExportData is everything about a user that goes into a data export, apart from media files
+type ExportData struct {
+	Profile   User      `json:"profile"`
+	Posts     []Post    `json:"posts"`
+	Comments  []Comment `json:"comments"`
+	Likes     []Like    `json:"likes"`
+	Following []Follow  `json:"following"`
+	Followers []Follow  `json:"followers"`
+}
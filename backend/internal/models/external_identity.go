@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type ExternalIdentity struct {
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// OIDCLoginState is the per-login secret state kept between redirecting to the provider and the callback
+type OIDCLoginState struct {
+	State        string    `json:"-" db:"state"`
+	Provider     string    `json:"-" db:"provider"`
+	Nonce        string    `json:"-" db:"nonce"`
+	CodeVerifier string    `json:"-" db:"code_verifier"`
+	CreatedAt    time.Time `json:"-" db:"created_at"`
+}
+
+// OIDCLinkRequired is the result of an external login whose verified email belongs to an existing account.
+// LinkToken is exchanged for a session together with the account's password, which links the identity.
+type OIDCLinkRequired struct {
+	LinkRequired bool      `json:"link_required"`
+	LinkToken    string    `json:"link_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// OIDCLink confirms linking an external identity to an existing account
+type OIDCLink struct {
+	LinkToken string `json:"link_token"`
+	Password  string `json:"password"`
+}
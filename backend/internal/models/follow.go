@@ -3,7 +3,7 @@ package models
 import "time"
 
 type Follow struct {
-	FollowerID  int       `json:"follower_id" db:"follower_id"`
-	FollowingID int       `json:"following_id" db:"following_id"`
+	FollowerID  int       `json:"follower_id" db:"follower_id" validate:"required,gt=0"`
+	FollowingID int       `json:"following_id" db:"following_id" validate:"required,gt=0"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
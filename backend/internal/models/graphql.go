@@ -0,0 +1,9 @@
+package models
+
+// GraphQLRequest is the body of a GraphQL request
+type GraphQLRequest struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"` // Extensions are accepted for client compatibility and ignored
+}
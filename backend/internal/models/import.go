@@ -0,0 +1,48 @@
+package models
+
+const (
+	ImportSourceInstagram = "instagram"
+
+	ImportTypePost   = "post"
+	ImportTypeFollow = "follow"
+
+	ImportStatusImported  = "imported"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusSkipped   = "skipped"
+	ImportStatusFailed    = "failed"
+)
+
+// ImportItem reports what happened to one post or follow of an imported archive. Source identifies the
+// item within the archive, ID is the post or followed user it became.
+type ImportItem struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	ID     int    `json:"id,omitempty"`
+}
+
+// ImportSummary is the result of an import, with counts per status and an entry per item
+type ImportSummary struct {
+	Source    string       `json:"source"`
+	Imported  int          `json:"imported"`
+	Duplicate int          `json:"duplicate"`
+	Skipped   int          `json:"skipped"`
+	Failed    int          `json:"failed"`
+	Items     []ImportItem `json:"items"`
+}
+
+// Add records an item and counts it
+func (s *ImportSummary) Add(item ImportItem) {
+	switch item.Status {
+	case ImportStatusImported:
+		s.Imported++
+	case ImportStatusDuplicate:
+		s.Duplicate++
+	case ImportStatusSkipped:
+		s.Skipped++
+	case ImportStatusFailed:
+		s.Failed++
+	}
+	s.Items = append(s.Items, item)
+}
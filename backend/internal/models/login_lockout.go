@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type LoginLockout struct {
+	UserID         int        `json:"user_id" db:"user_id"`
+	FailedAttempts int        `json:"failed_attempts" db:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	LastFailedAt   *time.Time `json:"last_failed_at,omitempty" db:"last_failed_at"`
+}
+
+// LockedAt reports whether the account is locked at the given time
+func (l *LoginLockout) LockedAt(t time.Time) bool {
+	return l != nil && l.LockedUntil != nil && t.Before(*l.LockedUntil)
+}
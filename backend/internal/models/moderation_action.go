@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Moderation actions
+const (
+	ActionSuspendUser    = "suspend_user"
+	ActionUnsuspendUser  = "unsuspend_user"
+	ActionChangeRole     = "change_role"
+	ActionRemovePost     = "remove_post"
+	ActionRemoveComment  = "remove_comment"
+	ActionRestoreUser    = "restore_user"
+	ActionHideContent    = "hide_content"
+	ActionDismissReports = "dismiss_reports"
+	ActionReopenReports  = "reopen_reports"
+)
+
+// Targets of moderation actions
+const (
+	TargetUser    = "user"
+	TargetPost    = "post"
+	TargetComment = "comment"
+)
+
+type ModerationAction struct {
+	ID          int       `json:"id" db:"id"`
+	ModeratorID int       `json:"moderator_id" db:"moderator_id"` // ModeratorID is 0 once the moderator's account has been purged
+	Action      string    `json:"action" db:"action"`
+	TargetType  string    `json:"target_type" db:"target_type"`
+	TargetID    int       `json:"target_id" db:"target_id"`
+	Reason      string    `json:"reason" db:"reason"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// ModerationRequest is the body of moderation endpoints
+type ModerationRequest struct {
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours,omitempty"` // DurationHours limits a suspension, 0 suspends indefinitely
+	Role          string `json:"role,omitempty"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// Page selects a slice of a list endpoint. A zero Limit selects everything from Offset on.
+type Page struct {
+	Limit  int
+	Offset int
+}
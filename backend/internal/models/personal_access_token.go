@@ -0,0 +1,52 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Scopes that can be granted to personal access tokens
+const (
+	ScopeUsersRead     = "users:read"
+	ScopeUsersWrite    = "users:write"
+	ScopePostsRead     = "posts:read"
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsRead  = "comments:read"
+	ScopeCommentsWrite = "comments:write"
+	ScopeFollowsWrite  = "follows:write"
+)
+
+// Scopes lists every scope a personal access token may be granted
+var Scopes = []string{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopePostsRead,
+	ScopePostsWrite,
+	ScopeCommentsRead,
+	ScopeCommentsWrite,
+	ScopeFollowsWrite,
+}
+
+// IsValidScope reports whether scope is a known scope
+func IsValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+type PersonalAccessToken struct {
+	ID            int        `json:"id" db:"id"`
+	UserID        int        `json:"user_id" db:"user_id"`
+	Name          string     `json:"name" db:"name"`
+	Scopes        []string   `json:"scopes" db:"scopes"`
+	Token         string     `json:"token,omitempty" db:"-"`           // Token is only returned once, when it is created
+	TokenHash     string     `json:"-" db:"token_hash"`                // TokenHash is stored in DB but not exposed in JSON
+	ExpiresInDays int        `json:"expires_in_days,omitempty" db:"-"` // ExpiresInDays is only used when creating a token
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// ActiveAt reports whether the token can be used to authenticate at the given time
+func (t *PersonalAccessToken) ActiveAt(now time.Time) bool {
+	return t != nil && t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
@@ -5,9 +5,11 @@ import "time"
 type Post struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
-	ImageURL  string    `json:"image_url" db:"image_url"`
-	Caption   string    `json:"caption,omitempty" db:"caption"`
+	ImageURL  string    `json:"image_url" db:"image_url" validate:"required,max=2048,mediaurl"`
+	Caption   string    `json:"caption,omitempty" db:"caption" validate:"max=2200"`
 	CreatedAt time.Time `json:"post_created_at" db:"created_at"` //
+	// Hidden is set when only the author and admins may see the post, e.g. after a moderator hid it
+	Hidden bool `json:"-" db:"-"`
 }
 
 type FeedPost struct {
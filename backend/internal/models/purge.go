@@ -0,0 +1,12 @@
+package models
+
+// PurgeResult summarizes a run of the purger. Rows removed together with their deleted author are
+// only counted as part of the user.
+type PurgeResult struct {
+	Users     int
+	Posts     int
+	Comments  int
+	MediaURLs []string
+	// ExportFiles are the paths of the data export archives of the purged users
+	ExportFiles []string
+}
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// Report reasons
+const (
+	ReasonSpam       = "spam"
+	ReasonNudity     = "nudity"
+	ReasonHarassment = "harassment"
+	ReasonHateSpeech = "hate_speech"
+	ReasonViolence   = "violence"
+	ReasonSelfHarm   = "self_harm"
+	ReasonOther      = "other"
+)
+
+// reasonSeverities ranks how urgently a report with each reason needs a moderator's attention
+var reasonSeverities = map[string]int{
+	ReasonSpam:       1,
+	ReasonOther:      1,
+	ReasonNudity:     2,
+	ReasonHarassment: 2,
+	ReasonHateSpeech: 3,
+	ReasonViolence:   3,
+	ReasonSelfHarm:   3,
+}
+
+// ReasonSeverity returns the severity of a report reason, or 0 for unknown reasons
+func ReasonSeverity(reason string) int {
+	return reasonSeverities[reason]
+}
+
+// Resolutions of reported content. Open content has no resolution.
+const (
+	ResolutionOpen      = "open"
+	ResolutionDismissed = "dismissed"
+	ResolutionHidden    = "hidden"
+)
+
+// IsValidResolution reports whether resolution is a state a moderator can put reported content in
+func IsValidResolution(resolution string) bool {
+	return resolution == ResolutionOpen || resolution == ResolutionDismissed || resolution == ResolutionHidden
+}
+
+// IsValidTarget reports whether targetType is something that can be reported
+func IsValidTarget(targetType string) bool {
+	return targetType == TargetUser || targetType == TargetPost || targetType == TargetComment
+}
+
+type Report struct {
+	ID         int        `json:"id" db:"id"`
+	ReporterID int        `json:"reporter_id" db:"reporter_id"`
+	TargetType string     `json:"target_type" db:"target_type"`
+	TargetID   int        `json:"target_id" db:"target_id"`
+	Reason     string     `json:"reason" db:"reason"`
+	Severity   int        `json:"severity" db:"severity"`
+	Details    string     `json:"details,omitempty" db:"details"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// ReportQueueItem is a reported target waiting for a moderator, with its open reports aggregated
+type ReportQueueItem struct {
+	TargetType  string   `json:"target_type"`
+	TargetID    int      `json:"target_id"`
+	ReportCount int      `json:"report_count"`
+	MaxSeverity int      `json:"max_severity"`
+	Priority    int      `json:"priority"` // Priority is the sum of the severities of the open reports
+	Reasons     []string `json:"reasons"`
+	Resolution  string   `json:"resolution"` // Resolution is the current state of the target, new reports can arrive for hidden content
+}
+
+// ResolutionRequest is the body of the report resolution endpoint
+type ResolutionRequest struct {
+	Resolution string `json:"resolution"`
+	Reason     string `json:"reason"`
+}
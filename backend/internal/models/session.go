@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"user_id" db:"user_id"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	Current    bool       `json:"current" db:"-"` // Current marks the session making the request
+}
+
+// Active reports whether the session may still be used to authenticate requests
+func (s *Session) Active() bool {
+	return s != nil && s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// TokenResponse is the result of a completed login: a JWT bound to a new session
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	ID        int       `json:"id"` // ID is the ID of the logged in user
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+type TOTP struct {
+	UserID      int        `json:"user_id" db:"user_id"`
+	Secret      string     `json:"-" db:"secret"` // Secret is never exposed after enrollment
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Enabled reports whether the user has confirmed their authenticator and TOTP is enforced on login
+func (t *TOTP) Enabled() bool {
+	return t != nil && t.ConfirmedAt != nil
+}
+
+type MFAChallenge struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFARequired is the result of a login that still needs a second factor. MFAToken is exchanged for a
+// session together with a code.
+type MFARequired struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// TOTPEnrollment is the secret of a new authenticator, to be confirmed with a code from it
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// RecoveryCodes are shown once and each let the user log in without their authenticator a single time
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -4,17 +4,57 @@ import (
 	"time"
 )
 
+// Roles a user can have, in increasing order of privilege
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      0,
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants at least the privileges of minimum
+func RoleAtLeast(role, minimum string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[minimum]
+}
+
 type Auth struct {
 	ID           int    `json:"id" db:"id"`
-	Username     string `json:"username" db:"username"`
-	Email        string `json:"email" db:"email"`
+	Username     string `json:"username" db:"username" validate:"omitempty,min=1,max=30,username"`
+	Email        string `json:"email" db:"email" validate:"omitempty,max=254,email"`
 	PasswordHash string `json:"-" db:"password_hash"` // PasswordHash is stored in DB but not exposed in JSON
 }
 
 type User struct {
 	Auth
-	Password     string    `json:"password,omitempty" db:"-"` // Password is optional in JSON, but not stored in the DB
-	Bio          string    `json:"bio,omitempty" db:"bio"`
-	ProfileImage string    `json:"profile_image,omitempty" db:"profile_image"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	Password       string     `json:"password,omitempty" db:"-" validate:"omitempty,max=72"` // Password is optional in JSON, but not stored in the DB
+	Bio            string     `json:"bio,omitempty" db:"bio" validate:"max=150"`
+	ProfileImage   string     `json:"profile_image,omitempty" db:"profile_image" validate:"omitempty,max=2048,mediaurl"`
+	Role           string     `json:"role,omitempty" db:"role"`
+	SuspendedAt    *time.Time `json:"suspended_at,omitempty" db:"suspended_at"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty" db:"suspended_until"` // SuspendedUntil is nil for indefinite suspensions
+	DeactivatedAt  *time.Time `json:"deactivated_at,omitempty" db:"deactivated_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PasswordChange is the body of the change password endpoint
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,max=72"`
+}
+
+// SuspendedAtTime reports whether the user is suspended at the given time
+func (u *User) SuspendedAtTime(t time.Time) bool {
+	return u.SuspendedAt != nil && (u.SuspendedUntil == nil || t.Before(*u.SuspendedUntil))
 }
@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IDTokenClaims are the claims of a verified ID token that we use to identify the user
+type IDTokenClaims struct {
+	Nonce             string    `json:"nonce"`
+	Email             string    `json:"email"`
+	EmailVerified     boolClaim `json:"email_verified"`
+	Name              string    `json:"name"`
+	PreferredUsername string    `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+// boolClaim accepts both JSON booleans and the "true"/"false" strings some providers send
+type boolClaim bool
+
+func (b *boolClaim) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case bool:
+		*b = boolClaim(v)
+	case string:
+		*b = v == "true"
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// VerifyIDToken checks the ID token's signature, issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: missing subject")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+
+	return claims, nil
+}
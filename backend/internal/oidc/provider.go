@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/tracing"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProviderConfig is the generic configuration for an OpenID Connect identity provider
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// metadata is the subset of the discovery document we rely on
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a client for a single OpenID Connect provider using the authorization code flow with PKCE
+type Provider struct {
+	config     ProviderConfig
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *keySet
+}
+
+// NewProvider creates a provider client. The discovery document is fetched lazily on first use,
+// so an unreachable provider does not prevent the server from starting.
+func NewProvider(config ProviderConfig) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: tracing.NewTransport(nil)},
+	}
+}
+
+// Name returns the name the provider is registered under, e.g. "google"
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// RedirectURL returns the callback URL the provider redirects back to
+func (p *Provider) RedirectURL() string {
+	return p.config.RedirectURL
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	var discovered metadata
+	if err := p.getJSON(ctx, wellKnown, &discovered); err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+
+	// The issuer in the document must match the configured one exactly (OIDC Discovery 4.3)
+	if discovered.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: configured %q, provider reported %q", p.config.Issuer, discovered.Issuer)
+	}
+
+	if discovered.AuthorizationEndpoint == "" || discovered.TokenEndpoint == "" || discovered.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document for %s is missing required endpoints", p.config.Issuer)
+	}
+
+	p.metadata = &discovered
+	p.keys = newKeySet(discovered.JWKSURI, p)
+	return p.metadata, nil
+}
+
+// AuthCodeURL returns the URL to send the user to in order to start the login
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Token is the response of the token endpoint
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// tokenError is the error response of the token endpoint (RFC 6749 section 5.2)
+type tokenError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades an authorization code and the PKCE verifier for tokens
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	meta, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var tokenErr tokenError
+		if json.Unmarshal(body, &tokenErr) == nil && tokenErr.Error != "" {
+			return nil, fmt.Errorf("token endpoint returned %s: %s", tokenErr.Error, tokenErr.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("token response did not include an id_token")
+	}
+
+	return &token, nil
+}
+
+// getJSON fetches a JSON document from the provider
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
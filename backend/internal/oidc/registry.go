@@ -0,0 +1,28 @@
+package oidc
+
+import "instagram/internal/config"
+
+// Providers are the identity providers users can log in with, by name
+type Providers map[string]*Provider
+
+// NewProviders creates a client for every configured identity provider
+func NewProviders(configs []config.OIDCProviderConfig) Providers {
+	providers := Providers{}
+	for _, providerConfig := range configs {
+		providers[providerConfig.Name] = NewProvider(ProviderConfig{
+			Name:         providerConfig.Name,
+			Issuer:       providerConfig.Issuer,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			RedirectURL:  providerConfig.RedirectURL,
+			Scopes:       providerConfig.Scopes,
+		})
+	}
+	return providers
+}
+
+// Get returns the provider configured under name
+func (p Providers) Get(name string) (*Provider, bool) {
+	provider, ok := p[name]
+	return provider, ok
+}
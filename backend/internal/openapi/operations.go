@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"fmt"
+	"instagram/internal/graphql"
+	"instagram/internal/models"
+	"net/http"
+)
+
+// Authentication an operation needs
+const (
+	authPublic  = iota // authPublic operations need no credentials
+	authBearer         // authBearer operations take a session JWT or a personal access token
+	authSession        // authSession operations only take a session JWT
+)
+
+// operation describes a route. request and response are models encoded as JSON, or a body or
+// alternatives for anything else.
+type operation struct {
+	pattern     string
+	summary     string
+	description string
+	auth        int
+	scope       string // scope is the scope personal access tokens need
+	query       []Parameter
+	request     any
+	status      int
+	response    any
+}
+
+// body is a request or response that is not JSON
+type body struct {
+	contentType string
+	schema      *Schema
+}
+
+// alternatives is a JSON response that is one of several models
+type alternatives []any
+
+// loginResult is the response of every way to log in: a session, or the second factor it still needs
+var loginResult = alternatives{models.TokenResponse{}, models.MFARequired{}}
+
+var limitParameter = Parameter{
+	Name: "limit", In: "query", Description: "Number of entries to return, at most 500",
+	Schema: &Schema{Type: "integer", ExclusiveMinimum: number(0)},
+}
+
+// pageParameters select a page of a list endpoint, which returns the whole list without them
+var pageParameters = []Parameter{
+	limitParameter,
+	{Name: "offset", In: "query", Description: "Number of entries to skip", Schema: &Schema{Type: "integer", Minimum: number(0)}},
+}
+
+// operations documents every route registered by the routers, and the endpoints served next to them
+var operations = []operation{
+	// Authentication
+	{pattern: "POST /auth/signup", summary: "Create an account and log in", request: models.User{}, status: http.StatusOK, response: models.TokenResponse{}},
+	{pattern: "POST /auth/login", summary: "Log in with an email and password", request: models.User{}, status: http.StatusOK, response: loginResult,
+		description: "Accounts with TOTP enabled get an MFA token instead of a session, to be exchanged at /auth/mfa/verify."},
+	{pattern: "POST /auth/restore", summary: "Restore a deactivated or deleted account and log in", request: models.User{}, status: http.StatusOK, response: loginResult},
+	{pattern: "POST /auth/mfa/verify", summary: "Complete a login with a TOTP or recovery code", request: models.MFAChallenge{}, status: http.StatusOK, response: models.TokenResponse{}},
+	{pattern: "GET /auth/oidc/{provider}/login", summary: "Start a login with an external identity provider", status: http.StatusFound},
+	{pattern: "GET /auth/oidc/{provider}/callback", summary: "Complete a login with an external identity provider", status: http.StatusOK,
+		response:    append(alternatives{models.OIDCLinkRequired{}}, loginResult...),
+		description: "Logins with a new identity whose email belongs to an account get a link token instead of a session, to be exchanged at /auth/oidc/link.",
+		query: []Parameter{
+			{Name: "code", In: "query", Description: "Authorization code issued by the provider", Schema: &Schema{Type: "string"}},
+			{Name: "state", In: "query", Description: "State of the login started at /auth/oidc/{provider}/login", Schema: &Schema{Type: "string"}},
+			{Name: "error", In: "query", Description: "Error reported by the provider", Schema: &Schema{Type: "string"}},
+		}},
+	{pattern: "POST /auth/oidc/link", summary: "Link an external identity to an account with its password and log in", request: models.OIDCLink{}, status: http.StatusOK, response: loginResult},
+	{pattern: "POST /auth/mfa/totp/enroll", summary: "Start enrolling an authenticator", auth: authSession, status: http.StatusOK, response: models.TOTPEnrollment{}},
+	{pattern: "POST /auth/mfa/totp/confirm", summary: "Enable TOTP with a code from the new authenticator", auth: authSession, request: models.MFAChallenge{}, status: http.StatusOK, response: models.RecoveryCodes{}},
+	{pattern: "POST /auth/mfa/totp/recovery-codes", summary: "Replace the recovery codes", auth: authSession, request: models.MFAChallenge{}, status: http.StatusOK, response: models.RecoveryCodes{}},
+	{pattern: "DELETE /auth/mfa/totp", summary: "Disable TOTP", auth: authSession, request: models.MFAChallenge{}, status: http.StatusNoContent},
+	{pattern: "POST /auth/password", summary: "Change the password", auth: authSession, request: models.PasswordChange{}, status: http.StatusNoContent,
+		description: "Every other session of the user is logged out."},
+	{pattern: "GET /auth/sessions", summary: "List the active sessions", auth: authSession, status: http.StatusOK, response: []models.Session{}},
+	{pattern: "DELETE /auth/sessions/{id}", summary: "Log out a session", auth: authSession, status: http.StatusNoContent},
+	{pattern: "POST /auth/tokens", summary: "Create a personal access token", auth: authSession, request: models.PersonalAccessToken{}, status: http.StatusCreated, response: models.PersonalAccessToken{},
+		description: "The token is only returned in this response."},
+	{pattern: "GET /auth/tokens", summary: "List the personal access tokens", auth: authSession, status: http.StatusOK, response: []models.PersonalAccessToken{}},
+	{pattern: "DELETE /auth/tokens/{id}", summary: "Revoke a personal access token", auth: authSession, status: http.StatusNoContent},
+
+	// Users
+	{pattern: "POST /users/", summary: "Create a user", auth: authBearer, scope: models.ScopeUsersWrite, request: models.User{}, status: http.StatusCreated, response: models.User{}},
+	{pattern: "PATCH /users/", summary: "Update the logged in user", auth: authBearer, scope: models.ScopeUsersWrite, request: models.User{}, status: http.StatusOK, response: models.User{}},
+	{pattern: "GET /users/{id}", summary: "Get a user", auth: authBearer, scope: models.ScopeUsersRead, status: http.StatusOK, response: models.User{}},
+	{pattern: "DELETE /users/{id}", summary: "Delete a user", auth: authBearer, scope: models.ScopeUsersWrite, status: http.StatusNoContent,
+		description: "The user can be restored during a grace period, after which the account and its content are purged."},
+	{pattern: "POST /users/{id}/deactivate", summary: "Deactivate a user", auth: authSession, status: http.StatusNoContent},
+
+	// Follows
+	{pattern: "POST /follow/", summary: "Follow a user", auth: authBearer, scope: models.ScopeFollowsWrite, request: models.Follow{}, status: http.StatusOK},
+	{pattern: "DELETE /follow/", summary: "Unfollow a user", auth: authBearer, scope: models.ScopeFollowsWrite, request: models.Follow{}, status: http.StatusOK},
+
+	// Posts
+	{pattern: "POST /post/", summary: "Create a post", auth: authBearer, scope: models.ScopePostsWrite, request: models.Post{}, status: http.StatusCreated, response: models.Post{}},
+	{pattern: "GET /post/{id}", summary: "Get a post", auth: authBearer, scope: models.ScopePostsRead, status: http.StatusOK, response: models.Post{}},
+	{pattern: "DELETE /post/{id}", summary: "Delete a post", auth: authBearer, scope: models.ScopePostsWrite, status: http.StatusOK},
+	{pattern: "POST /post/{id}/restore", summary: "Restore a deleted post", auth: authBearer, scope: models.ScopePostsWrite, status: http.StatusNoContent},
+	{pattern: "GET /post/user/{user_id}", summary: "List the posts of a user, newest first", auth: authBearer, scope: models.ScopePostsRead, query: pageParameters, status: http.StatusOK, response: []models.Post{}},
+	{pattern: "GET /post/feed/{user_id}", summary: "Get the feed of a user, newest first", auth: authBearer, scope: models.ScopePostsRead, query: pageParameters, status: http.StatusOK, response: []models.FeedPost{}},
+
+	// Comments
+	{pattern: "POST /comment/", summary: "Comment on a post", auth: authBearer, scope: models.ScopeCommentsWrite, request: models.Comment{}, status: http.StatusOK},
+	{pattern: "GET /comment/{id}", summary: "Get a comment", auth: authBearer, scope: models.ScopeCommentsRead, status: http.StatusOK, response: models.Comment{}},
+	{pattern: "DELETE /comment/{id}", summary: "Delete a comment", auth: authBearer, scope: models.ScopeCommentsWrite, status: http.StatusOK},
+	{pattern: "POST /comment/{id}/restore", summary: "Restore a deleted comment", auth: authBearer, scope: models.ScopeCommentsWrite, status: http.StatusNoContent},
+	{pattern: "GET /comment/post/{post_id}", summary: "List the comments of a post, oldest first", auth: authBearer, scope: models.ScopeCommentsRead, query: pageParameters, status: http.StatusOK, response: []models.Comment{}},
+
+	// Reports
+	{pattern: "POST /report/", summary: "Report a user, post or comment", auth: authSession, request: models.Report{}, status: http.StatusCreated},
+
+	// Moderation
+	{pattern: "POST /admin/users/{id}/suspend", summary: "Suspend a user", description: "Requires the moderator role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "POST /admin/users/{id}/unsuspend", summary: "Lift the suspension of a user", description: "Requires the moderator role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "PUT /admin/users/{id}/role", summary: "Change the role of a user", description: "Requires the admin role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "POST /admin/users/{id}/restore", summary: "Restore a deleted user", description: "Requires the admin role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "DELETE /admin/posts/{id}", summary: "Remove a post", description: "Requires the moderator role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "DELETE /admin/comments/{id}", summary: "Remove a comment", description: "Requires the moderator role.", auth: authSession, request: models.ModerationRequest{}, status: http.StatusNoContent},
+	{pattern: "GET /admin/reports", summary: "List reported content by priority", description: "Requires the moderator role.", auth: authSession,
+		query: []Parameter{limitParameter}, status: http.StatusOK, response: []models.ReportQueueItem{}},
+	{pattern: "GET /admin/reports/{target_type}/{target_id}", summary: "List the reports of a target", description: "Requires the moderator role.", auth: authSession, status: http.StatusOK, response: []models.Report{}},
+	{pattern: "POST /admin/reports/{target_type}/{target_id}/resolve", summary: "Resolve the reports of a target", description: "Requires the moderator role.", auth: authSession, request: models.ResolutionRequest{}, status: http.StatusNoContent},
+	{pattern: "GET /admin/actions", summary: "List recent moderation actions", description: "Requires the moderator role.", auth: authSession,
+		query: []Parameter{limitParameter}, status: http.StatusOK, response: []models.ModerationAction{}},
+	{pattern: "GET /admin/audit", summary: "Search the audit log", description: "Requires the admin role.", auth: authSession, status: http.StatusOK, response: []models.AuditEvent{},
+		query: []Parameter{
+			{Name: "action", In: "query", Schema: &Schema{Type: "string"}},
+			{Name: "actor_id", In: "query", Schema: &Schema{Type: "integer"}},
+			{Name: "target_type", In: "query", Schema: &Schema{Type: "string"}},
+			{Name: "target_id", In: "query", Schema: &Schema{Type: "integer"}},
+			{Name: "request_id", In: "query", Schema: &Schema{Type: "string"}},
+			{Name: "since", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			{Name: "until", In: "query", Schema: &Schema{Type: "string", Format: "date-time"}},
+			limitParameter,
+		}},
+
+	// Data portability
+	{pattern: "POST /export/", summary: "Request an export of all data of the user", auth: authSession, status: http.StatusAccepted, response: models.DataExport{}},
+	{pattern: "GET /export/{id}", summary: "Get the status of a data export", auth: authSession, status: http.StatusOK, response: models.DataExport{}},
+	{pattern: "GET /export/{id}/download", summary: "Download a finished data export", status: http.StatusOK,
+		response: body{"application/zip", &Schema{Type: "string", Format: "binary"}},
+		query: []Parameter{
+			{Name: "token", In: "query", Required: true, Description: "Download token from the download URL of the export", Schema: &Schema{Type: "string"}},
+		}},
+	{pattern: "POST /import/instagram", summary: "Import an Instagram data download", auth: authSession, status: http.StatusOK, response: models.ImportSummary{},
+		request: body{"multipart/form-data", &Schema{
+			Type:       "object",
+			Properties: map[string]*Schema{"archive": {Type: "string", Format: "binary"}},
+			Required:   []string{"archive"},
+		}}},
+	{pattern: "POST /graphql", summary: "Run a GraphQL query", auth: authBearer, request: models.GraphQLRequest{}, status: http.StatusOK,
+		description: fmt.Sprintf("Queries users, posts, comments, follows and the feed in one request. The schema is available "+
+			"through introspection. Queries nested deeper than %d fields or costing more than %d are rejected with a 400 "+
+			"response; every field costs 1, multiplied by the size of the lists it is selected under. Personal access "+
+			"tokens need the read scope of each type they select.", graphql.MaxDepth, graphql.MaxComplexity),
+		response: body{"application/json", &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"data":   {Type: "object"},
+				"errors": {Type: "array", Items: &Schema{Type: "object"}},
+			},
+		}}},
+
+	// Served next to the routers
+	{pattern: "GET /media/{path...}", summary: "Download uploaded media", status: http.StatusOK,
+		response: body{"application/octet-stream", &Schema{Type: "string", Format: "binary"}}},
+	{pattern: "GET /openapi.json", summary: "Get this document", status: http.StatusOK, response: body{"application/json", &Schema{Type: "object"}}},
+	{pattern: "GET /healthz", summary: "Check that the process is alive", status: http.StatusOK},
+	{pattern: "GET /readyz", summary: "Check that the server can handle requests", status: http.StatusOK},
+	{pattern: "GET /metrics", summary: "Get Prometheus metrics", status: http.StatusOK, response: body{"text/plain", &Schema{Type: "string"}}},
+}
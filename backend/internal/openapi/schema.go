@@ -0,0 +1,160 @@
+package openapi
+
+import (
+	"instagram/internal/problem"
+	"instagram/internal/validation"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemas derives JSON schemas from Go types. Named structs become components referenced by name,
+// the way encoding/json would encode them.
+type schemas struct {
+	components map[string]*Schema
+}
+
+// componentNames renames types whose Go name does not say what they are outside their package
+var componentNames = map[reflect.Type]string{
+	reflect.TypeFor[problem.Details](): "Problem",
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// of returns the schema of the type of v
+func (s *schemas) of(v any) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return s.schema(t.Elem())
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		return s.component(t)
+	}
+	return &Schema{}
+}
+
+// component registers a struct in the components and returns a reference to it
+func (s *schemas) component(t reflect.Type) *Schema {
+	name, ok := componentNames[t]
+	if !ok {
+		name = t.Name()
+	}
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := s.components[name]; ok {
+		return ref
+	}
+
+	// Register the component before its fields, so that recursive types end in a reference
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.components[name] = schema
+	s.fields(t, schema)
+	return ref
+}
+
+// fields adds the fields of a struct to its schema. Embedded structs are flattened like encoding/json
+// does.
+func (s *schemas) fields(t reflect.Type, schema *Schema) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, schema)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schema(field.Type)
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if constrain(property, rules) {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// constrain translates the validation rules of a field into its schema and reports whether the field
+// is required
+func constrain(schema *Schema, rules string) bool {
+	required := false
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "username":
+			schema.Pattern = validation.UsernamePattern
+		case "mediaurl":
+			schema.Format = "uri-reference"
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "gt":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			bound(schema, name, n)
+		}
+	}
+	return required
+}
+
+// bound applies a min, max or gt rule, which limit the length of strings and the value of numbers
+func bound(schema *Schema, rule string, n int) {
+	if schema.Type == "string" {
+		switch rule {
+		case "min":
+			schema.MinLength = &n
+		case "max":
+			schema.MaxLength = &n
+		}
+		return
+	}
+
+	switch rule {
+	case "min":
+		schema.Minimum = number(float64(n))
+	case "max":
+		schema.Maximum = number(float64(n))
+	case "gt":
+		schema.ExclusiveMinimum = number(float64(n))
+	}
+}
+
+func number(n float64) *float64 {
+	return &n
+}
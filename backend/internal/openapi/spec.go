@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"instagram/internal/logging"
+	"instagram/internal/problem"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	swaggerfiles "github.com/swaggo/files/v2"
+)
+
+// bearerScheme is the name of the security scheme of authenticated operations
+const bearerScheme = "bearerAuth"
+
+// tags group the operations by the first segment of their path
+var tags = []Tag{
+	{Name: "auth", Description: "Login, sessions, second factors and personal access tokens"},
+	{Name: "users", Description: "User profiles and account lifecycle"},
+	{Name: "follow", Description: "Following other users"},
+	{Name: "post", Description: "Posts and feeds"},
+	{Name: "comment", Description: "Comments on posts"},
+	{Name: "report", Description: "Reporting content to moderators"},
+	{Name: "admin", Description: "Moderation and the audit log, for moderators and admins"},
+	{Name: "export", Description: "Exports of all data of a user"},
+	{Name: "import", Description: "Imports from other services"},
+	{Name: "graphql", Description: "Reading users, posts, comments and follows in one request"},
+}
+
+// Spec returns the document describing the API. It is built once, from the operations and the models
+// they reference.
+var Spec = sync.OnceValue(build)
+
+func build() *Document {
+	schemas := &schemas{components: map[string]*Schema{}}
+	problemSchema := schemas.of(problem.Details{})
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "Instagram API",
+			Description: "Errors are reported as RFC 7807 problem details.",
+			Version:     "1.0.0",
+		},
+		Paths: map[string]PathItem{},
+		Tags:  tags,
+		Components: Components{
+			Schemas: schemas.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerScheme: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "A session JWT from a login, or a personal access token where the operation allows it",
+				},
+			},
+		},
+	}
+
+	for _, op := range operations {
+		method, path, ok := strings.Cut(op.pattern, " ")
+		if !ok {
+			panic("openapi: operation without a method: " + op.pattern)
+		}
+		path = strings.ReplaceAll(path, "...}", "}")
+
+		operation := &Operation{
+			Summary:     op.summary,
+			Description: op.description,
+			OperationID: operationID(method, path),
+			Parameters:  append(pathParameters(path), op.query...),
+			Responses: map[string]*Response{
+				strconv.Itoa(op.status): {
+					Description: http.StatusText(op.status),
+					Content:     content(schemas, op.response),
+				},
+				"default": {
+					Description: "Error",
+					Content:     map[string]MediaType{problem.ContentType: {Schema: problemSchema}},
+				},
+			},
+		}
+		tag := strings.Split(path, "/")[1]
+		if slices.ContainsFunc(tags, func(t Tag) bool { return t.Name == tag }) {
+			operation.Tags = []string{tag}
+		}
+		if op.request != nil {
+			operation.RequestBody = &RequestBody{Required: true, Content: content(schemas, op.request)}
+		}
+
+		switch op.auth {
+		case authBearer:
+			operation.Security = []SecurityRequirement{{bearerScheme: {}}}
+			if op.scope != "" {
+				operation.Description = strings.TrimSpace(operation.Description + " Personal access tokens need the " + op.scope + " scope.")
+			}
+		case authSession:
+			operation.Security = []SecurityRequirement{{bearerScheme: {}}}
+			operation.Description = strings.TrimSpace(operation.Description + " Requires a session, personal access tokens are rejected.")
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(method)] = operation
+	}
+
+	return doc
+}
+
+// content describes a request or response body
+func content(schemas *schemas, v any) map[string]MediaType {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case body:
+		return map[string]MediaType{v.contentType: {Schema: v.schema}}
+	case alternatives:
+		schema := &Schema{}
+		for _, alternative := range v {
+			schema.OneOf = append(schema.OneOf, schemas.of(alternative))
+		}
+		return map[string]MediaType{"application/json": {Schema: schema}}
+	default:
+		return map[string]MediaType{"application/json": {Schema: schemas.of(v)}}
+	}
+}
+
+// pathParameters describes the wildcards of a path. IDs are integers, anything else is a string.
+func pathParameters(path string) []Parameter {
+	var parameters []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSuffix(name, "}")
+
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = &Schema{Type: "integer"}
+		}
+		parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return parameters
+}
+
+// operationID names an operation after its method and path, e.g. "getPostFeedUserId" for
+// "GET /post/feed/{user_id}"
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '_' || r == '-' || r == '.'
+	}) {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return id.String()
+}
+
+// Handler serves the document as JSON
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(Spec())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("Failed to write OpenAPI document", "error", err)
+		}
+	})
+}
+
+//go:embed docs
+var docs embed.FS
+
+// DocsHandler serves Swagger UI for the document at /openapi.json. prefix is the path the handler is
+// mounted at, such as "/docs".
+func DocsHandler(prefix string) http.Handler {
+	initializer, err := fs.Sub(docs, "docs")
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /swagger-initializer.js", http.FileServerFS(initializer))
+	mux.Handle("GET /", http.FileServerFS(swaggerfiles.FS))
+	return http.StripPrefix(prefix, mux)
+}
@@ -0,0 +1,90 @@
+// Package problem writes error responses as RFC 7807 problem details
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"instagram/internal/logging"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Details is the body of an error response. Type is left empty, which means "about:blank": the
+// problem is described by the status code and its title.
+type Details struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Errors lists the invalid fields of a request that failed validation
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// Write responds with a problem of the given status. detail is shown to the client and must not
+// contain internals like SQL errors, use Error for those.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	write(w, r, Details{Status: status, Detail: detail})
+}
+
+func write(w http.ResponseWriter, r *http.Request, details Details) {
+	details.Title = http.StatusText(details.Status)
+	details.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(details.Status)
+	err := json.NewEncoder(w).Encode(details)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("Failed to write problem details", "error", err)
+	}
+}
+
+// Error responds with the problem matching err. Validation errors and domain errors of the repositories
+// are reported with their message and status, anything else is logged and reported as an internal error
+// without details.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		write(w, r, Details{Status: http.StatusUnprocessableEntity, Detail: "The request has invalid fields", Errors: fieldErrs})
+		return
+	}
+
+	var domainErr *repositories.Error
+	if errors.As(err, &domainErr) {
+		Write(w, r, Status(err), domainErr.Message)
+		return
+	}
+
+	logging.FromContext(r.Context()).Error("Request failed", "error", err)
+	span := trace.SpanFromContext(r.Context())
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	Write(w, r, http.StatusInternalServerError, "")
+}
+
+// Status returns the status code err is reported with
+func Status(err error) int {
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repositories.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, repositories.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repositories.ErrValidation), errors.As(err, new(validation.Errors)):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
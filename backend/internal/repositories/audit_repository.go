@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"strings"
+	"time"
+)
+
+func AddAuditEvent(ctx context.Context, db *sql.DB, event *models.AuditEvent) error {
+	query := `
+        INSERT INTO audit_log (action, actor_id, target_type, target_id, ip_address, request_id, details, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `
+	_, err := db.ExecContext(ctx, query, event.Action, event.ActorID, nullString(event.TargetType), event.TargetID,
+		event.IPAddress, event.RequestID, nullString(event.Details), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add audit event: %w", err)
+	}
+	return nil
+}
+
+// GetAuditEvents returns the audit events matching the filter, newest first
+func GetAuditEvents(ctx context.Context, db *sql.DB, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if filter.Action != "" {
+		// Actions ending in a dot select a whole namespace, e.g. "admin."
+		if strings.HasSuffix(filter.Action, ".") {
+			where("action LIKE ? ESCAPE '\\'", escapeLike(filter.Action)+"%")
+		} else {
+			where("action = ?", filter.Action)
+		}
+	}
+	if filter.ActorID != 0 {
+		where("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		where("request_id = ?", filter.RequestID)
+	}
+	if !filter.Since.IsZero() {
+		where("created_at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		where("created_at < ?", filter.Until.UTC())
+	}
+
+	query := `
+        SELECT id, action, actor_id, COALESCE(target_type, ''), target_id, ip_address, request_id,
+               COALESCE(details, ''), created_at
+        FROM audit_log
+    `
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var actorID, targetID sql.NullInt64
+		err := rows.Scan(&event.ID, &event.Action, &actorID, &event.TargetType, &targetID, &event.IPAddress,
+			&event.RequestID, &event.Details, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			event.ActorID = &id
+		}
+		if targetID.Valid {
+			id := int(targetID.Int64)
+			event.TargetID = &id
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
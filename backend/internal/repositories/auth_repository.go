@@ -1,22 +1,23 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"instagram/internal/models"
 )
 
-func GetUserAuth(db *sql.DB, email string) (*models.Auth, error) {
+func GetUserAuth(ctx context.Context, db *sql.DB, email string) (*models.Auth, error) {
 	var auth models.Auth
 
 	query := `
         SELECT id, username, email, password_hash
         FROM users
-        WHERE email = ?
+        WHERE email = ? AND deleted_at IS NULL
     `
 
-	err := db.QueryRow(query, email).Scan(
+	err := db.QueryRowContext(ctx, query, email).Scan(
 		&auth.ID,
 		&auth.Username,
 		&auth.Email,
@@ -25,9 +26,60 @@ func GetUserAuth(db *sql.DB, email string) (*models.Auth, error) {
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return &models.Auth{}, fmt.Errorf("user with email %s not found", email)
+			return &models.Auth{}, NotFound("user with email %s not found", email)
 		}
 		return &models.Auth{}, fmt.Errorf("failed to retrieve user: %w", err)
 	}
 	return &auth, nil
 }
+
+// FindUserAuthByEmail looks up a user by email, ignoring case. It returns nil if no user has the email.
+func FindUserAuthByEmail(ctx context.Context, db *sql.DB, email string) (*models.Auth, error) {
+	var auth models.Auth
+
+	query := `
+        SELECT id, username, email, password_hash
+        FROM users
+        WHERE lower(email) = lower(?) AND deleted_at IS NULL
+    `
+
+	err := db.QueryRowContext(ctx, query, email).Scan(&auth.ID, &auth.Username, &auth.Email, &auth.PasswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	return &auth, nil
+}
+
+// GetDeletedUserAuth looks up a soft deleted user by email so they can restore their account.
+// It returns nil if there is no such user.
+func GetDeletedUserAuth(ctx context.Context, db *sql.DB, email string) (*models.Auth, error) {
+	var auth models.Auth
+
+	query := `
+        SELECT id, username, email, password_hash
+        FROM users
+        WHERE email = ? AND deleted_at IS NOT NULL
+    `
+
+	err := db.QueryRowContext(ctx, query, email).Scan(&auth.ID, &auth.Username, &auth.Email, &auth.PasswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	return &auth, nil
+}
+
+// UsernameExists reports whether the username is already taken
+func UsernameExists(ctx context.Context, db *sql.DB, username string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)`, username).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check username: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/models"
+	"strings"
+)
+
+// pageClause returns the LIMIT clause selecting a page of an ordered query, to be appended last
+func pageClause(page models.Page) string {
+	if page.Limit <= 0 && page.Offset <= 0 {
+		return ""
+	}
+
+	// SQLite reads a negative limit as no limit, which allows an offset on its own
+	limit := page.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", limit, max(page.Offset, 0))
+}
+
+// inClause returns a parenthesized list of placeholders for the IDs, and the IDs as query arguments
+func inClause(ids []int) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
+
+// countByID runs a query selecting an ID and a count per row, and returns the counts keyed by ID. IDs
+// without rows are left out.
+func countByID(ctx context.Context, db Querier, query string, args []interface{}) (map[int]int, error) {
+	counts := make(map[int]int)
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return err
+		}
+		counts[id] = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -1,63 +1,149 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"instagram/internal/models"
+	"time"
 )
 
-func AddComment(db *sql.DB, comment *models.Comment) error {
-	_, err := db.Exec("INSERT INTO comments (user_id, post_id, content) VALUES ($1, $2, $3)", comment.UserID, comment.PostID, comment.Content)
-	return err
+// AddComment saves a new comment and sets its ID
+func AddComment(ctx context.Context, db Querier, comment *models.Comment) error {
+	query := `INSERT INTO comments (user_id, post_id, content, created_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)`
+	result, err := db.ExecContext(ctx, query, comment.UserID, comment.PostID, comment.Content)
+	if err != nil {
+		return fmt.Errorf("failed to add comment: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve last insert id: %w", err)
+	}
+	comment.ID = int(id)
+	return nil
 }
 
-func GetComment(db *sql.DB, commentID int) (*models.Comment, error) {
+// GetComment retrieves a comment that wasn't deleted. Comments nobody but their author should see are
+// returned too, with Hidden set.
+func GetComment(ctx context.Context, db Querier, commentID int) (*models.Comment, error) {
+	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at, NOT (` + visibleCommentCondition + `)
+        FROM comments c WHERE c.id = ? AND c.deleted_at IS NULL`
+
 	var comment models.Comment
-	err := db.QueryRow("SELECT id, user_id, post_id, content FROM comments WHERE id = $1", commentID).Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content)
+	err := db.QueryRowContext(ctx, query, commentID).Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content, &comment.CreatedAt, &comment.Hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFound("comment with id %d not found", commentID)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get comment: %w", err)
 	}
 	return &comment, nil
 }
 
-func DeleteComment(db *sql.DB, commentID int) error {
-	result, err := db.Exec("DELETE FROM comments WHERE id = $1", commentID)
+// DeleteComment soft deletes the comment. It can be restored until it is purged, by an admin only if a
+// moderator removed it.
+func DeleteComment(ctx context.Context, db Querier, commentID int, byModerator bool) error {
+	query := `UPDATE comments SET deleted_at = ?, removed_by_moderator = ? WHERE id = ? AND deleted_at IS NULL`
+	result, err := db.ExecContext(ctx, query, time.Now().UTC(), byModerator, commentID)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to delete comment: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return NotFound("comment with id %d not found", commentID)
 	}
 
 	return nil
 }
 
-func GetCommentsForPost(db *sql.DB, postID int) ([]models.Comment, error) {
-	rows, err := db.Query("SELECT id, user_id, post_id, content FROM comments WHERE post_id = $1", postID)
+// RestoreComment undoes the soft deletion of a comment that has not been purged yet
+func RestoreComment(ctx context.Context, db Querier, commentID int) error {
+	query := `UPDATE comments SET deleted_at = NULL, removed_by_moderator = FALSE WHERE id = ? AND deleted_at IS NOT NULL`
+	result, err := db.ExecContext(ctx, query, commentID)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to restore comment: %w", err)
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(rows)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return NotFound("deleted comment with id %d not found", commentID)
+	}
+
+	return nil
+}
+
+// GetCommentsForPost retrieves a page of the comments of a post, oldest first
+func GetCommentsForPost(ctx context.Context, db Querier, postID int, page models.Page) ([]models.Comment, error) {
+	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at FROM comments c WHERE c.post_id = ? AND ` +
+		visibleCommentCondition + ` ORDER BY c.id` + pageClause(page)
 
 	var comments []models.Comment
-	for rows.Next() {
+	err := queryRows(ctx, db, query, []interface{}{postID}, func(rows *sql.Rows) error {
 		var comment models.Comment
-		err := rows.Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content)
-		if err != nil {
-			return nil, err
+		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content, &comment.CreatedAt); err != nil {
+			return err
 		}
 		comments = append(comments, comment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
 	}
 
 	return comments, nil
 }
+
+// GetCommentsForPosts retrieves the first limit visible comments of each of the posts, oldest first,
+// keyed by post ID
+func GetCommentsForPosts(ctx context.Context, db Querier, postIDs []int, limit int) (map[int][]models.Comment, error) {
+	in, args := inClause(postIDs)
+	query := `
+        SELECT id, user_id, post_id, content, created_at FROM (
+            SELECT c.id, c.user_id, c.post_id, c.content, c.created_at,
+                   ROW_NUMBER() OVER (PARTITION BY c.post_id ORDER BY c.id) AS position
+            FROM comments c
+            WHERE c.post_id IN ` + in + ` AND ` + visibleCommentCondition + `
+        )
+        WHERE position <= ?
+        ORDER BY post_id, id
+    `
+
+	comments := make(map[int][]models.Comment, len(postIDs))
+	err := queryRows(ctx, db, query, append(args, limit), func(rows *sql.Rows) error {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content, &comment.CreatedAt); err != nil {
+			return err
+		}
+		comments[comment.PostID] = append(comments[comment.PostID], comment)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	return comments, nil
+}
+
+// CountCommentsForPosts counts the visible comments of each of the posts, keyed by post ID. Posts
+// without comments are left out.
+func CountCommentsForPosts(ctx context.Context, db Querier, postIDs []int) (map[int]int, error) {
+	in, args := inClause(postIDs)
+	query := `SELECT c.post_id, COUNT(*) FROM comments c WHERE c.post_id IN ` + in + ` AND ` + visibleCommentCondition + ` GROUP BY c.post_id`
+
+	counts, err := countByID(ctx, db, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,223 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"time"
+)
+
+const dataExportColumns = `id, user_id, status, COALESCE(file_path, ''), COALESCE(error, ''), created_at, completed_at, expires_at`
+
+func CreateDataExport(ctx context.Context, db *sql.DB, userID int) (*models.DataExport, error) {
+	result, err := db.ExecContext(ctx, `INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`,
+		userID, models.ExportPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data export: %w", err)
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last insert id: %w", err)
+	}
+
+	return GetDataExport(ctx, db, int(lastInsertID))
+}
+
+// GetDataExport returns the export with the given ID, or nil if there is none
+func GetDataExport(ctx context.Context, db *sql.DB, id int) (*models.DataExport, error) {
+	export, err := scanDataExport(db.QueryRowContext(ctx, `SELECT `+dataExportColumns+` FROM data_exports WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get data export: %w", err)
+	}
+	return export, nil
+}
+
+// HasActiveDataExport reports whether the user already has an export waiting or being built
+func HasActiveDataExport(ctx context.Context, db *sql.DB, userID int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM data_exports WHERE user_id = ? AND status IN (?, ?))`
+	err := db.QueryRowContext(ctx, query, userID, models.ExportPending, models.ExportRunning).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check data exports: %w", err)
+	}
+	return exists, nil
+}
+
+// ClaimNextDataExport marks the oldest pending export as running and returns it, or nil if none are pending
+func ClaimNextDataExport(ctx context.Context, db *sql.DB) (*models.DataExport, error) {
+	query := `
+        UPDATE data_exports SET status = ?
+        WHERE id = (SELECT id FROM data_exports WHERE status = ? ORDER BY id LIMIT 1)
+        RETURNING ` + dataExportColumns
+	export, err := scanDataExport(db.QueryRowContext(ctx, query, models.ExportRunning, models.ExportPending))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim data export: %w", err)
+	}
+	return export, nil
+}
+
+// RequeueRunningDataExports puts exports interrupted by a restart back in the queue
+func RequeueRunningDataExports(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `UPDATE data_exports SET status = ? WHERE status = ?`, models.ExportPending, models.ExportRunning)
+	if err != nil {
+		return fmt.Errorf("failed to requeue data exports: %w", err)
+	}
+	return nil
+}
+
+func CompleteDataExport(ctx context.Context, db *sql.DB, id int, filePath string, expiresAt time.Time) error {
+	query := `UPDATE data_exports SET status = ?, file_path = ?, completed_at = ?, expires_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, models.ExportCompleted, filePath, time.Now().UTC(), expiresAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to complete data export: %w", err)
+	}
+	return nil
+}
+
+func FailDataExport(ctx context.Context, db *sql.DB, id int, reason string) error {
+	query := `UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, models.ExportFailed, reason, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to fail data export: %w", err)
+	}
+	return nil
+}
+
+// GetExpiredDataExports returns completed exports whose archive should be deleted
+func GetExpiredDataExports(ctx context.Context, db *sql.DB, now time.Time) ([]models.DataExport, error) {
+	query := `SELECT ` + dataExportColumns + ` FROM data_exports WHERE status = ? AND expires_at <= ?`
+	rows, err := db.QueryContext(ctx, query, models.ExportCompleted, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired data exports: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var exports []models.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan data export: %w", err)
+		}
+		exports = append(exports, *export)
+	}
+	return exports, nil
+}
+
+func ExpireDataExport(ctx context.Context, db *sql.DB, id int) error {
+	_, err := db.ExecContext(ctx, `UPDATE data_exports SET status = ?, file_path = NULL WHERE id = ?`, models.ExportExpired, id)
+	if err != nil {
+		return fmt.Errorf("failed to expire data export: %w", err)
+	}
+	return nil
+}
+
+func scanDataExport(row scanner) (*models.DataExport, error) {
+	var export models.DataExport
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.FilePath, &export.Error, &export.CreatedAt,
+		&completedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	return &export, nil
+}
+
+// GetExportData collects everything stored about a user, including their deleted posts and
+// comments that have not been purged yet
+func GetExportData(ctx context.Context, db *sql.DB, userID int) (*models.ExportData, error) {
+	user, err := GetUserByID(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = ""
+
+	data := &models.ExportData{Profile: *user}
+
+	err = queryRows(ctx, db, `SELECT id, user_id, image_url, COALESCE(caption, ''), created_at FROM posts WHERE user_id = ? ORDER BY id`,
+		[]interface{}{userID}, func(rows *sql.Rows) error {
+			var post models.Post
+			err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt)
+			data.Posts = append(data.Posts, post)
+			return err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export posts: %w", err)
+	}
+
+	err = queryRows(ctx, db, `SELECT id, post_id, user_id, content, created_at FROM comments WHERE user_id = ? ORDER BY id`,
+		[]interface{}{userID}, func(rows *sql.Rows) error {
+			var comment models.Comment
+			err := rows.Scan(&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.CreatedAt)
+			data.Comments = append(data.Comments, comment)
+			return err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export comments: %w", err)
+	}
+
+	err = queryRows(ctx, db, `SELECT user_id, post_id, created_at FROM likes WHERE user_id = ? ORDER BY created_at`,
+		[]interface{}{userID}, func(rows *sql.Rows) error {
+			var like models.Like
+			err := rows.Scan(&like.UserID, &like.PostID, &like.CreatedAt)
+			data.Likes = append(data.Likes, like)
+			return err
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export likes: %w", err)
+	}
+
+	follows := func(column string, dest *[]models.Follow) error {
+		query := `SELECT follower_id, following_id, created_at FROM follows WHERE ` + column + ` = ? ORDER BY created_at`
+		return queryRows(ctx, db, query, []interface{}{userID}, func(rows *sql.Rows) error {
+			var follow models.Follow
+			err := rows.Scan(&follow.FollowerID, &follow.FollowingID, &follow.CreatedAt)
+			*dest = append(*dest, follow)
+			return err
+		})
+	}
+	if err := follows("follower_id", &data.Following); err != nil {
+		return nil, fmt.Errorf("failed to export following: %w", err)
+	}
+	if err := follows("following_id", &data.Followers); err != nil {
+		return nil, fmt.Errorf("failed to export followers: %w", err)
+	}
+
+	return data, nil
+}
+
+// queryRows runs a query and calls scan for every row
+func queryRows(ctx context.Context, db Querier, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,161 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"time"
+)
+
+// GetContentOwnerID returns the author of a post or comment, including deleted ones
+func GetContentOwnerID(ctx context.Context, db Querier, targetType string, targetID int) (int, error) {
+	if targetType != models.TargetPost && targetType != models.TargetComment {
+		return 0, fmt.Errorf("%s has no owner", targetType)
+	}
+
+	var ownerID int
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM `+reportTargetTables[targetType]+` WHERE id = ?`, targetID).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, NotFound("%s with id %d not found", targetType, targetID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s owner: %w", targetType, err)
+	}
+	return ownerID, nil
+}
+
+// IsRemovedByModerator reports whether a deleted post or comment was removed by a moderator
+func IsRemovedByModerator(ctx context.Context, db Querier, targetType string, targetID int) (bool, error) {
+	if targetType != models.TargetPost && targetType != models.TargetComment {
+		return false, fmt.Errorf("%s can't be removed", targetType)
+	}
+
+	var removed bool
+	err := db.QueryRowContext(ctx, `SELECT removed_by_moderator FROM `+reportTargetTables[targetType]+` WHERE id = ?`, targetID).Scan(&removed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, NotFound("%s with id %d not found", targetType, targetID)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s: %w", targetType, err)
+	}
+	return removed, nil
+}
+
+// PurgeDeletedBefore permanently removes users, posts and comments that were soft deleted before cutoff,
+// together with everything that belongs to them. It returns the URLs of the stored media uploaded by the
+// removed users or for the removed posts, and the data export archives of the removed users, which the
+// caller is responsible for deleting. Media still used by a remaining user or post is kept.
+func PurgeDeletedBefore(ctx context.Context, db *sql.DB, cutoff time.Time) (*models.PurgeResult, error) {
+	cutoff = cutoff.UTC()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var result models.PurgeResult
+
+	// Posts and comments going away with their author are counted with the author
+	purgedUsers := `SELECT id FROM users WHERE deleted_at < ?`
+	purgedPosts := `SELECT id FROM posts WHERE deleted_at < ? OR user_id IN (` + purgedUsers + `)`
+	purgedComments := `SELECT id FROM comments WHERE deleted_at < ? OR user_id IN (` + purgedUsers + `) OR post_id IN (` + purgedPosts + `)`
+
+	// Only files the purged users uploaded go, and only once nothing that remains shows them. Any user
+	// and post can reference a URL, so the URLs of the purged rows alone don't say whose file it is.
+	purgedMedia := `
+        SELECT url FROM media_files
+        WHERE (user_id IN (` + purgedUsers + `) OR url IN (SELECT image_url FROM posts WHERE deleted_at < ? AND user_id = media_files.user_id))
+          AND url NOT IN (SELECT image_url FROM posts WHERE id NOT IN (` + purgedPosts + `))
+          AND url NOT IN (SELECT profile_image FROM users WHERE profile_image IS NOT NULL AND id NOT IN (` + purgedUsers + `))
+    `
+	purgedMediaArgs := []interface{}{cutoff, cutoff, cutoff, cutoff, cutoff}
+
+	// Collect the media first, the rows referencing it are about to be removed
+	rows, err := tx.QueryContext(ctx, purgedMedia, purgedMediaArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media to purge: %w", err)
+	}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan media url: %w", err)
+		}
+		result.MediaURLs = append(result.MediaURLs, url)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get media to purge: %w", err)
+	}
+
+	// Export archives contain the user's personal data, so they go with the user
+	exportQuery := `
+        SELECT file_path FROM data_exports
+        WHERE user_id IN (SELECT id FROM users WHERE deleted_at < ?) AND COALESCE(file_path, '') != ''
+    `
+	rows, err = tx.QueryContext(ctx, exportQuery, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exports to purge: %w", err)
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan export path: %w", err)
+		}
+		result.ExportFiles = append(result.ExportFiles, path)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get exports to purge: %w", err)
+	}
+
+	// Reports and resolutions about purged targets are removed before the targets are
+	purgedTargets := `(target_type = 'user' AND target_id IN (` + purgedUsers + `))
+        OR (target_type = 'post' AND target_id IN (` + purgedPosts + `))
+        OR (target_type = 'comment' AND target_id IN (` + purgedComments + `))`
+	purgedTargetArgs := []interface{}{cutoff, cutoff, cutoff, cutoff, cutoff, cutoff, cutoff}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+		count *int
+	}{
+		{`DELETE FROM reports WHERE ` + purgedTargets, purgedTargetArgs, nil},
+		{`DELETE FROM report_resolutions WHERE ` + purgedTargets, purgedTargetArgs, nil},
+		{`DELETE FROM likes WHERE user_id IN (` + purgedUsers + `) OR post_id IN (` + purgedPosts + `)`, []interface{}{cutoff, cutoff, cutoff}, nil},
+		{`DELETE FROM comments WHERE deleted_at < ? AND user_id NOT IN (` + purgedUsers + `) AND post_id NOT IN (` + purgedPosts + `)`, []interface{}{cutoff, cutoff, cutoff, cutoff}, &result.Comments},
+		{`DELETE FROM comments WHERE user_id IN (` + purgedUsers + `) OR post_id IN (` + purgedPosts + `)`, []interface{}{cutoff, cutoff, cutoff}, nil},
+		{`DELETE FROM imported_items WHERE user_id IN (` + purgedUsers + `) OR post_id IN (` + purgedPosts + `)`, []interface{}{cutoff, cutoff, cutoff}, nil},
+		{`DELETE FROM data_exports WHERE user_id IN (` + purgedUsers + `)`, []interface{}{cutoff}, nil},
+		{`DELETE FROM media_files WHERE url IN (` + purgedMedia + `)`, purgedMediaArgs, nil},
+		// Files of purged users still used elsewhere stay, without an uploader
+		{`DELETE FROM media_files WHERE user_id IN (` + purgedUsers + `)`, []interface{}{cutoff}, nil},
+		{`DELETE FROM follows WHERE follower_id IN (` + purgedUsers + `) OR following_id IN (` + purgedUsers + `)`, []interface{}{cutoff, cutoff}, nil},
+		{`DELETE FROM posts WHERE deleted_at < ? AND user_id NOT IN (` + purgedUsers + `)`, []interface{}{cutoff, cutoff}, &result.Posts},
+		{`DELETE FROM posts WHERE user_id IN (` + purgedUsers + `)`, []interface{}{cutoff}, nil},
+		{`DELETE FROM users WHERE deleted_at < ?`, []interface{}{cutoff}, &result.Users},
+	}
+
+	for _, statement := range statements {
+		res, err := tx.ExecContext(ctx, statement.query, statement.args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to purge deleted rows: %w", err)
+		}
+		if statement.count != nil {
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get rows affected: %w", err)
+			}
+			*statement.count = int(affected)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit purge: %w", err)
+	}
+	return &result, nil
+}
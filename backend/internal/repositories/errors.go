@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Kinds of domain errors. Handlers tell them apart with errors.Is to pick the response status.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+	ErrInvalid    = errors.New("invalid request")
+	ErrForbidden  = errors.New("forbidden")
+)
+
+// Error is a domain error. Unlike other errors, which may contain SQL or other internals and are only
+// logged, its message is meant for the client.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NotFound reports that the requested resource does not exist, or is hidden from the user
+func NotFound(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict reports that the change clashes with the current state, e.g. a duplicate
+func Conflict(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validation reports that the change is well formed but not acceptable
+func Validation(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+// Invalid reports that the request is missing something or makes no sense, e.g. following yourself
+func Invalid(format string, args ...any) error {
+	return &Error{Kind: ErrInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden reports that the user may not act on the resource
+func Forbidden(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// isUniqueViolation reports whether err is caused by a UNIQUE or PRIMARY KEY constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"time"
+)
+
+func SaveOIDCLoginState(ctx context.Context, db *sql.DB, state *models.OIDCLoginState) error {
+	query := `INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := db.ExecContext(ctx, query, state.State, state.Provider, state.Nonce, state.CodeVerifier, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCLoginState deletes and returns a login state so it can only be used once.
+// States older than maxAge, and states for other providers, are rejected.
+func ConsumeOIDCLoginState(ctx context.Context, db *sql.DB, provider, state string, maxAge time.Duration) (*models.OIDCLoginState, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var loginState models.OIDCLoginState
+	query := `SELECT state, provider, nonce, code_verifier, created_at FROM oidc_login_states WHERE state = ?`
+	err = tx.QueryRowContext(ctx, query, state).Scan(&loginState.State, &loginState.Provider, &loginState.Nonce,
+		&loginState.CodeVerifier, &loginState.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("unknown login state")
+		}
+		return nil, fmt.Errorf("failed to get login state: %w", err)
+	}
+
+	// Clean up this state together with any abandoned ones
+	_, err = tx.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE state = ? OR created_at < ?`, state, time.Now().UTC().Add(-maxAge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete login state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if loginState.Provider != provider || time.Since(loginState.CreatedAt) > maxAge {
+		return nil, fmt.Errorf("login state expired")
+	}
+
+	return &loginState, nil
+}
+
+// GetExternalIdentity returns the identity linked to the provider's subject, or nil if it was never linked.
+func GetExternalIdentity(ctx context.Context, db *sql.DB, provider, subject string) (*models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	var email sql.NullString
+
+	query := `SELECT provider, subject, user_id, email, created_at FROM external_identities WHERE provider = ? AND subject = ?`
+	err := db.QueryRowContext(ctx, query, provider, subject).Scan(&identity.Provider, &identity.Subject, &identity.UserID,
+		&email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get external identity: %w", err)
+	}
+
+	identity.Email = email.String
+	return &identity, nil
+}
+
+func LinkExternalIdentity(ctx context.Context, db *sql.DB, identity *models.ExternalIdentity) error {
+	query := `INSERT INTO external_identities (provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+	_, err := db.ExecContext(ctx, query, identity.Provider, identity.Subject, identity.UserID, identity.Email)
+	if err != nil {
+		return fmt.Errorf("failed to link external identity: %w", err)
+	}
+	return nil
+}
@@ -1,25 +1,29 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"instagram/internal/models"
 )
 
-func AddFollow(db *sql.DB, follow *models.Follow) error {
+func AddFollow(ctx context.Context, db Querier, follow *models.Follow) error {
 	query := `INSERT INTO follows (follower_id, following_id) VALUES (?, ?)`
-	_, err := db.Exec(query, follow.FollowerID, follow.FollowingID)
+	_, err := db.ExecContext(ctx, query, follow.FollowerID, follow.FollowingID)
+	if isUniqueViolation(err) {
+		return Conflict("user %d already follows user %d", follow.FollowerID, follow.FollowingID)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to add follow: %w", err)
 	}
 	return nil
 }
 
-func RemoveFollow(db *sql.DB, follow *models.Follow) error {
+func RemoveFollow(ctx context.Context, db Querier, follow *models.Follow) error {
 	query := `DELETE FROM follows WHERE follower_id = ? AND following_id = ?`
 
 	// Execute the delete query and check the number of affected rows
-	result, err := db.Exec(query, follow.FollowerID, follow.FollowingID)
+	result, err := db.ExecContext(ctx, query, follow.FollowerID, follow.FollowingID)
 	if err != nil {
 		return fmt.Errorf("failed to remove follow: %w", err)
 	}
@@ -30,8 +34,96 @@ func RemoveFollow(db *sql.DB, follow *models.Follow) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no follow relationship found between follower %d and following %d", follow.FollowerID, follow.FollowingID)
+		return NotFound("no follow relationship found between follower %d and following %d", follow.FollowerID, follow.FollowingID)
 	}
 
 	return nil
 }
+
+func FollowExists(ctx context.Context, db Querier, followerID, followingID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id = ? AND following_id = ?)`
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, query, followerID, followingID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check follow: %w", err)
+	}
+	return exists, nil
+}
+
+// activeUserCondition leaves out follows of users who deleted or deactivated their account. It expects the
+// users table to be aliased as u.
+const activeUserCondition = `u.deleted_at IS NULL AND u.deactivated_at IS NULL`
+
+// GetFollowerIDs retrieves a page of the IDs of the users following a user, most recent follow first
+func GetFollowerIDs(ctx context.Context, db Querier, userID int, page models.Page) ([]int, error) {
+	query := `
+        SELECT f.follower_id FROM follows f INNER JOIN users u ON u.id = f.follower_id
+        WHERE f.following_id = ? AND ` + activeUserCondition + `
+        ORDER BY f.created_at DESC, f.follower_id DESC` + pageClause(page)
+
+	ids, err := queryIDs(ctx, db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followers: %w", err)
+	}
+	return ids, nil
+}
+
+// GetFollowingIDs retrieves a page of the IDs of the users a user follows, most recent follow first
+func GetFollowingIDs(ctx context.Context, db Querier, userID int, page models.Page) ([]int, error) {
+	query := `
+        SELECT f.following_id FROM follows f INNER JOIN users u ON u.id = f.following_id
+        WHERE f.follower_id = ? AND ` + activeUserCondition + `
+        ORDER BY f.created_at DESC, f.following_id DESC` + pageClause(page)
+
+	ids, err := queryIDs(ctx, db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed users: %w", err)
+	}
+	return ids, nil
+}
+
+// CountFollowers counts the followers of each of the users, keyed by user ID. Users without followers
+// are left out.
+func CountFollowers(ctx context.Context, db Querier, userIDs []int) (map[int]int, error) {
+	in, args := inClause(userIDs)
+	query := `
+        SELECT f.following_id, COUNT(*) FROM follows f INNER JOIN users u ON u.id = f.follower_id
+        WHERE f.following_id IN ` + in + ` AND ` + activeUserCondition + `
+        GROUP BY f.following_id`
+
+	counts, err := countByID(ctx, db, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return counts, nil
+}
+
+// CountFollowing counts the users each of the users follows, keyed by user ID. Users who follow nobody
+// are left out.
+func CountFollowing(ctx context.Context, db Querier, userIDs []int) (map[int]int, error) {
+	in, args := inClause(userIDs)
+	query := `
+        SELECT f.follower_id, COUNT(*) FROM follows f INNER JOIN users u ON u.id = f.following_id
+        WHERE f.follower_id IN ` + in + ` AND ` + activeUserCondition + `
+        GROUP BY f.follower_id`
+
+	counts, err := countByID(ctx, db, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count followed users: %w", err)
+	}
+	return counts, nil
+}
+
+// queryIDs runs a query selecting a single ID column
+func queryIDs(ctx context.Context, db Querier, query string, args ...interface{}) ([]int, error) {
+	var ids []int
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetImportedPostID returns the post an item was imported as, or 0 if it was not imported yet
+func GetImportedPostID(ctx context.Context, db Querier, userID int, source, externalID string) (int, error) {
+	query := `SELECT post_id FROM imported_items WHERE user_id = ? AND source = ? AND external_id = ?`
+
+	var postID int
+	err := db.QueryRowContext(ctx, query, userID, source, externalID).Scan(&postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get imported item: %w", err)
+	}
+	return postID, nil
+}
+
+// AddImportedItem records that an item of a user's archive from source was imported as a post
+func AddImportedItem(ctx context.Context, db Querier, userID int, source, externalID string, postID int) error {
+	query := `INSERT INTO imported_items (user_id, source, external_id, post_id) VALUES (?, ?, ?, ?)`
+	if _, err := db.ExecContext(ctx, query, userID, source, externalID, postID); err != nil {
+		return fmt.Errorf("failed to add imported item: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+)
+
+// CountLikesForPosts counts the likes of each of the posts by users who did not delete or deactivate
+// their account, keyed by post ID. Posts without likes are left out.
+func CountLikesForPosts(ctx context.Context, db Querier, postIDs []int) (map[int]int, error) {
+	in, args := inClause(postIDs)
+	query := `
+        SELECT l.post_id, COUNT(*) FROM likes l INNER JOIN users u ON u.id = l.user_id
+        WHERE l.post_id IN ` + in + ` AND ` + activeUserCondition + `
+        GROUP BY l.post_id`
+
+	counts, err := countByID(ctx, db, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count likes: %w", err)
+	}
+	return counts, nil
+}
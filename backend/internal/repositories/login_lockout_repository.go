@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/utils"
+	"time"
+)
+
+// GetLoginLockout returns the failed login state for the user, or nil if there were no recent failures.
+func GetLoginLockout(ctx context.Context, db *sql.DB, userID int) (*models.LoginLockout, error) {
+	var lockout models.LoginLockout
+	var lockedUntil, lastFailedAt sql.NullTime
+
+	query := `SELECT user_id, failed_attempts, locked_until, last_failed_at FROM login_lockouts WHERE user_id = ?`
+	err := db.QueryRowContext(ctx, query, userID).Scan(&lockout.UserID, &lockout.FailedAttempts, &lockedUntil, &lastFailedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get login lockout: %w", err)
+	}
+
+	if lockedUntil.Valid {
+		lockout.LockedUntil = &lockedUntil.Time
+	}
+	if lastFailedAt.Valid {
+		lockout.LastFailedAt = &lastFailedAt.Time
+	}
+	return &lockout, nil
+}
+
+// RecordFailedLogin counts a failed login for the user and locks the account once the threshold is reached.
+// The count is incremented by the database, so failures of parallel logins are all counted.
+func RecordFailedLogin(ctx context.Context, db *sql.DB, userID int) (*models.LoginLockout, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	lockout := &models.LoginLockout{UserID: userID, LastFailedAt: &now}
+	var lockedUntil sql.NullTime
+
+	query := `
+        INSERT INTO login_lockouts (user_id, failed_attempts, last_failed_at)
+        VALUES (?, 1, ?)
+        ON CONFLICT(user_id) DO UPDATE SET
+            failed_attempts = login_lockouts.failed_attempts + 1,
+            last_failed_at = excluded.last_failed_at
+        RETURNING failed_attempts, locked_until
+    `
+	err = tx.QueryRowContext(ctx, query, userID, now).Scan(&lockout.FailedAttempts, &lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failed login: %w", err)
+	}
+	if lockedUntil.Valid {
+		lockout.LockedUntil = &lockedUntil.Time
+	}
+
+	if duration := utils.LoginLockoutDuration(lockout.FailedAttempts); duration > 0 {
+		until := now.Add(duration)
+		lockout.LockedUntil = &until
+		_, err = tx.ExecContext(ctx, `UPDATE login_lockouts SET locked_until = ? WHERE user_id = ?`, until, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock account: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit failed login: %w", err)
+	}
+	return lockout, nil
+}
+
+// ResetFailedLogins clears the failed login state after a successful login.
+func ResetFailedLogins(ctx context.Context, db *sql.DB, userID int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM login_lockouts WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AddMediaFile records that a user uploaded the file stored under url
+func AddMediaFile(ctx context.Context, db Querier, userID int, url string) error {
+	query := `INSERT INTO media_files (url, user_id) VALUES (?, ?)`
+	if _, err := db.ExecContext(ctx, query, url, userID); err != nil {
+		return fmt.Errorf("failed to add media file: %w", err)
+	}
+	return nil
+}
+
+// GetMediaFileUploaderID returns the user who uploaded the file stored under url
+func GetMediaFileUploaderID(ctx context.Context, db Querier, url string) (int, error) {
+	var userID int
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM media_files WHERE url = ?`, url).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, NotFound("media file %s not found", url)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get media file: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrations are the numbered SQL files that build the SQLite schema, applied in order. Applied
+// migrations must never be changed, changes to the schema go in a new file and in
+// sql/inititialize_db.sql, which must stay equal to the result of all migrations.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies the migrations that have not been applied to the SQLite database yet, in one
+// transaction. Databases created before migrations existed have no schema_migrations table and get every
+// migration, the first one only creates what is missing.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	names, err := migrationNames()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current, err := schemaVersion(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		version, err := MigrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+
+		statements, err := migrations.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", name, err)
+		}
+		slog.Info("Applied database migration", "migration", name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
+
+// CheckSchema returns an error unless every migration has been applied to the database, and no
+// migration unknown to this build
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	names, err := migrationNames()
+	if err != nil {
+		return err
+	}
+	latest, err := MigrationVersion(names[len(names)-1])
+	if err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return err
+	}
+	if current != latest {
+		return fmt.Errorf("database schema is at version %d, expected %d", current, latest)
+	}
+	return nil
+}
+
+// MigrationVersion returns the number a migration file name starts with, e.g. 1 for 0001_initial_schema.sql
+func MigrationVersion(name string) (int, error) {
+	base := name[strings.LastIndex(name, "/")+1:]
+	number, _, ok := strings.Cut(base, "_")
+	if !ok {
+		return 0, fmt.Errorf("migration %s is not named <version>_<description>.sql", name)
+	}
+	version, err := strconv.Atoi(number)
+	if err != nil {
+		return 0, fmt.Errorf("migration %s is not named <version>_<description>.sql", name)
+	}
+	return version, nil
+}
+
+func migrationNames() ([]string, error) {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// schemaVersion returns the version of the last applied migration, 0 if there is none
+func schemaVersion(ctx context.Context, db Querier) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,162 @@
+-- Two-factor authentication, sessions, access tokens, roles, moderation, the audit log, deletion, data
+-- exports and imports
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN suspended_at DATETIME;
+ALTER TABLE users ADD COLUMN suspended_until DATETIME;
+ALTER TABLE users ADD COLUMN deactivated_at DATETIME;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+ALTER TABLE posts ADD COLUMN deleted_at DATETIME;
+ALTER TABLE comments ADD COLUMN deleted_at DATETIME;
+
+CREATE TABLE user_totp (
+                           user_id INTEGER PRIMARY KEY,
+                           secret TEXT NOT NULL,
+                           confirmed_at DATETIME,
+                           created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                           FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE recovery_codes (
+                                id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                user_id INTEGER NOT NULL,
+                                code_hash TEXT NOT NULL,
+                                used_at DATETIME,
+                                created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                UNIQUE(user_id, code_hash),
+                                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE sessions (
+                          id INTEGER PRIMARY KEY AUTOINCREMENT,
+                          user_id INTEGER NOT NULL,
+                          user_agent TEXT,
+                          ip_address TEXT,
+                          created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                          last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                          revoked_at DATETIME,
+                          FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE login_lockouts (
+                                user_id INTEGER PRIMARY KEY,
+                                failed_attempts INTEGER NOT NULL DEFAULT 0,
+                                locked_until DATETIME,
+                                last_failed_at DATETIME,
+                                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE oidc_login_states (
+                                   state TEXT PRIMARY KEY,
+                                   provider TEXT NOT NULL,
+                                   nonce TEXT NOT NULL,
+                                   code_verifier TEXT NOT NULL,
+                                   created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE external_identities (
+                                     provider TEXT NOT NULL,
+                                     subject TEXT NOT NULL,
+                                     user_id INTEGER NOT NULL,
+                                     email TEXT,
+                                     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                     PRIMARY KEY(provider, subject),
+                                     FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE personal_access_tokens (
+                                        id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                        user_id INTEGER NOT NULL,
+                                        name TEXT NOT NULL,
+                                        token_hash TEXT NOT NULL UNIQUE,
+                                        scopes TEXT NOT NULL,
+                                        created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                        last_used_at DATETIME,
+                                        expires_at DATETIME,
+                                        revoked_at DATETIME,
+                                        FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE moderation_actions (
+                                    id INTEGER PRIMARY KEY AUTOINCREMENT,
+                                    moderator_id INTEGER,
+                                    action TEXT NOT NULL,
+                                    target_type TEXT NOT NULL,
+                                    target_id INTEGER NOT NULL,
+                                    reason TEXT NOT NULL,
+                                    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                    FOREIGN KEY(moderator_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE reports (
+                         id INTEGER PRIMARY KEY AUTOINCREMENT,
+                         reporter_id INTEGER NOT NULL,
+                         target_type TEXT NOT NULL,
+                         target_id INTEGER NOT NULL,
+                         reason TEXT NOT NULL,
+                         severity INTEGER NOT NULL,
+                         details TEXT,
+                         resolved_at DATETIME,
+                         created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                         UNIQUE(reporter_id, target_type, target_id),
+                         FOREIGN KEY(reporter_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE report_resolutions (
+                                    target_type TEXT NOT NULL,
+                                    target_id INTEGER NOT NULL,
+                                    resolution TEXT NOT NULL,
+                                    moderator_id INTEGER,
+                                    resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                    PRIMARY KEY(target_type, target_id),
+                                    FOREIGN KEY(moderator_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- The audit log deliberately has no foreign keys so entries outlive the users and content they describe
+CREATE TABLE audit_log (
+                           id INTEGER PRIMARY KEY AUTOINCREMENT,
+                           action TEXT NOT NULL,
+                           actor_id INTEGER,
+                           target_type TEXT,
+                           target_id INTEGER,
+                           ip_address TEXT NOT NULL,
+                           request_id TEXT NOT NULL,
+                           details TEXT,
+                           created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_audit_log_actor ON audit_log(actor_id, created_at);
+CREATE INDEX idx_audit_log_target ON audit_log(target_type, target_id, created_at);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TABLE data_exports (
+                              id INTEGER PRIMARY KEY AUTOINCREMENT,
+                              user_id INTEGER NOT NULL,
+                              status TEXT NOT NULL DEFAULT 'pending',
+                              file_path TEXT,
+                              error TEXT,
+                              created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                              completed_at DATETIME,
+                              expires_at DATETIME,
+                              FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Remembers what was imported from other services so importing the same archive twice does not duplicate posts
+CREATE TABLE imported_items (
+                                user_id INTEGER NOT NULL,
+                                source TEXT NOT NULL,
+                                external_id TEXT NOT NULL,
+                                post_id INTEGER NOT NULL,
+                                created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+                                PRIMARY KEY(user_id, source, external_id),
+                                FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+                                FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
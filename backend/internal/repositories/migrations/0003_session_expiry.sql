@@ -0,0 +1,3 @@
+-- Sessions expire with the token they were issued for. Existing sessions got tokens valid for a day.
+ALTER TABLE sessions ADD COLUMN expires_at DATETIME;
+UPDATE sessions SET expires_at = datetime(created_at, '+1 day');
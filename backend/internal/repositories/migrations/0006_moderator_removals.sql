@@ -0,0 +1,13 @@
+-- Posts and comments removed by a moderator can only be restored by an admin. Who removed them is in
+-- moderation_actions.
+ALTER TABLE posts ADD COLUMN removed_by_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE comments ADD COLUMN removed_by_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE posts SET removed_by_moderator = TRUE
+WHERE deleted_at IS NOT NULL AND EXISTS (
+    SELECT 1 FROM moderation_actions m WHERE m.action = 'remove_post' AND m.target_type = 'post' AND m.target_id = posts.id
+);
+UPDATE comments SET removed_by_moderator = TRUE
+WHERE deleted_at IS NOT NULL AND EXISTS (
+    SELECT 1 FROM moderation_actions m WHERE m.action = 'remove_comment' AND m.target_type = 'comment' AND m.target_id = comments.id
+);
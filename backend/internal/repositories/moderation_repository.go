@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+)
+
+func AddModerationAction(ctx context.Context, db *sql.DB, action *models.ModerationAction) error {
+	query := `
+        INSERT INTO moderation_actions (moderator_id, action, target_type, target_id, reason, created_at)
+        VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+    `
+	_, err := db.ExecContext(ctx, query, action.ModeratorID, action.Action, action.TargetType, action.TargetID, action.Reason)
+	if err != nil {
+		return fmt.Errorf("failed to add moderation action: %w", err)
+	}
+	return nil
+}
+
+// GetRecentModerationActions returns the latest moderation actions, newest first
+func GetRecentModerationActions(ctx context.Context, db *sql.DB, limit int) ([]models.ModerationAction, error) {
+	query := `
+        SELECT id, COALESCE(moderator_id, 0), action, target_type, target_id, reason, created_at
+        FROM moderation_actions
+        ORDER BY created_at DESC, id DESC
+        LIMIT ?
+    `
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get moderation actions: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var actions []models.ModerationAction
+	for rows.Next() {
+		var action models.ModerationAction
+		err := rows.Scan(&action.ID, &action.ModeratorID, &action.Action, &action.TargetType, &action.TargetID,
+			&action.Reason, &action.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan moderation action: %w", err)
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
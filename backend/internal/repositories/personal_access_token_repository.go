@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"strings"
+	"time"
+)
+
+// tokenTouchInterval limits how often last_used_at is written for a personal access token
+const tokenTouchInterval = time.Minute
+
+func CreatePersonalAccessToken(ctx context.Context, db *sql.DB, token *models.PersonalAccessToken) (*models.PersonalAccessToken, error) {
+	query := `
+        INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, created_at, expires_at)
+        VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, ?)
+    `
+	result, err := db.ExecContext(ctx, query, token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create access token: %w", err)
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last insert id: %w", err)
+	}
+
+	return getPersonalAccessToken(ctx, db, `WHERE id = ?`, lastInsertID)
+}
+
+// GetPersonalAccessTokenByHash returns the token with the given hash, or nil if there is none.
+func GetPersonalAccessTokenByHash(ctx context.Context, db *sql.DB, tokenHash string) (*models.PersonalAccessToken, error) {
+	token, err := getPersonalAccessToken(ctx, db, `WHERE token_hash = ?`, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+func getPersonalAccessToken(ctx context.Context, db *sql.DB, where string, args ...interface{}) (*models.PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at, expires_at, revoked_at
+        FROM personal_access_tokens
+    ` + where
+
+	token, err := scanPersonalAccessToken(db.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return token, nil
+}
+
+// GetPersonalAccessTokensForUser lists the user's tokens that have not been revoked, newest first.
+func GetPersonalAccessTokensForUser(ctx context.Context, db *sql.DB, userID int) ([]models.PersonalAccessToken, error) {
+	query := `
+        SELECT id, user_id, name, token_hash, scopes, created_at, last_used_at, expires_at, revoked_at
+        FROM personal_access_tokens
+        WHERE user_id = ? AND revoked_at IS NULL
+        ORDER BY created_at DESC, id DESC
+    `
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access tokens: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var tokens []models.PersonalAccessToken
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access token: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPersonalAccessToken(row scanner) (*models.PersonalAccessToken, error) {
+	var token models.PersonalAccessToken
+	var scopes string
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &scopes, &token.CreatedAt,
+		&lastUsedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// TouchPersonalAccessToken records that the token was just used. Writes are throttled to once per tokenTouchInterval.
+func TouchPersonalAccessToken(ctx context.Context, db *sql.DB, tokenID int) error {
+	query := `UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, query, now, tokenID, now.Add(-tokenTouchInterval))
+	if err != nil {
+		return fmt.Errorf("failed to touch access token: %w", err)
+	}
+	return nil
+}
+
+// RevokePersonalAccessToken revokes one of the user's tokens. Tokens owned by other users are reported as not found.
+func RevokePersonalAccessToken(ctx context.Context, db *sql.DB, userID, tokenID int) error {
+	query := `UPDATE personal_access_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := db.ExecContext(ctx, query, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return NotFound("access token with id %d not found", tokenID)
+	}
+
+	return nil
+}
@@ -1,23 +1,40 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"instagram/internal/logging"
 	"instagram/internal/models"
+	"time"
 )
 
-func AddPost(db *sql.DB, post *models.Post) error {
+// AddPost saves a new post and sets its ID. Posts without a creation time are created now.
+func AddPost(ctx context.Context, db Querier, post *models.Post) error {
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now().UTC()
+	}
+
 	query := `INSERT INTO posts (user_id, image_url, caption, created_at) VALUES (?, ?, ?, ?)`
-	_, err := db.Exec(query, post.UserID, post.ImageURL, post.Caption, post.CreatedAt)
+	result, err := db.ExecContext(ctx, query, post.UserID, post.ImageURL, post.Caption, post.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to add post: %w", err)
 	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve last insert id: %w", err)
+	}
+	post.ID = int(id)
 	return nil
 }
 
-func DeletePost(db *sql.DB, postID int) error {
-	query := `DELETE FROM posts WHERE id = ?`
-	result, err := db.Exec(query, postID)
+// DeletePost soft deletes the post. It can be restored until it is purged, by an admin only if a
+// moderator removed it.
+func DeletePost(ctx context.Context, db Querier, postID int, byModerator bool) error {
+	query := `UPDATE posts SET deleted_at = ?, removed_by_moderator = ? WHERE id = ? AND deleted_at IS NULL`
+	result, err := db.ExecContext(ctx, query, time.Now().UTC(), byModerator, postID)
 	if err != nil {
 		return fmt.Errorf("failed to delete post: %w", err)
 	}
@@ -28,18 +45,44 @@ func DeletePost(db *sql.DB, postID int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("post with id %d not found", postID)
+		return NotFound("post with id %d not found", postID)
+	}
+
+	return nil
+}
+
+// RestorePost undoes the soft deletion of a post that has not been purged yet
+func RestorePost(ctx context.Context, db Querier, postID int) error {
+	query := `UPDATE posts SET deleted_at = NULL, removed_by_moderator = FALSE WHERE id = ? AND deleted_at IS NOT NULL`
+	result, err := db.ExecContext(ctx, query, postID)
+	if err != nil {
+		return fmt.Errorf("failed to restore post: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return NotFound("deleted post with id %d not found", postID)
 	}
 
 	return nil
 }
 
-func GetPostByID(db *sql.DB, postID int) (*models.Post, error) {
-	query := `SELECT id, user_id, image_url, caption, created_at FROM posts WHERE id = ?`
-	row := db.QueryRow(query, postID)
+// GetPostByID retrieves a post that wasn't deleted. Posts nobody but their author should see are
+// returned too, with Hidden set.
+func GetPostByID(ctx context.Context, db Querier, postID int) (*models.Post, error) {
+	query := `SELECT p.id, p.user_id, p.image_url, p.caption, p.created_at, NOT (` + visiblePostCondition + `)
+        FROM posts p WHERE p.id = ? AND p.deleted_at IS NULL`
+	row := db.QueryRowContext(ctx, query, postID)
 
 	var post models.Post
-	err := row.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt)
+	err := row.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt, &post.Hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, NotFound("post with id %d not found", postID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get post: %w", err)
 	}
@@ -47,10 +90,33 @@ func GetPostByID(db *sql.DB, postID int) (*models.Post, error) {
 	return &post, nil
 }
 
-func GetPostsForUser(db *sql.DB, userID int) ([]models.Post, error) {
-	query := `SELECT id, user_id, image_url, caption, created_at FROM posts WHERE user_id = ?`
+// GetPostsByIDs retrieves the visible posts with the given IDs, keyed by ID
+func GetPostsByIDs(ctx context.Context, db Querier, ids []int) (map[int]*models.Post, error) {
+	in, args := inClause(ids)
+	query := `SELECT p.id, p.user_id, p.image_url, COALESCE(p.caption, ''), p.created_at FROM posts p WHERE p.id IN ` + in + ` AND ` + visiblePostCondition
+
+	posts := make(map[int]*models.Post, len(ids))
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var post models.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt); err != nil {
+			return err
+		}
+		posts[post.ID] = &post
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// GetPostsForUser retrieves a page of the posts of a user, newest first
+func GetPostsForUser(ctx context.Context, db Querier, userID int, page models.Page) ([]models.Post, error) {
+	query := `SELECT p.id, p.user_id, p.image_url, p.caption, p.created_at FROM posts p WHERE p.user_id = ? AND ` + visiblePostCondition +
+		` ORDER BY p.created_at DESC, p.id DESC` + pageClause(page)
 
-	rows, err := db.Query(query, userID)
+	rows, err := db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get posts: %w", err)
 	}
@@ -58,7 +124,7 @@ func GetPostsForUser(db *sql.DB, userID int) ([]models.Post, error) {
 	defer func(rows *sql.Rows) {
 		err := rows.Close()
 		if err != nil {
-			fmt.Printf("failed to close rows: %v\n", err)
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
 		}
 	}(rows)
 
@@ -75,47 +141,67 @@ func GetPostsForUser(db *sql.DB, userID int) ([]models.Post, error) {
 	return posts, nil
 }
 
-// GetPostsForUserFeed retrieves posts for a user's feed based on the people they follow.
-func GetPostsForUserFeed(db *sql.DB, userID int) ([]models.FeedPost, error) {
-	// SQL query to get all posts and user info from users the given user follows
-	query := `
+// feedColumns are the columns of a feed post, scanned by queryFeed
+const feedColumns = `
         SELECT p.id, p.user_id, p.image_url, p.caption, p.created_at,
-               u.id, u.username, u.email, u.bio, u.profile_image
+               u.id, u.username, u.email, COALESCE(u.bio, ''), COALESCE(u.profile_image, '')
         FROM posts p
         INNER JOIN follows f ON p.user_id = f.following_id
-        INNER JOIN users u ON p.user_id = u.id
-        WHERE f.follower_id = ?
-        ORDER BY p.created_at DESC
-    `
+        INNER JOIN users u ON p.user_id = u.id`
 
-	// Execute the query
-	rows, err := db.Query(query, userID)
+// GetPostsForUserFeed retrieves a page of a user's feed based on the people they follow.
+// Posts hidden by a moderator are left out.
+func GetPostsForUserFeed(ctx context.Context, db Querier, userID int, page models.Page) ([]models.FeedPost, error) {
+	// SQL query to get all posts and user info from users the given user follows
+	query := feedColumns + `
+        WHERE f.follower_id = ? AND ` + visiblePostCondition + `
+        ORDER BY p.created_at DESC, p.id DESC
+    ` + pageClause(page)
+
+	feedPosts, err := queryFeed(ctx, db, query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get posts for user feed: %w", err)
 	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			fmt.Printf("failed to close rows: %v\n", err)
-		}
-	}(rows)
+	return feedPosts, nil
+}
+
+// GetFeedPostsAfter retrieves up to limit posts of a user's feed with an ID above afterID, in the order
+// they were published, to follow the feed as posts come in
+func GetFeedPostsAfter(ctx context.Context, db Querier, userID, afterID, limit int) ([]models.FeedPost, error) {
+	query := feedColumns + `
+        WHERE f.follower_id = ? AND p.id > ? AND ` + visiblePostCondition + `
+        ORDER BY p.id
+    ` + pageClause(models.Page{Limit: limit})
+
+	feedPosts, err := queryFeed(ctx, db, query, userID, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new posts for user feed: %w", err)
+	}
+	return feedPosts, nil
+}
+
+// GetLatestPostID returns the ID of the most recently published post, or 0 if there is none
+func GetLatestPostID(ctx context.Context, db Querier) (int, error) {
+	var id int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM posts`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest post: %w", err)
+	}
+	return id, nil
+}
 
-	// Collect posts and user info into a slice of FeedPost
+// queryFeed runs a query selecting feedColumns and collects the posts with their authors
+func queryFeed(ctx context.Context, db Querier, query string, args ...interface{}) ([]models.FeedPost, error) {
 	var feedPosts []models.FeedPost
-	for rows.Next() {
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
 		var post models.Post
 		var user models.User
 		if err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt,
 			&user.ID, &user.Username, &user.Email, &user.Bio, &user.ProfileImage); err != nil {
-			return nil, fmt.Errorf("failed to scan post and user: %w", err)
-		}
-
-		feedPost := models.FeedPost{
-			User: user,
-			Post: post,
+			return fmt.Errorf("failed to scan post and user: %w", err)
 		}
-		feedPosts = append(feedPosts, feedPost)
-	}
-
-	return feedPosts, nil
+		feedPosts = append(feedPosts, models.FeedPost{User: user, Post: post})
+		return nil
+	})
+	return feedPosts, err
 }
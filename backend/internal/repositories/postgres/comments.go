@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"time"
+)
+
+type comments struct{ db repositories.Querier }
+
+func (s comments) Create(ctx context.Context, comment *models.Comment) error {
+	query := `INSERT INTO comments (user_id, post_id, content, created_at) VALUES ($1, $2, $3, CURRENT_TIMESTAMP) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, comment.UserID, comment.PostID, comment.Content).Scan(&comment.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add comment: %w", err)
+	}
+	return nil
+}
+
+func (s comments) Get(ctx context.Context, id int) (*models.Comment, error) {
+	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at, NOT (` + visibleCommentCondition + `)
+        FROM comments c WHERE c.id = $1 AND c.deleted_at IS NULL`
+
+	var comment models.Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content, &comment.CreatedAt, &comment.Hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NotFound("comment with id %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	return &comment, nil
+}
+
+func (s comments) OwnerID(ctx context.Context, id int) (int, error) {
+	return ownerID(ctx, s.db, "comments", models.TargetComment, id)
+}
+
+func (s comments) ListForPost(ctx context.Context, postID int, page models.Page) ([]models.Comment, error) {
+	query := `SELECT c.id, c.user_id, c.post_id, c.content, c.created_at FROM comments c
+        WHERE c.post_id = $1 AND ` + visibleCommentCondition + `
+        ORDER BY c.id` + pageClause(page)
+
+	list, err := queryComments(ctx, s.db, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	return list, nil
+}
+
+func (s comments) FirstForPosts(ctx context.Context, postIDs []int, limit int) (map[int][]models.Comment, error) {
+	query := `
+        SELECT id, user_id, post_id, content, created_at FROM (
+            SELECT c.id, c.user_id, c.post_id, c.content, c.created_at,
+                   ROW_NUMBER() OVER (PARTITION BY c.post_id ORDER BY c.id) AS position
+            FROM comments c
+            WHERE c.post_id = ANY($1) AND ` + visibleCommentCondition + `
+        ) ranked
+        WHERE position <= $2
+        ORDER BY post_id, id`
+
+	list, err := queryComments(ctx, s.db, query, ids(postIDs), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+
+	byPost := make(map[int][]models.Comment, len(postIDs))
+	for _, comment := range list {
+		byPost[comment.PostID] = append(byPost[comment.PostID], comment)
+	}
+	return byPost, nil
+}
+
+func (s comments) CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error) {
+	query := `SELECT c.post_id, COUNT(*) FROM comments c WHERE c.post_id = ANY($1) AND ` + visibleCommentCondition + ` GROUP BY c.post_id`
+
+	counts, err := countByID(ctx, s.db, query, ids(postIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return counts, nil
+}
+
+func (s comments) Delete(ctx context.Context, id int) error {
+	return s.delete(ctx, id, false)
+}
+
+func (s comments) Remove(ctx context.Context, id int) error {
+	return s.delete(ctx, id, true)
+}
+
+func (s comments) delete(ctx context.Context, id int, byModerator bool) error {
+	query := `UPDATE comments SET deleted_at = $1, removed_by_moderator = $2 WHERE id = $3 AND deleted_at IS NULL`
+	return execOne(ctx, s.db, repositories.NotFound("comment with id %d not found", id), "delete comment", query, time.Now().UTC(), byModerator, id)
+}
+
+func (s comments) RemovedByModerator(ctx context.Context, id int) (bool, error) {
+	return removedByModerator(ctx, s.db, "comments", models.TargetComment, id)
+}
+
+func (s comments) Restore(ctx context.Context, id int) error {
+	query := `UPDATE comments SET deleted_at = NULL, removed_by_moderator = FALSE WHERE id = $1 AND deleted_at IS NOT NULL`
+	return execOne(ctx, s.db, repositories.NotFound("deleted comment with id %d not found", id), "restore comment", query, id)
+}
+
+func queryComments(ctx context.Context, db repositories.Querier, query string, args ...interface{}) ([]models.Comment, error) {
+	var list []models.Comment
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var comment models.Comment
+		if err := rows.Scan(&comment.ID, &comment.UserID, &comment.PostID, &comment.Content, &comment.CreatedAt); err != nil {
+			return err
+		}
+		list = append(list, comment)
+		return nil
+	})
+	return list, err
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+)
+
+type follows struct{ db repositories.Querier }
+
+func (s follows) Follow(ctx context.Context, follow *models.Follow) error {
+	query := `INSERT INTO follows (follower_id, following_id) VALUES ($1, $2)`
+	_, err := s.db.ExecContext(ctx, query, follow.FollowerID, follow.FollowingID)
+	if isUniqueViolation(err) {
+		return repositories.Conflict("user %d already follows user %d", follow.FollowerID, follow.FollowingID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add follow: %w", err)
+	}
+	return nil
+}
+
+func (s follows) Unfollow(ctx context.Context, follow *models.Follow) error {
+	query := `DELETE FROM follows WHERE follower_id = $1 AND following_id = $2`
+	notFound := repositories.NotFound("no follow relationship found between follower %d and following %d", follow.FollowerID, follow.FollowingID)
+	return execOne(ctx, s.db, notFound, "remove follow", query, follow.FollowerID, follow.FollowingID)
+}
+
+func (s follows) Exists(ctx context.Context, followerID, followingID int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id = $1 AND following_id = $2)`
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, followerID, followingID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check follow: %w", err)
+	}
+	return exists, nil
+}
+
+func (s follows) FollowerIDs(ctx context.Context, userID int, page models.Page) ([]int, error) {
+	query := `
+        SELECT f.follower_id FROM follows f INNER JOIN users u ON u.id = f.follower_id
+        WHERE f.following_id = $1 AND ` + activeUserCondition + `
+        ORDER BY f.created_at DESC, f.follower_id DESC` + pageClause(page)
+
+	followerIDs, err := queryIDs(ctx, s.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followers: %w", err)
+	}
+	return followerIDs, nil
+}
+
+func (s follows) FollowingIDs(ctx context.Context, userID int, page models.Page) ([]int, error) {
+	query := `
+        SELECT f.following_id FROM follows f INNER JOIN users u ON u.id = f.following_id
+        WHERE f.follower_id = $1 AND ` + activeUserCondition + `
+        ORDER BY f.created_at DESC, f.following_id DESC` + pageClause(page)
+
+	followingIDs, err := queryIDs(ctx, s.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get followed users: %w", err)
+	}
+	return followingIDs, nil
+}
+
+func (s follows) CountFollowers(ctx context.Context, userIDs []int) (map[int]int, error) {
+	query := `
+        SELECT f.following_id, COUNT(*) FROM follows f INNER JOIN users u ON u.id = f.follower_id
+        WHERE f.following_id = ANY($1) AND ` + activeUserCondition + `
+        GROUP BY f.following_id`
+
+	counts, err := countByID(ctx, s.db, query, ids(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count followers: %w", err)
+	}
+	return counts, nil
+}
+
+func (s follows) CountFollowing(ctx context.Context, userIDs []int) (map[int]int, error) {
+	query := `
+        SELECT f.follower_id, COUNT(*) FROM follows f INNER JOIN users u ON u.id = f.following_id
+        WHERE f.follower_id = ANY($1) AND ` + activeUserCondition + `
+        GROUP BY f.follower_id`
+
+	counts, err := countByID(ctx, s.db, query, ids(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count followed users: %w", err)
+	}
+	return counts, nil
+}
+
+// queryIDs runs a query selecting a single ID column
+func queryIDs(ctx context.Context, db repositories.Querier, query string, args ...interface{}) ([]int, error) {
+	var list []int
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		list = append(list, id)
+		return nil
+	})
+	return list, err
+}
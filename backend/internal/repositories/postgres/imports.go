@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/repositories"
+)
+
+type imports struct{ db repositories.Querier }
+
+func (s imports) Add(ctx context.Context, userID int, source, externalID string, postID int) error {
+	query := `INSERT INTO imported_items (user_id, source, external_id, post_id) VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.ExecContext(ctx, query, userID, source, externalID, postID); err != nil {
+		return fmt.Errorf("failed to add imported item: %w", err)
+	}
+	return nil
+}
+
+func (s imports) PostID(ctx context.Context, userID int, source, externalID string) (int, error) {
+	query := `SELECT post_id FROM imported_items WHERE user_id = $1 AND source = $2 AND external_id = $3`
+
+	var postID int
+	err := s.db.QueryRowContext(ctx, query, userID, source, externalID).Scan(&postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get imported item: %w", err)
+	}
+	return postID, nil
+}
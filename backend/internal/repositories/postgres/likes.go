@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"instagram/internal/repositories"
+)
+
+type likes struct{ db repositories.Querier }
+
+func (s likes) CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error) {
+	query := `
+        SELECT l.post_id, COUNT(*) FROM likes l INNER JOIN users u ON u.id = l.user_id
+        WHERE l.post_id = ANY($1) AND ` + activeUserCondition + `
+        GROUP BY l.post_id`
+
+	counts, err := countByID(ctx, s.db, query, ids(postIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count likes: %w", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/repositories"
+)
+
+type media struct{ db repositories.Querier }
+
+func (s media) Add(ctx context.Context, userID int, url string) error {
+	query := `INSERT INTO media_files (url, user_id) VALUES ($1, $2)`
+	if _, err := s.db.ExecContext(ctx, query, url, userID); err != nil {
+		return fmt.Errorf("failed to add media file: %w", err)
+	}
+	return nil
+}
+
+func (s media) UploaderID(ctx context.Context, url string) (int, error) {
+	var userID int
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM media_files WHERE url = $1`, url).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repositories.NotFound("media file %s not found", url)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get media file: %w", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"instagram/internal/repositories"
+	"io/fs"
+	"log/slog"
+	"sort"
+)
+
+// migrations are numbered SQL files, applied in order. Applied migrations must never be changed, changes
+// to the schema go in a new file.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID keeps instances that start at the same time from applying the same migrations
+const migrationLockID = 7_305_421_993
+
+// Migrate applies the migrations that have not been applied to the database yet, in one transaction
+func Migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID)
+	if err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	for _, name := range names {
+		version, err := repositories.MigrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+
+		statements, err := migrations.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			return fmt.Errorf("failed to record migration %s: %w", name, err)
+		}
+		slog.Info("Applied database migration", "migration", name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	return nil
+}
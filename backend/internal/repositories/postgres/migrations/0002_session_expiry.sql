@@ -0,0 +1,3 @@
+-- Sessions expire with the token they were issued for. Existing sessions got tokens valid for a day.
+ALTER TABLE sessions ADD COLUMN expires_at TIMESTAMPTZ;
+UPDATE sessions SET expires_at = created_at + INTERVAL '1 day';
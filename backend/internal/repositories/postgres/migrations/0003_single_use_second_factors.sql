@@ -0,0 +1,7 @@
+-- TOTP codes and MFA challenge tokens can only be used once
+ALTER TABLE user_totp ADD COLUMN last_used_step BIGINT;
+
+CREATE TABLE used_mfa_challenges (
+    token_id TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
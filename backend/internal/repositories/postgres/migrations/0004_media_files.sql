@@ -0,0 +1,12 @@
+-- Files stored in the media directory are recorded with the user who uploaded them. Only that user may
+-- use them in posts and profiles, and only their rows take the files along when they are purged. The
+-- files stored so far are the images of imported posts.
+CREATE TABLE media_files (
+    url TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO media_files (url, user_id)
+SELECT p.image_url, p.user_id FROM posts p INNER JOIN imported_items i ON i.post_id = p.id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,170 @@
+// Package postgres implements the stores of the repositories package on PostgreSQL. The schema is created
+// and upgraded by Migrate from the migrations directory.
+//
+// The server does not run on PostgreSQL yet: only users, posts, comments, follows and sessions have
+// stores, while authentication, MFA, access tokens, the audit log, reports, moderation, exports and
+// imports still run SQLite statements on a *sql.DB. Those need stores before a database setting can
+// offer PostgreSQL.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+
+	"github.com/lib/pq"
+)
+
+// Driver is the database/sql driver name of PostgreSQL
+const Driver = "postgres"
+
+// NewStores returns the stores of a PostgreSQL database, which must have been migrated
+func NewStores(db *sql.DB) *repositories.Stores {
+	stores := newStores(db)
+	stores.Tx = func(ctx context.Context, fn func(tx *repositories.Stores) error) error {
+		return repositories.RunInTx(ctx, db, newStores, fn)
+	}
+	return stores
+}
+
+func newStores(db repositories.Querier) *repositories.Stores {
+	return &repositories.Stores{
+		Users:    users{db},
+		Posts:    posts{db},
+		Comments: comments{db},
+		Follows:  follows{db},
+		Likes:    likes{db},
+		Sessions: sessions{db},
+		Media:    media{db},
+		Imports:  imports{db},
+	}
+}
+
+// Conditions that leave out posts and comments nobody but their author should see, like those of the
+// SQLite stores. They expect the posts and comments tables to be aliased as p and c.
+const (
+	visiblePostCondition = `p.deleted_at IS NULL
+        AND EXISTS (SELECT 1 FROM users au WHERE au.id = p.user_id AND au.deleted_at IS NULL AND au.deactivated_at IS NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM report_resolutions rr
+            WHERE rr.resolution = 'hidden'
+              AND ((rr.target_type = 'post' AND rr.target_id = p.id) OR (rr.target_type = 'user' AND rr.target_id = p.user_id))
+        )`
+	visibleCommentCondition = `c.deleted_at IS NULL
+        AND EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id AND ` + visiblePostCondition + `)
+        AND EXISTS (SELECT 1 FROM users au WHERE au.id = c.user_id AND au.deleted_at IS NULL AND au.deactivated_at IS NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM report_resolutions rr
+            WHERE rr.resolution = 'hidden'
+              AND ((rr.target_type = 'comment' AND rr.target_id = c.id) OR (rr.target_type = 'user' AND rr.target_id = c.user_id))
+        )`
+
+	// activeUserCondition leaves out users who deleted or deactivated their account, aliased as u
+	activeUserCondition = `u.deleted_at IS NULL AND u.deactivated_at IS NULL`
+)
+
+// pageClause returns the LIMIT and OFFSET clauses selecting a page of an ordered query, to be appended last
+func pageClause(page models.Page) string {
+	var clause string
+	if page.Limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", page.Limit)
+	}
+	if page.Offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", page.Offset)
+	}
+	return clause
+}
+
+// ids converts IDs to an array argument, to be compared with = ANY($n)
+func ids(values []int) interface{} {
+	array := make(pq.Int64Array, len(values))
+	for i, value := range values {
+		array[i] = int64(value)
+	}
+	return array
+}
+
+// isUniqueViolation reports whether err is caused by a UNIQUE or PRIMARY KEY constraint
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// ownerID returns the user_id of a row of table, including soft deleted rows
+func ownerID(ctx context.Context, db repositories.Querier, table, name string, id int) (int, error) {
+	var userID int
+	err := db.QueryRowContext(ctx, `SELECT user_id FROM `+table+` WHERE id = $1`, id).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, repositories.NotFound("%s with id %d not found", name, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s owner: %w", name, err)
+	}
+	return userID, nil
+}
+
+// removedByModerator reports whether a soft deleted row of table was removed by a moderator
+func removedByModerator(ctx context.Context, db repositories.Querier, table, name string, id int) (bool, error) {
+	var removed bool
+	err := db.QueryRowContext(ctx, `SELECT removed_by_moderator FROM `+table+` WHERE id = $1`, id).Scan(&removed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, repositories.NotFound("%s with id %d not found", name, id)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+	return removed, nil
+}
+
+// execOne runs a statement that changes a single row and returns notFound if it changed none
+func execOne(ctx context.Context, db repositories.Querier, notFound error, action, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// queryRows runs a query and calls scan for every row
+func queryRows(ctx context.Context, db repositories.Querier, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// countByID runs a query selecting an ID and a count per row, and returns the counts keyed by ID
+func countByID(ctx context.Context, db repositories.Querier, query string, args ...interface{}) (map[int]int, error) {
+	counts := make(map[int]int)
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var id, count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return err
+		}
+		counts[id] = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"time"
+)
+
+type posts struct{ db repositories.Querier }
+
+// feedColumns are the columns of a feed post, scanned by queryFeed
+const feedColumns = `
+        SELECT p.id, p.user_id, p.image_url, COALESCE(p.caption, ''), p.created_at,
+               u.id, u.username, u.email, COALESCE(u.bio, ''), COALESCE(u.profile_image, '')
+        FROM posts p
+        INNER JOIN follows f ON p.user_id = f.following_id
+        INNER JOIN users u ON p.user_id = u.id`
+
+func (s posts) Create(ctx context.Context, post *models.Post) error {
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now().UTC()
+	}
+
+	query := `INSERT INTO posts (user_id, image_url, caption, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, post.UserID, post.ImageURL, post.Caption, post.CreatedAt).Scan(&post.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add post: %w", err)
+	}
+	return nil
+}
+
+func (s posts) Get(ctx context.Context, id int) (*models.Post, error) {
+	query := `SELECT p.id, p.user_id, p.image_url, COALESCE(p.caption, ''), p.created_at, NOT (` + visiblePostCondition + `)
+        FROM posts p WHERE p.id = $1 AND p.deleted_at IS NULL`
+
+	var post models.Post
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt, &post.Hidden)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NotFound("post with id %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get post: %w", err)
+	}
+	return &post, nil
+}
+
+func (s posts) OwnerID(ctx context.Context, id int) (int, error) {
+	return ownerID(ctx, s.db, "posts", models.TargetPost, id)
+}
+
+func (s posts) GetMany(ctx context.Context, postIDs []int) (map[int]*models.Post, error) {
+	query := `SELECT p.id, p.user_id, p.image_url, COALESCE(p.caption, ''), p.created_at FROM posts p
+        WHERE p.id = ANY($1) AND ` + visiblePostCondition
+
+	found := make(map[int]*models.Post, len(postIDs))
+	err := queryRows(ctx, s.db, query, []interface{}{ids(postIDs)}, func(rows *sql.Rows) error {
+		var post models.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt); err != nil {
+			return err
+		}
+		found[post.ID] = &post
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	return found, nil
+}
+
+func (s posts) ListForUser(ctx context.Context, userID int, page models.Page) ([]models.Post, error) {
+	query := `SELECT p.id, p.user_id, p.image_url, COALESCE(p.caption, ''), p.created_at FROM posts p
+        WHERE p.user_id = $1 AND ` + visiblePostCondition + `
+        ORDER BY p.created_at DESC, p.id DESC` + pageClause(page)
+
+	var list []models.Post
+	err := queryRows(ctx, s.db, query, []interface{}{userID}, func(rows *sql.Rows) error {
+		var post models.Post
+		if err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt); err != nil {
+			return err
+		}
+		list = append(list, post)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts: %w", err)
+	}
+	return list, nil
+}
+
+func (s posts) Feed(ctx context.Context, userID int, page models.Page) ([]models.FeedPost, error) {
+	query := feedColumns + `
+        WHERE f.follower_id = $1 AND ` + visiblePostCondition + `
+        ORDER BY p.created_at DESC, p.id DESC` + pageClause(page)
+
+	feedPosts, err := queryFeed(ctx, s.db, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posts for user feed: %w", err)
+	}
+	return feedPosts, nil
+}
+
+func (s posts) FeedAfter(ctx context.Context, userID, afterID, limit int) ([]models.FeedPost, error) {
+	query := feedColumns + `
+        WHERE f.follower_id = $1 AND p.id > $2 AND ` + visiblePostCondition + `
+        ORDER BY p.id` + pageClause(models.Page{Limit: limit})
+
+	feedPosts, err := queryFeed(ctx, s.db, query, userID, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get new posts for user feed: %w", err)
+	}
+	return feedPosts, nil
+}
+
+func (s posts) LatestID(ctx context.Context) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM posts`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest post: %w", err)
+	}
+	return id, nil
+}
+
+func (s posts) Delete(ctx context.Context, id int) error {
+	return s.delete(ctx, id, false)
+}
+
+func (s posts) Remove(ctx context.Context, id int) error {
+	return s.delete(ctx, id, true)
+}
+
+func (s posts) delete(ctx context.Context, id int, byModerator bool) error {
+	query := `UPDATE posts SET deleted_at = $1, removed_by_moderator = $2 WHERE id = $3 AND deleted_at IS NULL`
+	return execOne(ctx, s.db, repositories.NotFound("post with id %d not found", id), "delete post", query, time.Now().UTC(), byModerator, id)
+}
+
+func (s posts) RemovedByModerator(ctx context.Context, id int) (bool, error) {
+	return removedByModerator(ctx, s.db, "posts", models.TargetPost, id)
+}
+
+func (s posts) Restore(ctx context.Context, id int) error {
+	query := `UPDATE posts SET deleted_at = NULL, removed_by_moderator = FALSE WHERE id = $1 AND deleted_at IS NOT NULL`
+	return execOne(ctx, s.db, repositories.NotFound("deleted post with id %d not found", id), "restore post", query, id)
+}
+
+// queryFeed runs a query selecting feedColumns and collects the posts with their authors
+func queryFeed(ctx context.Context, db repositories.Querier, query string, args ...interface{}) ([]models.FeedPost, error) {
+	var feedPosts []models.FeedPost
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var post models.Post
+		var user models.User
+		if err := rows.Scan(&post.ID, &post.UserID, &post.ImageURL, &post.Caption, &post.CreatedAt,
+			&user.ID, &user.Username, &user.Email, &user.Bio, &user.ProfileImage); err != nil {
+			return fmt.Errorf("failed to scan post and user: %w", err)
+		}
+		feedPosts = append(feedPosts, models.FeedPost{User: user, Post: post})
+		return nil
+	})
+	return feedPosts, err
+}
@@ -0,0 +1,18 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"instagram/internal/repositories"
+)
+
+type sessions struct{ db repositories.Querier }
+
+func (s sessions) RevokeAll(ctx context.Context, userID int) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"time"
+)
+
+type users struct{ db repositories.Querier }
+
+// userColumns are the columns of a user, scanned by scanUser
+const userColumns = `id, username, email, password_hash, COALESCE(bio, ''), COALESCE(profile_image, ''), role,
+        suspended_at, suspended_until, deactivated_at, created_at`
+
+func (s users) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	query := `
+        INSERT INTO users (username, email, password_hash, bio, profile_image, created_at)
+        VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+        RETURNING ` + userColumns
+
+	created, err := scanUser(s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash, user.Bio, user.ProfileImage))
+	if isUniqueViolation(err) {
+		return nil, repositories.Conflict("username or email is already taken")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user: %w", err)
+	}
+	return created, nil
+}
+
+func (s users) Get(ctx context.Context, id int) (*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1 AND deleted_at IS NULL`
+
+	user, err := scanUser(s.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NotFound("user with id %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve user: %w", err)
+	}
+	return user, nil
+}
+
+func (s users) GetMany(ctx context.Context, userIDs []int) (map[int]*models.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	found := make(map[int]*models.User, len(userIDs))
+	err := queryRows(ctx, s.db, query, []interface{}{ids(userIDs)}, func(rows *sql.Rows) error {
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		// Like GetUsersByIDs, lists of users leave out password hashes
+		user.PasswordHash = ""
+		found[user.ID] = user
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve users: %w", err)
+	}
+	return found, nil
+}
+
+func (s users) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	if user.ID == 0 {
+		return nil, repositories.Validation("user ID is required for updating")
+	}
+
+	query := `
+        UPDATE users SET username = $1, email = $2, bio = $3, profile_image = $4
+        WHERE id = $5 AND deleted_at IS NULL
+        RETURNING ` + userColumns
+
+	updated, err := scanUser(s.db.QueryRowContext(ctx, query, user.Username, user.Email, user.Bio, user.ProfileImage, user.ID))
+	if isUniqueViolation(err) {
+		return nil, repositories.Conflict("username or email is already taken")
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NotFound("user with id %d not found", user.ID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+	return updated, nil
+}
+
+func (s users) Delete(ctx context.Context, id int) error {
+	query := `UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`
+	return execOne(ctx, s.db, repositories.NotFound("user with id %d not found", id), "delete user", query, time.Now().UTC(), id)
+}
+
+func (s users) Restore(ctx context.Context, id int) error {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	return execOne(ctx, s.db, repositories.NotFound("user with id %d not found", id), "restore user", query, id)
+}
+
+func (s users) Deactivate(ctx context.Context, id int) error {
+	query := `UPDATE users SET deactivated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL`
+	return execOne(ctx, s.db, repositories.NotFound("user with id %d not found", id), "deactivate user", query, id)
+}
+
+func (s users) Reactivate(ctx context.Context, id int) error {
+	query := `UPDATE users SET deactivated_at = NULL WHERE id = $1`
+	return execOne(ctx, s.db, repositories.NotFound("user with id %d not found", id), "reactivate user", query, id)
+}
+
+// scanner is a row of sql.Row or sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (*models.User, error) {
+	var user models.User
+	var suspendedAt, suspendedUntil, deactivatedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Bio, &user.ProfileImage, &user.Role,
+		&suspendedAt, &suspendedUntil, &deactivatedAt, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if deactivatedAt.Valid {
+		user.DeactivatedAt = &deactivatedAt.Time
+	}
+	return &user, nil
+}
@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"strings"
+)
+
+// reportTargetTables maps report target types to the table holding the target
+var reportTargetTables = map[string]string{
+	models.TargetUser:    "users",
+	models.TargetPost:    "posts",
+	models.TargetComment: "comments",
+}
+
+// AddReport records a report. It returns false if the reporter has already reported the target.
+func AddReport(ctx context.Context, db *sql.DB, report *models.Report) (bool, error) {
+	query := `
+        INSERT INTO reports (reporter_id, target_type, target_id, reason, severity, details, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+        ON CONFLICT(reporter_id, target_type, target_id) DO NOTHING
+    `
+	result, err := db.ExecContext(ctx, query, report.ReporterID, report.TargetType, report.TargetID, report.Reason, report.Severity, report.Details)
+	if err != nil {
+		return false, fmt.Errorf("failed to add report: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// ReportTargetExists reports whether the user, post or comment being reported exists
+func ReportTargetExists(ctx context.Context, db *sql.DB, targetType string, targetID int) (bool, error) {
+	table, ok := reportTargetTables[targetType]
+	if !ok {
+		return false, fmt.Errorf("unknown report target %q", targetType)
+	}
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = ?)`, targetID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check report target: %w", err)
+	}
+	return exists, nil
+}
+
+// GetReportQueue returns the targets with open reports, the most urgent first. Urgency is the sum of the
+// severities of the open reports, so both many reports and severe reasons move a target up the queue.
+func GetReportQueue(ctx context.Context, db *sql.DB, limit int) ([]models.ReportQueueItem, error) {
+	query := `
+        SELECT r.target_type, r.target_id, COUNT(*), MAX(r.severity), SUM(r.severity),
+               GROUP_CONCAT(DISTINCT r.reason), COALESCE(rr.resolution, 'open')
+        FROM reports r
+        LEFT JOIN report_resolutions rr ON rr.target_type = r.target_type AND rr.target_id = r.target_id
+        WHERE r.resolved_at IS NULL
+        GROUP BY r.target_type, r.target_id
+        ORDER BY SUM(r.severity) DESC, MAX(r.severity) DESC, MIN(r.id) ASC
+        LIMIT ?
+    `
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report queue: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var queue []models.ReportQueueItem
+	for rows.Next() {
+		var item models.ReportQueueItem
+		var reasons string
+		err := rows.Scan(&item.TargetType, &item.TargetID, &item.ReportCount, &item.MaxSeverity, &item.Priority,
+			&reasons, &item.Resolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report queue item: %w", err)
+		}
+		item.Reasons = strings.Split(reasons, ",")
+		queue = append(queue, item)
+	}
+
+	return queue, nil
+}
+
+// GetReportsForTarget lists every report made about a target, newest first
+func GetReportsForTarget(ctx context.Context, db *sql.DB, targetType string, targetID int) ([]models.Report, error) {
+	query := `
+        SELECT id, reporter_id, target_type, target_id, reason, severity, COALESCE(details, ''), resolved_at, created_at
+        FROM reports
+        WHERE target_type = ? AND target_id = ?
+        ORDER BY created_at DESC, id DESC
+    `
+	rows, err := db.QueryContext(ctx, query, targetType, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var reports []models.Report
+	for rows.Next() {
+		var report models.Report
+		var resolvedAt sql.NullTime
+		err := rows.Scan(&report.ID, &report.ReporterID, &report.TargetType, &report.TargetID, &report.Reason,
+			&report.Severity, &report.Details, &resolvedAt, &report.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		if resolvedAt.Valid {
+			report.ResolvedAt = &resolvedAt.Time
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// ResolveReports puts a reported target in the given resolution and closes its open reports.
+// Resolving a target as open removes any earlier resolution and puts its reports back in the queue.
+func ResolveReports(ctx context.Context, db *sql.DB, targetType string, targetID int, resolution string, moderatorID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if resolution == models.ResolutionOpen {
+		_, err = tx.ExecContext(ctx, `DELETE FROM report_resolutions WHERE target_type = ? AND target_id = ?`, targetType, targetID)
+		if err != nil {
+			return fmt.Errorf("failed to remove resolution: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE reports SET resolved_at = NULL WHERE target_type = ? AND target_id = ?`, targetType, targetID)
+		if err != nil {
+			return fmt.Errorf("failed to reopen reports: %w", err)
+		}
+	} else {
+		query := `
+            INSERT INTO report_resolutions (target_type, target_id, resolution, moderator_id, resolved_at)
+            VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+            ON CONFLICT(target_type, target_id) DO UPDATE SET
+                resolution = excluded.resolution, moderator_id = excluded.moderator_id, resolved_at = excluded.resolved_at
+        `
+		_, err = tx.ExecContext(ctx, query, targetType, targetID, resolution, moderatorID)
+		if err != nil {
+			return fmt.Errorf("failed to save resolution: %w", err)
+		}
+
+		query = `UPDATE reports SET resolved_at = CURRENT_TIMESTAMP WHERE target_type = ? AND target_id = ? AND resolved_at IS NULL`
+		_, err = tx.ExecContext(ctx, query, targetType, targetID)
+		if err != nil {
+			return fmt.Errorf("failed to resolve reports: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
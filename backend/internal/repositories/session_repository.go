@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/logging"
+	"instagram/internal/models"
+	"time"
+)
+
+// SessionTouchInterval limits how often last_seen_at is written for an active session, callers can skip
+// TouchSession for sessions seen more recently
+const SessionTouchInterval = time.Minute
+
+func CreateSession(ctx context.Context, db *sql.DB, session *models.Session) (*models.Session, error) {
+	query := `
+        INSERT INTO sessions (user_id, user_agent, ip_address, created_at, last_seen_at, expires_at)
+        VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, ?)
+    `
+	result, err := db.ExecContext(ctx, query, session.UserID, session.UserAgent, session.IPAddress, session.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last insert id: %w", err)
+	}
+
+	return GetSession(ctx, db, int(lastInsertID))
+}
+
+func GetSession(ctx context.Context, db *sql.DB, sessionID int) (*models.Session, error) {
+	var session models.Session
+	var userAgent, ipAddress sql.NullString
+	var revokedAt, expiresAt sql.NullTime
+
+	query := `
+        SELECT id, user_id, user_agent, ip_address, created_at, last_seen_at, revoked_at, expires_at
+        FROM sessions
+        WHERE id = ?
+    `
+	err := db.QueryRowContext(ctx, query, sessionID).Scan(&session.ID, &session.UserID, &userAgent, &ipAddress,
+		&session.CreatedAt, &session.LastSeenAt, &revokedAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, NotFound("session with id %d not found", sessionID)
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	session.UserAgent = userAgent.String
+	session.IPAddress = ipAddress.String
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	// Sessions without an expiry are never active
+	session.ExpiresAt = expiresAt.Time
+	return &session, nil
+}
+
+// GetActiveSessionsForUser lists the sessions that have neither been revoked nor expired, most recently used first.
+func GetActiveSessionsForUser(ctx context.Context, db *sql.DB, userID int) ([]models.Session, error) {
+	query := `
+        SELECT id, user_id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at, expires_at
+        FROM sessions
+        WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+        ORDER BY last_seen_at DESC
+    `
+	rows, err := db.QueryContext(ctx, query, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logging.FromContext(ctx).Error("Failed to close rows", "error", err)
+		}
+	}(rows)
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// TouchSession records that the session was just used. Writes are throttled to once per SessionTouchInterval.
+func TouchSession(ctx context.Context, db *sql.DB, sessionID int) error {
+	query := `UPDATE sessions SET last_seen_at = ? WHERE id = ? AND last_seen_at < ?`
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, query, now, sessionID, now.Add(-SessionTouchInterval))
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// RevokeSession revokes one of the user's sessions. Sessions owned by other users are reported as not found.
+func RevokeSession(ctx context.Context, db *sql.DB, userID, sessionID int) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := db.ExecContext(ctx, query, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return NotFound("session with id %d not found", sessionID)
+	}
+
+	return nil
+}
+
+// RevokeAllSessions logs the user out everywhere
+func RevokeAllSessions(ctx context.Context, db Querier, userID int) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL`
+	_, err := db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes the sessions that expired before now, they can't be used or listed anymore.
+// It returns how many were removed.
+func DeleteExpiredSessions(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at IS NULL OR expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted, nil
+}
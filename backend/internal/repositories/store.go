@@ -0,0 +1,376 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/models"
+)
+
+// Querier runs statements, it is either a *sql.DB or a *sql.Tx
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Stores holds a store per aggregate. The SQLite stores are built on the functions of this package, the
+// PostgreSQL stores are in the postgres package. Both pass the contract tests in test/repositories_test.
+// Aggregates without a store are only available on SQLite, through the functions of this package.
+type Stores struct {
+	Users    UserStore
+	Posts    PostStore
+	Comments CommentStore
+	Follows  FollowStore
+	Likes    LikeStore
+	Sessions SessionStore
+	Media    MediaStore
+	Imports  ImportStore
+
+	// Tx runs fn with stores whose changes are committed together if fn returns nil, and rolled back
+	// otherwise. It is nil for stores that are already in a transaction.
+	Tx func(ctx context.Context, fn func(tx *Stores) error) error
+}
+
+// Transaction runs fn in a transaction. Stores without Tx, like those of a transaction or fakes in
+// tests, run fn on themselves.
+func (s *Stores) Transaction(ctx context.Context, fn func(tx *Stores) error) error {
+	if s.Tx == nil {
+		return fn(s)
+	}
+	return s.Tx(ctx, fn)
+}
+
+// RunInTx begins a transaction on db and runs fn with the stores newStores builds on it. The
+// transaction is committed if fn returns nil and rolled back otherwise.
+func RunInTx(ctx context.Context, db *sql.DB, newStores func(Querier) *Stores, fn func(tx *Stores) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(newStores(tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// UserStore persists user accounts. Deleted users are not found until they are restored.
+type UserStore interface {
+	// Create saves a new user and returns it as stored. A taken username or email is a conflict.
+	Create(ctx context.Context, user *models.User) (*models.User, error)
+	Get(ctx context.Context, id int) (*models.User, error)
+	// GetMany returns the users with the given IDs keyed by ID, leaving out missing ones
+	GetMany(ctx context.Context, ids []int) (map[int]*models.User, error)
+	// Update changes the username, email, bio and profile image and returns the updated user
+	Update(ctx context.Context, user *models.User) (*models.User, error)
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+	Deactivate(ctx context.Context, id int) error
+	Reactivate(ctx context.Context, id int) error
+}
+
+// PostStore persists posts. Lists only include posts visible to everyone, see visiblePostCondition.
+type PostStore interface {
+	// Create saves a new post and sets its ID
+	Create(ctx context.Context, post *models.Post) error
+	// Get returns a post that wasn't deleted, including hidden ones with Hidden set
+	Get(ctx context.Context, id int) (*models.Post, error)
+	// OwnerID returns the author of a post, including deleted ones
+	OwnerID(ctx context.Context, id int) (int, error)
+	// GetMany returns the visible posts with the given IDs keyed by ID
+	GetMany(ctx context.Context, ids []int) (map[int]*models.Post, error)
+	// ListForUser returns a page of the posts of a user, newest first
+	ListForUser(ctx context.Context, userID int, page models.Page) ([]models.Post, error)
+	// Feed returns a page of the posts of the users a user follows, newest first
+	Feed(ctx context.Context, userID int, page models.Page) ([]models.FeedPost, error)
+	// FeedAfter returns up to limit feed posts with an ID above afterID, oldest first
+	FeedAfter(ctx context.Context, userID, afterID, limit int) ([]models.FeedPost, error)
+	// LatestID returns the ID of the most recent post, or 0 if there is none
+	LatestID(ctx context.Context) (int, error)
+	Delete(ctx context.Context, id int) error
+	// Remove deletes a post on behalf of a moderator, only admins may restore it
+	Remove(ctx context.Context, id int) error
+	// RemovedByModerator reports whether a deleted post was removed by a moderator
+	RemovedByModerator(ctx context.Context, id int) (bool, error)
+	Restore(ctx context.Context, id int) error
+}
+
+// CommentStore persists comments. Lists only include comments visible to everyone.
+type CommentStore interface {
+	// Create saves a new comment and sets its ID
+	Create(ctx context.Context, comment *models.Comment) error
+	// Get returns a comment that wasn't deleted, including hidden ones with Hidden set
+	Get(ctx context.Context, id int) (*models.Comment, error)
+	// OwnerID returns the author of a comment, including deleted ones
+	OwnerID(ctx context.Context, id int) (int, error)
+	// ListForPost returns a page of the comments of a post, oldest first
+	ListForPost(ctx context.Context, postID int, page models.Page) ([]models.Comment, error)
+	// FirstForPosts returns the first limit comments of each of the posts, keyed by post ID
+	FirstForPosts(ctx context.Context, postIDs []int, limit int) (map[int][]models.Comment, error)
+	// CountForPosts counts the comments of each of the posts, leaving out posts without comments
+	CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error)
+	Delete(ctx context.Context, id int) error
+	// Remove deletes a comment on behalf of a moderator, only admins may restore it
+	Remove(ctx context.Context, id int) error
+	// RemovedByModerator reports whether a deleted comment was removed by a moderator
+	RemovedByModerator(ctx context.Context, id int) (bool, error)
+	Restore(ctx context.Context, id int) error
+}
+
+// FollowStore persists who follows whom. Lists and counts leave out deleted and deactivated users.
+type FollowStore interface {
+	// Follow adds a follow, following a user twice is a conflict
+	Follow(ctx context.Context, follow *models.Follow) error
+	Unfollow(ctx context.Context, follow *models.Follow) error
+	Exists(ctx context.Context, followerID, followingID int) (bool, error)
+	// FollowerIDs returns a page of the followers of a user, most recent first
+	FollowerIDs(ctx context.Context, userID int, page models.Page) ([]int, error)
+	// FollowingIDs returns a page of the users a user follows, most recent first
+	FollowingIDs(ctx context.Context, userID int, page models.Page) ([]int, error)
+	CountFollowers(ctx context.Context, userIDs []int) (map[int]int, error)
+	CountFollowing(ctx context.Context, userIDs []int) (map[int]int, error)
+}
+
+// LikeStore reads the likes of posts
+type LikeStore interface {
+	// CountForPosts counts the likes of each of the posts by active users, leaving out posts without likes
+	CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error)
+}
+
+// MediaStore records who uploaded the files of the media storage
+type MediaStore interface {
+	// Add records that a user uploaded the file stored under url
+	Add(ctx context.Context, userID int, url string) error
+	// UploaderID returns the user who uploaded the file stored under url. Files that weren't uploaded
+	// here are not found.
+	UploaderID(ctx context.Context, url string) (int, error)
+}
+
+// ImportStore remembers which items of archives from other services were imported as which posts
+type ImportStore interface {
+	// Add records that an item of a user's archive from source was imported as a post
+	Add(ctx context.Context, userID int, source, externalID string, postID int) error
+	// PostID returns the post an item was imported as, or 0 if it was not imported yet
+	PostID(ctx context.Context, userID int, source, externalID string) (int, error)
+}
+
+// SessionStore persists the login sessions of users
+type SessionStore interface {
+	// RevokeAll logs a user out everywhere
+	RevokeAll(ctx context.Context, userID int) error
+}
+
+// NewSQLiteStores returns the stores of a SQLite database
+func NewSQLiteStores(db *sql.DB) *Stores {
+	stores := newSQLiteStores(db)
+	stores.Tx = func(ctx context.Context, fn func(tx *Stores) error) error {
+		return RunInTx(ctx, db, newSQLiteStores, fn)
+	}
+	return stores
+}
+
+func newSQLiteStores(db Querier) *Stores {
+	return &Stores{
+		Users:    sqliteUsers{db},
+		Posts:    sqlitePosts{db},
+		Comments: sqliteComments{db},
+		Follows:  sqliteFollows{db},
+		Likes:    sqliteLikes{db},
+		Sessions: sqliteSessions{db},
+		Media:    sqliteMedia{db},
+		Imports:  sqliteImports{db},
+	}
+}
+
+type sqliteUsers struct{ db Querier }
+
+func (s sqliteUsers) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	return SaveUser(ctx, s.db, user)
+}
+
+func (s sqliteUsers) Get(ctx context.Context, id int) (*models.User, error) {
+	return GetUserByID(ctx, s.db, id)
+}
+
+func (s sqliteUsers) GetMany(ctx context.Context, ids []int) (map[int]*models.User, error) {
+	return GetUsersByIDs(ctx, s.db, ids)
+}
+
+func (s sqliteUsers) Update(ctx context.Context, user *models.User) (*models.User, error) {
+	return UpdateUser(ctx, s.db, user)
+}
+
+func (s sqliteUsers) Delete(ctx context.Context, id int) error {
+	return DeleteUserByID(ctx, s.db, id)
+}
+
+func (s sqliteUsers) Restore(ctx context.Context, id int) error {
+	return RestoreUser(ctx, s.db, id)
+}
+
+func (s sqliteUsers) Deactivate(ctx context.Context, id int) error {
+	return DeactivateUser(ctx, s.db, id)
+}
+
+func (s sqliteUsers) Reactivate(ctx context.Context, id int) error {
+	return ReactivateUser(ctx, s.db, id)
+}
+
+type sqlitePosts struct{ db Querier }
+
+func (s sqlitePosts) Create(ctx context.Context, post *models.Post) error {
+	return AddPost(ctx, s.db, post)
+}
+
+func (s sqlitePosts) Get(ctx context.Context, id int) (*models.Post, error) {
+	return GetPostByID(ctx, s.db, id)
+}
+
+func (s sqlitePosts) OwnerID(ctx context.Context, id int) (int, error) {
+	return GetContentOwnerID(ctx, s.db, models.TargetPost, id)
+}
+
+func (s sqlitePosts) GetMany(ctx context.Context, ids []int) (map[int]*models.Post, error) {
+	return GetPostsByIDs(ctx, s.db, ids)
+}
+
+func (s sqlitePosts) ListForUser(ctx context.Context, userID int, page models.Page) ([]models.Post, error) {
+	return GetPostsForUser(ctx, s.db, userID, page)
+}
+
+func (s sqlitePosts) Feed(ctx context.Context, userID int, page models.Page) ([]models.FeedPost, error) {
+	return GetPostsForUserFeed(ctx, s.db, userID, page)
+}
+
+func (s sqlitePosts) FeedAfter(ctx context.Context, userID, afterID, limit int) ([]models.FeedPost, error) {
+	return GetFeedPostsAfter(ctx, s.db, userID, afterID, limit)
+}
+
+func (s sqlitePosts) LatestID(ctx context.Context) (int, error) {
+	return GetLatestPostID(ctx, s.db)
+}
+
+func (s sqlitePosts) Delete(ctx context.Context, id int) error {
+	return DeletePost(ctx, s.db, id, false)
+}
+
+func (s sqlitePosts) Remove(ctx context.Context, id int) error {
+	return DeletePost(ctx, s.db, id, true)
+}
+
+func (s sqlitePosts) RemovedByModerator(ctx context.Context, id int) (bool, error) {
+	return IsRemovedByModerator(ctx, s.db, models.TargetPost, id)
+}
+
+func (s sqlitePosts) Restore(ctx context.Context, id int) error {
+	return RestorePost(ctx, s.db, id)
+}
+
+type sqliteComments struct{ db Querier }
+
+func (s sqliteComments) Create(ctx context.Context, comment *models.Comment) error {
+	return AddComment(ctx, s.db, comment)
+}
+
+func (s sqliteComments) Get(ctx context.Context, id int) (*models.Comment, error) {
+	return GetComment(ctx, s.db, id)
+}
+
+func (s sqliteComments) OwnerID(ctx context.Context, id int) (int, error) {
+	return GetContentOwnerID(ctx, s.db, models.TargetComment, id)
+}
+
+func (s sqliteComments) ListForPost(ctx context.Context, postID int, page models.Page) ([]models.Comment, error) {
+	return GetCommentsForPost(ctx, s.db, postID, page)
+}
+
+func (s sqliteComments) FirstForPosts(ctx context.Context, postIDs []int, limit int) (map[int][]models.Comment, error) {
+	return GetCommentsForPosts(ctx, s.db, postIDs, limit)
+}
+
+func (s sqliteComments) CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error) {
+	return CountCommentsForPosts(ctx, s.db, postIDs)
+}
+
+func (s sqliteComments) Delete(ctx context.Context, id int) error {
+	return DeleteComment(ctx, s.db, id, false)
+}
+
+func (s sqliteComments) Remove(ctx context.Context, id int) error {
+	return DeleteComment(ctx, s.db, id, true)
+}
+
+func (s sqliteComments) RemovedByModerator(ctx context.Context, id int) (bool, error) {
+	return IsRemovedByModerator(ctx, s.db, models.TargetComment, id)
+}
+
+func (s sqliteComments) Restore(ctx context.Context, id int) error {
+	return RestoreComment(ctx, s.db, id)
+}
+
+type sqliteFollows struct{ db Querier }
+
+func (s sqliteFollows) Follow(ctx context.Context, follow *models.Follow) error {
+	return AddFollow(ctx, s.db, follow)
+}
+
+func (s sqliteFollows) Unfollow(ctx context.Context, follow *models.Follow) error {
+	return RemoveFollow(ctx, s.db, follow)
+}
+
+func (s sqliteFollows) Exists(ctx context.Context, followerID, followingID int) (bool, error) {
+	return FollowExists(ctx, s.db, followerID, followingID)
+}
+
+func (s sqliteFollows) FollowerIDs(ctx context.Context, userID int, page models.Page) ([]int, error) {
+	return GetFollowerIDs(ctx, s.db, userID, page)
+}
+
+func (s sqliteFollows) FollowingIDs(ctx context.Context, userID int, page models.Page) ([]int, error) {
+	return GetFollowingIDs(ctx, s.db, userID, page)
+}
+
+func (s sqliteFollows) CountFollowers(ctx context.Context, userIDs []int) (map[int]int, error) {
+	return CountFollowers(ctx, s.db, userIDs)
+}
+
+func (s sqliteFollows) CountFollowing(ctx context.Context, userIDs []int) (map[int]int, error) {
+	return CountFollowing(ctx, s.db, userIDs)
+}
+
+type sqliteLikes struct{ db Querier }
+
+func (s sqliteLikes) CountForPosts(ctx context.Context, postIDs []int) (map[int]int, error) {
+	return CountLikesForPosts(ctx, s.db, postIDs)
+}
+
+type sqliteSessions struct{ db Querier }
+
+func (s sqliteSessions) RevokeAll(ctx context.Context, userID int) error {
+	return RevokeAllSessions(ctx, s.db, userID)
+}
+
+type sqliteMedia struct{ db Querier }
+
+func (s sqliteMedia) Add(ctx context.Context, userID int, url string) error {
+	return AddMediaFile(ctx, s.db, userID, url)
+}
+
+func (s sqliteMedia) UploaderID(ctx context.Context, url string) (int, error) {
+	return GetMediaFileUploaderID(ctx, s.db, url)
+}
+
+type sqliteImports struct{ db Querier }
+
+func (s sqliteImports) Add(ctx context.Context, userID int, source, externalID string, postID int) error {
+	return AddImportedItem(ctx, s.db, userID, source, externalID, postID)
+}
+
+func (s sqliteImports) PostID(ctx context.Context, userID int, source, externalID string) (int, error) {
+	return GetImportedPostID(ctx, s.db, userID, source, externalID)
+}
@@ -0,0 +1,202 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/models"
+	"time"
+)
+
+// SaveTOTPSecret stores a new, unconfirmed TOTP secret for the user, replacing any pending enrollment.
+func SaveTOTPSecret(ctx context.Context, db *sql.DB, userID int, secret string) error {
+	query := `
+        INSERT INTO user_totp (user_id, secret, confirmed_at, created_at)
+        VALUES (?, ?, NULL, CURRENT_TIMESTAMP)
+        ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, confirmed_at = NULL, created_at = CURRENT_TIMESTAMP
+    `
+	_, err := db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("failed to save totp secret: %w", err)
+	}
+	return nil
+}
+
+// GetTOTP returns the user's TOTP enrollment, or nil if they never enrolled.
+func GetTOTP(ctx context.Context, db *sql.DB, userID int) (*models.TOTP, error) {
+	var totp models.TOTP
+	var confirmedAt sql.NullTime
+
+	query := `SELECT user_id, secret, confirmed_at, created_at FROM user_totp WHERE user_id = ?`
+	err := db.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &confirmedAt, &totp.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+
+	if confirmedAt.Valid {
+		totp.ConfirmedAt = &confirmedAt.Time
+	}
+	return &totp, nil
+}
+
+// ConfirmTOTP enables TOTP for the user and replaces their recovery codes in a single transaction.
+func ConfirmTOTP(ctx context.Context, db *sql.DB, userID int, recoveryCodeHashes []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE user_totp SET confirmed_at = CURRENT_TIMESTAMP WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to confirm totp: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("no totp enrollment found for user %d", userID)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTOTP disables TOTP for the user and removes their recovery codes.
+func DeleteTOTP(ctx context.Context, db *sql.DB, userID int) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete totp: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates all existing recovery codes for the user and stores new ones.
+func ReplaceRecoveryCodes(ctx context.Context, db *sql.DB, userID int, codeHashes []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, hash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash)
+		if err != nil {
+			return fmt.Errorf("failed to save recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if the code is unknown or already used.
+func UseRecoveryCode(ctx context.Context, db *sql.DB, userID int, codeHash string) (bool, error) {
+	query := `
+        UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+        WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+    `
+	result, err := db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// UseTOTPStep records that a code of the given time step was accepted for the user. It reports false if a
+// code of that step or a later one was accepted before, so every code works only once.
+func UseTOTPStep(ctx context.Context, db *sql.DB, userID int, step int64) (bool, error) {
+	query := `
+        UPDATE user_totp SET last_used_step = ?
+        WHERE user_id = ? AND (last_used_step IS NULL OR last_used_step < ?)
+    `
+	result, err := db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to use totp code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// MFAChallengeUsed reports whether the MFA challenge token with the ID was exchanged for a session
+func MFAChallengeUsed(ctx context.Context, db *sql.DB, tokenID string) (bool, error) {
+	var used bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM used_mfa_challenges WHERE token_id = ?)`, tokenID).Scan(&used)
+	if err != nil {
+		return false, fmt.Errorf("failed to check mfa challenge: %w", err)
+	}
+	return used, nil
+}
+
+// UseMFAChallenge records that the MFA challenge token with the ID was exchanged for a session. It reports
+// false if it was used before. The record is kept until the token expires.
+func UseMFAChallenge(ctx context.Context, db *sql.DB, tokenID string, expiresAt time.Time) (bool, error) {
+	query := `INSERT INTO used_mfa_challenges (token_id, expires_at) VALUES (?, ?) ON CONFLICT(token_id) DO NOTHING`
+	result, err := db.ExecContext(ctx, query, tokenID, expiresAt.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to use mfa challenge: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// DeleteExpiredMFAChallenges forgets the used MFA challenges that expired before now, they are rejected
+// for their expiry anyway. It returns how many were removed.
+func DeleteExpiredMFAChallenges(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM used_mfa_challenges WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired mfa challenges: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted, nil
+}
@@ -1,13 +1,15 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"instagram/internal/models"
+	"time"
 )
 
-func SaveUser(db *sql.DB, user *models.User) (*models.User, error) {
+func SaveUser(ctx context.Context, db Querier, user *models.User) (*models.User, error) {
 	username, email, passwordHash, bio, profileImage :=
 		user.Username, user.Email, user.PasswordHash, user.Bio, user.ProfileImage
 
@@ -17,7 +19,10 @@ func SaveUser(db *sql.DB, user *models.User) (*models.User, error) {
     `
 
 	// Use db.Exec to insert the user and capture the result
-	result, err := db.Exec(query, username, email, passwordHash, bio, profileImage)
+	result, err := db.ExecContext(ctx, query, username, email, passwordHash, bio, profileImage)
+	if isUniqueViolation(err) {
+		return nil, Conflict("username or email is already taken")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to save user: %w", err)
 	}
@@ -29,7 +34,7 @@ func SaveUser(db *sql.DB, user *models.User) (*models.User, error) {
 	}
 
 	// Retrieve the newly created user
-	newUser, err := GetUserByID(db, int(lastInsertID))
+	newUser, err := GetUserByID(ctx, db, int(lastInsertID))
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve new user: %w", err)
 	}
@@ -37,42 +42,123 @@ func SaveUser(db *sql.DB, user *models.User) (*models.User, error) {
 	return newUser, nil
 }
 
-func GetUserByID(db *sql.DB, id int) (*models.User, error) {
+func GetUserByID(ctx context.Context, db Querier, id int) (*models.User, error) {
+	return getUser(ctx, db, id, "deleted_at IS NULL")
+}
+
+// GetDeletedUserByID retrieves a soft deleted user that has not been purged yet, e.g. to log them in
+// before their account is restored
+func GetDeletedUserByID(ctx context.Context, db Querier, id int) (*models.User, error) {
+	return getUser(ctx, db, id, "deleted_at IS NOT NULL")
+}
+
+// getUser retrieves the user with the ID if it matches the deletion condition
+func getUser(ctx context.Context, db Querier, id int, deletedCondition string) (*models.User, error) {
 	var user models.User
+	var suspendedAt, suspendedUntil, deactivatedAt sql.NullTime
 
 	query := `
-        SELECT id, username, email, password_hash, bio, profile_image, created_at
+        SELECT id, username, email, password_hash, COALESCE(bio, ''), COALESCE(profile_image, ''), role,
+               suspended_at, suspended_until, deactivated_at, created_at
         FROM users
-        WHERE id = ?
-    `
+        WHERE id = ? AND ` + deletedCondition
 
-	err := db.QueryRow(query, id).Scan(
+	err := db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
 		&user.Bio,
 		&user.ProfileImage,
+		&user.Role,
+		&suspendedAt,
+		&suspendedUntil,
+		&deactivatedAt,
 		&user.CreatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("user with id %d not found", id)
+			return nil, NotFound("user with id %d not found", id)
 		}
 		return nil, fmt.Errorf("failed to retrieve user: %w", err)
 	}
 
+	if suspendedAt.Valid {
+		user.SuspendedAt = &suspendedAt.Time
+	}
+	if suspendedUntil.Valid {
+		user.SuspendedUntil = &suspendedUntil.Time
+	}
+	if deactivatedAt.Valid {
+		user.DeactivatedAt = &deactivatedAt.Time
+	}
+
 	return &user, nil
 }
 
-func DeleteUserByID(db *sql.DB, id int) error {
+// GetUsersByIDs retrieves the users with the given IDs that are not deleted, keyed by ID
+func GetUsersByIDs(ctx context.Context, db Querier, ids []int) (map[int]*models.User, error) {
+	in, args := inClause(ids)
+	query := `
+        SELECT id, username, email, COALESCE(bio, ''), COALESCE(profile_image, ''), role,
+               suspended_at, suspended_until, deactivated_at, created_at
+        FROM users
+        WHERE id IN ` + in + ` AND deleted_at IS NULL
+    `
+
+	users := make(map[int]*models.User, len(ids))
+	err := queryRows(ctx, db, query, args, func(rows *sql.Rows) error {
+		var user models.User
+		var suspendedAt, suspendedUntil, deactivatedAt sql.NullTime
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Bio, &user.ProfileImage, &user.Role,
+			&suspendedAt, &suspendedUntil, &deactivatedAt, &user.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		if suspendedAt.Valid {
+			user.SuspendedAt = &suspendedAt.Time
+		}
+		if suspendedUntil.Valid {
+			user.SuspendedUntil = &suspendedUntil.Time
+		}
+		if deactivatedAt.Valid {
+			user.DeactivatedAt = &deactivatedAt.Time
+		}
+		users[user.ID] = &user
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve users: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetUserIDByUsername returns the ID of the user with the username, or 0 if there is none
+func GetUserIDByUsername(ctx context.Context, db Querier, username string) (int, error) {
+	query := `SELECT id FROM users WHERE username = ? AND deleted_at IS NULL`
+
+	var id int
+	err := db.QueryRowContext(ctx, query, username).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user by username: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteUserByID soft deletes the user. The account can be restored until it is purged.
+func DeleteUserByID(ctx context.Context, db Querier, id int) error {
 	query := `
-		DELETE FROM users
-		WHERE id = ?
+		UPDATE users SET deleted_at = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`
 
-	result, err := db.Exec(query, id)
+	result, err := db.ExecContext(ctx, query, time.Now().UTC(), id)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -83,27 +169,30 @@ func DeleteUserByID(db *sql.DB, id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user with id %d not found", id)
+		return NotFound("user with id %d not found", id)
 	}
 
 	return nil
 }
 
-func UpdateUser(db *sql.DB, user *models.User) (*models.User, error) {
+func UpdateUser(ctx context.Context, db Querier, user *models.User) (*models.User, error) {
 	// Ensure the user ID is provided
 	if user.ID == 0 {
-		return nil, errors.New("user ID is required for updating")
+		return nil, Validation("user ID is required for updating")
 	}
 
 	// Prepare the update query
 	query := `
         UPDATE users 
         SET username = ?, email = ?, bio = ?, profile_image = ? 
-        WHERE id = ?
+        WHERE id = ? AND deleted_at IS NULL
     `
 
 	// Execute the update
-	result, err := db.Exec(query, user.Username, user.Email, user.Bio, user.ProfileImage, user.ID)
+	result, err := db.ExecContext(ctx, query, user.Username, user.Email, user.Bio, user.ProfileImage, user.ID)
+	if isUniqueViolation(err) {
+		return nil, Conflict("username or email is already taken")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
@@ -115,14 +204,71 @@ func UpdateUser(db *sql.DB, user *models.User) (*models.User, error) {
 	}
 
 	if rowsAffected == 0 {
-		return nil, fmt.Errorf("user with id %d not found", user.ID)
+		return nil, NotFound("user with id %d not found", user.ID)
 	}
 
 	// Retrieve the updated user
-	updatedUser, err := GetUserByID(db, user.ID)
+	updatedUser, err := GetUserByID(ctx, db, user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve updated user: %w", err)
 	}
 
 	return updatedUser, nil
 }
+
+// SuspendUser suspends the user until the given time, or indefinitely if until is nil
+func SuspendUser(ctx context.Context, db Querier, id int, until *time.Time) error {
+	query := `UPDATE users SET suspended_at = CURRENT_TIMESTAMP, suspended_until = ? WHERE id = ?`
+	return execUserUpdate(ctx, db, id, "suspend user", query, until, id)
+}
+
+func UnsuspendUser(ctx context.Context, db Querier, id int) error {
+	query := `UPDATE users SET suspended_at = NULL, suspended_until = NULL WHERE id = ?`
+	return execUserUpdate(ctx, db, id, "unsuspend user", query, id)
+}
+
+func SetUserRole(ctx context.Context, db Querier, id int, role string) error {
+	query := `UPDATE users SET role = ? WHERE id = ?`
+	return execUserUpdate(ctx, db, id, "set user role", query, role, id)
+}
+
+// RestoreUser undoes the soft deletion of a user that has not been purged yet
+func RestoreUser(ctx context.Context, db Querier, id int) error {
+	query := `UPDATE users SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+	return execUserUpdate(ctx, db, id, "restore user", query, id)
+}
+
+// DeactivateUser hides the user's profile and content until they log in again
+func DeactivateUser(ctx context.Context, db Querier, id int) error {
+	query := `UPDATE users SET deactivated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
+	return execUserUpdate(ctx, db, id, "deactivate user", query, id)
+}
+
+func ReactivateUser(ctx context.Context, db Querier, id int) error {
+	query := `UPDATE users SET deactivated_at = NULL WHERE id = ?`
+	return execUserUpdate(ctx, db, id, "reactivate user", query, id)
+}
+
+func UpdatePasswordHash(ctx context.Context, db Querier, id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = ? WHERE id = ?`
+	return execUserUpdate(ctx, db, id, "update password", query, passwordHash, id)
+}
+
+// execUserUpdate runs an update against a single user and reports a missing user as an error
+func execUserUpdate(ctx context.Context, db Querier, id int, action string, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return NotFound("user with id %d not found", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package repositories
+
+// Conditions that leave out posts and comments nobody but their author should see: content that was deleted,
+// content whose author deleted or deactivated their account, and content hidden by a moderator, either
+// directly or because its author's profile was hidden. Comments are also left out when their post is. They
+// expect the posts and comments tables to be aliased as p and c.
+const (
+	visiblePostCondition = `p.deleted_at IS NULL
+        AND EXISTS (SELECT 1 FROM users au WHERE au.id = p.user_id AND au.deleted_at IS NULL AND au.deactivated_at IS NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM report_resolutions rr
+            WHERE rr.resolution = 'hidden'
+              AND ((rr.target_type = 'post' AND rr.target_id = p.id) OR (rr.target_type = 'user' AND rr.target_id = p.user_id))
+        )`
+	visibleCommentCondition = `c.deleted_at IS NULL
+        AND EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id AND ` + visiblePostCondition + `)
+        AND EXISTS (SELECT 1 FROM users au WHERE au.id = c.user_id AND au.deleted_at IS NULL AND au.deactivated_at IS NULL)
+        AND NOT EXISTS (
+            SELECT 1 FROM report_resolutions rr
+            WHERE rr.resolution = 'hidden'
+              AND ((rr.target_type = 'comment' AND rr.target_id = c.id) OR (rr.target_type = 'user' AND rr.target_id = c.user_id))
+        )`
+)
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"net/http"
+)
+
+func AdminRouter(app *handlers.App) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	moderator := func(handler http.HandlerFunc) http.Handler {
+		return middleware.RequireSession(middleware.RequireRole(handler, models.RoleModerator))
+	}
+	admin := func(handler http.HandlerFunc) http.Handler {
+		return middleware.RequireSession(middleware.RequireRole(handler, models.RoleAdmin))
+	}
+
+	handle(mux, "POST /admin/users/{id}/suspend", moderator(app.HandleSuspendUser))
+	handle(mux, "POST /admin/users/{id}/unsuspend", moderator(app.HandleUnsuspendUser))
+	handle(mux, "PUT /admin/users/{id}/role", admin(app.HandlePutUserRole))
+	handle(mux, "POST /admin/users/{id}/restore", admin(app.HandleRestoreUser))
+	handle(mux, "DELETE /admin/posts/{id}", moderator(app.HandleRemovePost))
+	handle(mux, "DELETE /admin/comments/{id}", moderator(app.HandleRemoveComment))
+	handle(mux, "GET /admin/reports", moderator(app.HandleGetReportQueue))
+	handle(mux, "GET /admin/reports/{target_type}/{target_id}", moderator(app.HandleGetReportsForTarget))
+	handle(mux, "POST /admin/reports/{target_type}/{target_id}/resolve", moderator(app.HandleResolveReports))
+	handle(mux, "GET /admin/actions", moderator(app.HandleGetModerationActions))
+	handle(mux, "GET /admin/audit", admin(app.HandleGetAuditEvents))
+
+	return mux
+}
@@ -2,13 +2,50 @@ package routes
 
 import (
 	"instagram/internal/handlers"
+	"instagram/internal/middleware"
 	"net/http"
+	"time"
 )
 
-func AuthRouter() *http.ServeMux {
+func AuthRouter(app *handlers.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /auth/signup", handlers.HandleSignup)
-	mux.HandleFunc("POST /auth/login", handlers.HandleLogin)
+	// Credential guessing is limited per client IP, accounts are additionally locked by the handlers
+	loginLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "login", Limit: 10, Window: time.Minute, Key: middleware.KeyByIP,
+	})
+	signupLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "signup", Limit: 5, Window: time.Hour, Key: middleware.KeyByIP,
+	})
+
+	handle(mux, "POST /auth/signup", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleSignup), signupLimiter))
+	handle(mux, "POST /auth/login", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleLogin), loginLimiter))
+	handle(mux, "POST /auth/restore", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleRestoreAccount), loginLimiter))
+	handle(mux, "POST /auth/mfa/verify", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleMFAVerify), loginLimiter))
+
+	// Login with external OpenID Connect identity providers
+	handle(mux, "GET /auth/oidc/{provider}/login", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleOIDCLogin), loginLimiter))
+	handle(mux, "GET /auth/oidc/{provider}/callback", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleOIDCCallback), loginLimiter))
+	handle(mux, "POST /auth/oidc/link", middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleOIDCLink), loginLimiter))
+
+	// Account security settings can only be changed from a logged in session, never with an access token
+	session := func(handler http.HandlerFunc) http.Handler {
+		return middleware.JWTMiddleware(middleware.RequireSession(handler), app.DB, app.Config.Auth.JWTSecret)
+	}
+
+	handle(mux, "POST /auth/mfa/totp/enroll", session(app.HandleTOTPEnroll))
+	handle(mux, "POST /auth/mfa/totp/confirm", session(app.HandleTOTPConfirm))
+	handle(mux, "POST /auth/mfa/totp/recovery-codes", session(app.HandleTOTPRecoveryCodes))
+	handle(mux, "DELETE /auth/mfa/totp", session(app.HandleTOTPDisable))
+
+	handle(mux, "POST /auth/password", session(app.HandleChangePassword))
+
+	handle(mux, "GET /auth/sessions", session(app.HandleGetSessions))
+	handle(mux, "DELETE /auth/sessions/{id}", session(app.HandleDeleteSession))
+
+	handle(mux, "POST /auth/tokens", session(app.HandlePostPersonalAccessToken))
+	handle(mux, "GET /auth/tokens", session(app.HandleGetPersonalAccessTokens))
+	handle(mux, "DELETE /auth/tokens/{id}", session(app.HandleDeletePersonalAccessToken))
+
 	return mux
 }
@@ -2,16 +2,28 @@ package routes
 
 import (
 	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
 	"net/http"
+	"time"
 )
 
-func CommentRouter() *http.ServeMux {
+func CommentRouter(app *handlers.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /comment/{id}", handlers.HandleGetComment)
-	mux.HandleFunc("POST /comment/", handlers.HandlePostComment)
-	mux.HandleFunc("DELETE /comment/{id}", handlers.HandleDeleteComment)
-	mux.HandleFunc("GET /comment/post/{post_id}", handlers.HandleGetCommentsForPost)
+	// Writes are limited per user, with a looser per-IP limit to catch users spread over many accounts
+	userWriteLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "comment-write-user", Limit: 30, Window: time.Minute, Key: middleware.KeyByUser,
+	})
+	ipWriteLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "comment-write-ip", Limit: 60, Window: time.Minute, Key: middleware.KeyByIP,
+	})
+
+	handle(mux, "GET /comment/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetComment), models.ScopeCommentsRead))
+	handle(mux, "POST /comment/", middleware.RequireScope(middleware.RateLimitMiddleware(http.HandlerFunc(app.HandlePostComment), userWriteLimiter, ipWriteLimiter), models.ScopeCommentsWrite))
+	handle(mux, "DELETE /comment/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleDeleteComment), models.ScopeCommentsWrite))
+	handle(mux, "POST /comment/{id}/restore", middleware.RequireScope(http.HandlerFunc(app.HandleRestoreComment), models.ScopeCommentsWrite))
+	handle(mux, "GET /comment/post/{post_id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetCommentsForPost), models.ScopeCommentsRead))
 
 	return mux
 }
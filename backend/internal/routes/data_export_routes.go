@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"net/http"
+	"time"
+)
+
+func DataExportRouter(app *handlers.App) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Exports are expensive to build, a few per day are plenty
+	exportLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "data-export", Limit: 3, Window: 24 * time.Hour, Key: middleware.KeyByUser,
+	})
+
+	session := func(handler http.Handler) http.Handler {
+		return middleware.JWTMiddleware(middleware.RequireSession(handler), app.DB, app.Config.Auth.JWTSecret)
+	}
+
+	handle(mux, "POST /export/", session(middleware.RateLimitMiddleware(http.HandlerFunc(app.HandlePostDataExport), exportLimiter)))
+	handle(mux, "GET /export/{id}", session(http.HandlerFunc(app.HandleGetDataExport)))
+
+	// Download links carry their own short-lived token so they can be opened directly in a browser
+	handle(mux, "GET /export/{id}/download", http.HandlerFunc(app.HandleDownloadDataExport))
+
+	return mux
+}
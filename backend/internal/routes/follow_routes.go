@@ -2,14 +2,16 @@ package routes
 
 import (
 	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
 	"net/http"
 )
 
-func FollowRouter() *http.ServeMux {
+func FollowRouter(app *handlers.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /follow/", handlers.HandlePostFollow)
-	mux.HandleFunc("DELETE /follow/", handlers.HandleDeleteFollow)
+	handle(mux, "POST /follow/", middleware.RequireScope(http.HandlerFunc(app.HandlePostFollow), models.ScopeFollowsWrite))
+	handle(mux, "DELETE /follow/", middleware.RequireScope(http.HandlerFunc(app.HandleDeleteFollow), models.ScopeFollowsWrite))
 
 	return mux
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"instagram/internal/handlers"
+	"net/http"
+)
+
+func GraphQLRouter(app *handlers.App) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	handle(mux, "POST /graphql", http.HandlerFunc(app.HandleGraphQL))
+
+	return mux
+}
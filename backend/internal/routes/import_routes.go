@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"net/http"
+	"time"
+)
+
+func ImportRouter(app *handlers.App) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Archives are large and imports are one-off, a few retries per day are plenty
+	importLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "import", Limit: 5, Window: 24 * time.Hour, Key: middleware.KeyByUser,
+	})
+
+	handle(mux, "POST /import/instagram", middleware.RequireSession(middleware.RateLimitMiddleware(http.HandlerFunc(app.HandleImportInstagram), importLimiter)))
+
+	return mux
+}
@@ -2,17 +2,29 @@ package routes
 
 import (
 	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
 	"net/http"
+	"time"
 )
 
-func PostRouter() *http.ServeMux {
+func PostRouter(app *handlers.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /post/{id}", handlers.HandleGetPostById)
-	mux.HandleFunc("GET /post/user/{user_id}", handlers.HandleGetPostsForUser)
-	mux.HandleFunc("DELETE /post/{id}", handlers.HandleDeletePost)
-	mux.HandleFunc("POST /post/", handlers.HandlePostPost)
-	mux.HandleFunc("GET /post/feed/{user_id}", handlers.HandleGetFeedForUser)
+	// Writes are limited per user, with a looser per-IP limit to catch users spread over many accounts
+	userWriteLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "post-write-user", Limit: 10, Window: time.Minute, Key: middleware.KeyByUser,
+	})
+	ipWriteLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "post-write-ip", Limit: 30, Window: time.Minute, Key: middleware.KeyByIP,
+	})
+
+	handle(mux, "GET /post/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetPostById), models.ScopePostsRead))
+	handle(mux, "GET /post/user/{user_id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetPostsForUser), models.ScopePostsRead))
+	handle(mux, "DELETE /post/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleDeletePost), models.ScopePostsWrite))
+	handle(mux, "POST /post/{id}/restore", middleware.RequireScope(http.HandlerFunc(app.HandleRestorePost), models.ScopePostsWrite))
+	handle(mux, "POST /post/", middleware.RequireScope(middleware.RateLimitMiddleware(http.HandlerFunc(app.HandlePostPost), userWriteLimiter, ipWriteLimiter), models.ScopePostsWrite))
+	handle(mux, "GET /post/feed/{user_id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetFeedForUser), models.ScopePostsRead))
 
 	return mux
 }
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// patterns holds every route registered by the routers, so that the API documentation can be checked
+// against what is actually served
+var patterns = struct {
+	sync.Mutex
+	set map[string]struct{}
+}{set: map[string]struct{}{}}
+
+// handle registers a route on the router and records its pattern
+func handle(mux *http.ServeMux, pattern string, handler http.Handler) {
+	patterns.Lock()
+	patterns.set[pattern] = struct{}{}
+	patterns.Unlock()
+
+	mux.Handle(pattern, handler)
+}
+
+// Patterns returns the sorted patterns, such as "GET /post/{id}", of all routes registered by the
+// routers built so far
+func Patterns() []string {
+	patterns.Lock()
+	defer patterns.Unlock()
+
+	result := make([]string, 0, len(patterns.set))
+	for pattern := range patterns.set {
+		result = append(result, pattern)
+	}
+	slices.Sort(result)
+	return result
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"net/http"
+	"time"
+)
+
+func ReportRouter(app *handlers.App) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Reports are cheap to send and expensive to review, so they are limited per user
+	reportLimiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "report-user", Limit: 20, Window: time.Hour, Key: middleware.KeyByUser,
+	})
+
+	handle(mux, "POST /report/", middleware.RequireSession(middleware.RateLimitMiddleware(http.HandlerFunc(app.HandlePostReport), reportLimiter)))
+
+	return mux
+}
@@ -2,16 +2,19 @@ package routes
 
 import (
 	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
 	"net/http"
 )
 
-func UserRouter() *http.ServeMux {
+func UserRouter(app *handlers.App) *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("POST /users/", handlers.HandlePostUser)
-	mux.HandleFunc("PATCH /users/", handlers.HandlePatchUser)
-	mux.HandleFunc("GET /users/{id}", handlers.HandleGetUserById)
-	mux.HandleFunc("DELETE /users/{id}", handlers.HandleDeleteUserById)
+	handle(mux, "POST /users/", middleware.RequireScope(http.HandlerFunc(app.HandlePostUser), models.ScopeUsersWrite))
+	handle(mux, "PATCH /users/", middleware.RequireScope(http.HandlerFunc(app.HandlePatchUser), models.ScopeUsersWrite))
+	handle(mux, "GET /users/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleGetUserById), models.ScopeUsersRead))
+	handle(mux, "DELETE /users/{id}", middleware.RequireScope(http.HandlerFunc(app.HandleDeleteUserById), models.ScopeUsersWrite))
+	handle(mux, "POST /users/{id}/deactivate", middleware.RequireSession(http.HandlerFunc(app.HandleDeactivateUser)))
 
 	return mux
 }
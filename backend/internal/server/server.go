@@ -0,0 +1,185 @@
+// Package server runs the HTTP server and the background jobs, and shuts both down gracefully
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"instagram/internal/config"
+	"instagram/internal/problem"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readinessTimeout bounds the database check of /readyz, so a hung database fails the check
+const readinessTimeout = 2 * time.Second
+
+// Server serves the API next to /healthz and /readyz, and owns the background workers started with Go.
+// The health endpoints bypass the API middleware so probes neither need the database to be reachable
+// nor fill the request log.
+type Server struct {
+	cfg      config.ServerConfig
+	db       *sql.DB
+	mux      *http.ServeMux
+	http     *http.Server
+	draining atomic.Bool
+	checks   []readinessCheck
+
+	workers       sync.WaitGroup
+	workerCtx     context.Context
+	cancelWorkers context.CancelFunc
+}
+
+func New(cfg config.ServerConfig, db *sql.DB, api http.Handler) *Server {
+	s := &Server{cfg: cfg, db: db}
+	s.workerCtx, s.cancelWorkers = context.WithCancel(context.Background())
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("GET /healthz", s.HandleHealthz)
+	s.mux.HandleFunc("GET /readyz", s.HandleReadyz)
+	s.mux.Handle("/", api)
+
+	s.http = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	return s
+}
+
+// readinessCheck is a dependency /readyz checks besides the database connection
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// AddReadinessCheck makes /readyz fail while check returns an error, e.g. while the database schema is
+// not the one the server expects. name is shown to the client, the error is only logged.
+func (s *Server) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	s.checks = append(s.checks, readinessCheck{name: name, check: check})
+}
+
+// Handler returns the handler serving the API and the health endpoints
+func (s *Server) Handler() http.Handler {
+	return s.http.Handler
+}
+
+// Handle serves an operational endpoint, such as metrics, next to the health endpoints and outside
+// of the API middleware
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Go starts a background worker. Its context is cancelled on shutdown once the in-flight requests are
+// done, and shutdown waits for the worker to return.
+func (s *Server) Go(worker func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		worker(s.workerCtx)
+	}()
+}
+
+// Run listens on the configured address and serves until ctx is cancelled, then shuts down
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.cfg.Addr, err)
+	}
+	slog.Info("Server is running", "addr", listener.Addr().String())
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on the listener until ctx is cancelled. The server then reports not ready, stops accepting
+// connections, waits for in-flight requests and finally for the background workers, all within the
+// shutdown timeout.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.http.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		s.stopWorkers(context.Background())
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining requests", "timeout", s.cfg.ShutdownTimeout.String())
+	s.draining.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain requests: %w", err))
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errs = append(errs, err)
+	}
+	if err := s.stopWorkers(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// stopWorkers cancels the background workers and waits for them until ctx is done
+func (s *Server) stopWorkers(ctx context.Context) error {
+	s.cancelWorkers()
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop in time: %w", ctx.Err())
+	}
+}
+
+// HandleHealthz reports that the process is alive. It does not check dependencies, a failing database
+// should take the server out of rotation (readiness), not get it restarted.
+func (s *Server) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
+
+// HandleReadyz reports whether the server can handle requests: it is not shutting down, the database
+// responds and the readiness checks pass
+func (s *Server) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		problem.Write(w, r, http.StatusServiceUnavailable, "Shutting down")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if err := s.db.PingContext(ctx); err != nil {
+		problem.Write(w, r, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+	for _, check := range s.checks {
+		if err := check.check(ctx); err != nil {
+			slog.WarnContext(ctx, "Readiness check failed", "check", check.name, "error", err)
+			problem.Write(w, r, http.StatusServiceUnavailable, check.name+" check failed")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"instagram/internal/metrics"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+)
+
+// CommentService manages comments on posts
+type CommentService struct {
+	stores *repositories.Stores
+}
+
+// Create adds a comment to a post and sets its ID. Posts that are deleted or hidden cannot be commented on,
+// and only admins may comment as another user.
+func (s *CommentService) Create(ctx context.Context, comment *models.Comment) error {
+	if err := validation.Struct(comment); err != nil {
+		return err
+	}
+	if !IsOwnerOrAdmin(ctx, comment.UserID) {
+		return errForbidden
+	}
+
+	err := s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		post, err := tx.Posts.Get(ctx, comment.PostID)
+		if err != nil {
+			return err
+		}
+		if post.Hidden {
+			return repositories.NotFound("post with id %d not found", comment.PostID)
+		}
+		return tx.Comments.Create(ctx, comment)
+	})
+	if err != nil {
+		return err
+	}
+	metrics.CommentsCreated.Inc()
+	return nil
+}
+
+// Get returns a comment. Hidden comments are only shown to their author and admins.
+func (s *CommentService) Get(ctx context.Context, id int) (*models.Comment, error) {
+	comment, err := s.stores.Comments.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.Hidden && !IsOwnerOrAdmin(ctx, comment.UserID) {
+		return nil, repositories.NotFound("comment with id %d not found", id)
+	}
+	return comment, nil
+}
+
+// ListForPost returns a page of the comments of a post, oldest first
+func (s *CommentService) ListForPost(ctx context.Context, postID int, page models.Page) ([]models.Comment, error) {
+	return s.stores.Comments.ListForPost(ctx, postID, page)
+}
+
+// Delete deletes a comment on behalf of its author or an admin. Moderators remove other users' comments
+// through the admin API so the removal is recorded.
+func (s *CommentService) Delete(ctx context.Context, id int) error {
+	return s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		comment, err := tx.Comments.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !IsOwnerOrAdmin(ctx, comment.UserID) {
+			return errForbidden
+		}
+		return tx.Comments.Delete(ctx, id)
+	})
+}
+
+// Restore restores a deleted comment on behalf of its author or an admin, until it is purged. Comments
+// removed by a moderator can only be restored by an admin.
+func (s *CommentService) Restore(ctx context.Context, id int) error {
+	return s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		ownerID, err := tx.Comments.OwnerID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !IsOwnerOrAdmin(ctx, ownerID) {
+			return errForbidden
+		}
+
+		removed, err := tx.Comments.RemovedByModerator(ctx, id)
+		if err != nil {
+			return err
+		}
+		if removed && !isAdmin(ctx) {
+			return errRemovedByModerator
+		}
+		return tx.Comments.Restore(ctx, id)
+	})
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"instagram/internal/metrics"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+)
+
+// FollowService manages who follows whom
+type FollowService struct {
+	stores *repositories.Stores
+}
+
+// Follow makes a user follow another one, on behalf of the user or an admin. source is where the follow
+// comes from, one of the metrics sources, e.g. metrics.SourceImport for follows imported from an Instagram
+// export.
+func (s *FollowService) Follow(ctx context.Context, follow *models.Follow, source string) error {
+	if err := validation.Struct(follow); err != nil {
+		return err
+	}
+	if follow.FollowerID == follow.FollowingID {
+		return repositories.Invalid("You can't follow yourself")
+	}
+	if !IsOwnerOrAdmin(ctx, follow.FollowerID) {
+		return errForbidden
+	}
+
+	err := s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		if _, err := tx.Users.Get(ctx, follow.FollowingID); err != nil {
+			return err
+		}
+		return tx.Follows.Follow(ctx, follow)
+	})
+	if err != nil {
+		return err
+	}
+	metrics.Follows.WithLabelValues(source).Inc()
+	return nil
+}
+
+// Unfollow removes a follow on behalf of the follower or an admin
+func (s *FollowService) Unfollow(ctx context.Context, follow *models.Follow) error {
+	if err := validation.Struct(follow); err != nil {
+		return err
+	}
+	if !IsOwnerOrAdmin(ctx, follow.FollowerID) {
+		return errForbidden
+	}
+	return s.stores.Follows.Unfollow(ctx, follow)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"instagram/internal/metrics"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+)
+
+// PostService manages posts
+type PostService struct {
+	stores *repositories.Stores
+}
+
+// Create publishes a post and sets its ID. Only admins may post as another user.
+func (s *PostService) Create(ctx context.Context, post *models.Post) error {
+	if err := validation.Struct(post); err != nil {
+		return err
+	}
+	if !IsOwnerOrAdmin(ctx, post.UserID) {
+		return errForbidden
+	}
+	if err := checkMediaOwner(ctx, s.stores.Media, "image_url", post.ImageURL, post.UserID); err != nil {
+		return err
+	}
+
+	if err := s.stores.Posts.Create(ctx, post); err != nil {
+		return err
+	}
+	metrics.PostsCreated.WithLabelValues(metrics.SourceAPI).Inc()
+	return nil
+}
+
+// Import creates a post imported from an archive of another service with its original creation time, and
+// remembers which item of the archive it was. The importer stored the post's image for the author.
+func (s *PostService) Import(ctx context.Context, post *models.Post, source, externalID string) error {
+	if err := validation.Struct(post); err != nil {
+		return err
+	}
+	if !IsOwnerOrAdmin(ctx, post.UserID) {
+		return errForbidden
+	}
+
+	err := s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		if err := tx.Media.Add(ctx, post.UserID, post.ImageURL); err != nil {
+			return err
+		}
+		if err := tx.Posts.Create(ctx, post); err != nil {
+			return err
+		}
+		return tx.Imports.Add(ctx, post.UserID, source, externalID, post.ID)
+	})
+	if err != nil {
+		return err
+	}
+	metrics.PostsCreated.WithLabelValues(metrics.SourceImport).Inc()
+	return nil
+}
+
+// Get returns a post. Hidden posts are only shown to their author and admins.
+func (s *PostService) Get(ctx context.Context, id int) (*models.Post, error) {
+	post, err := s.stores.Posts.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.Hidden && !IsOwnerOrAdmin(ctx, post.UserID) {
+		return nil, repositories.NotFound("post with id %d not found", id)
+	}
+	return post, nil
+}
+
+// ListForUser returns a page of the posts of a user, newest first
+func (s *PostService) ListForUser(ctx context.Context, userID int, page models.Page) ([]models.Post, error) {
+	return s.stores.Posts.ListForUser(ctx, userID, page)
+}
+
+// Feed returns a page of the posts of the users a user follows, newest first. A feed tells whom the user
+// follows, so only the user and admins may read it.
+func (s *PostService) Feed(ctx context.Context, userID int, page models.Page) ([]models.FeedPost, error) {
+	if !IsOwnerOrAdmin(ctx, userID) {
+		return nil, errForbidden
+	}
+	return s.stores.Posts.Feed(ctx, userID, page)
+}
+
+// FeedAfter returns up to limit posts of the users a user follows with an ID above afterID, oldest first.
+// Like Feed, only the user and admins may read it.
+func (s *PostService) FeedAfter(ctx context.Context, userID, afterID, limit int) ([]models.FeedPost, error) {
+	if !IsOwnerOrAdmin(ctx, userID) {
+		return nil, errForbidden
+	}
+	return s.stores.Posts.FeedAfter(ctx, userID, afterID, limit)
+}
+
+// LatestID returns the ID of the most recent post, or 0 if there is none
+func (s *PostService) LatestID(ctx context.Context) (int, error) {
+	return s.stores.Posts.LatestID(ctx)
+}
+
+// Delete deletes a post on behalf of its author or an admin. Moderators remove other users' posts
+// through the admin API so the removal is recorded.
+func (s *PostService) Delete(ctx context.Context, id int) error {
+	return s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		post, err := tx.Posts.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !IsOwnerOrAdmin(ctx, post.UserID) {
+			return errForbidden
+		}
+		return tx.Posts.Delete(ctx, id)
+	})
+}
+
+// Restore restores a deleted post on behalf of its author or an admin, until it is purged. Posts removed
+// by a moderator can only be restored by an admin.
+func (s *PostService) Restore(ctx context.Context, id int) error {
+	return s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		ownerID, err := tx.Posts.OwnerID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !IsOwnerOrAdmin(ctx, ownerID) {
+			return errForbidden
+		}
+
+		removed, err := tx.Posts.RemovedByModerator(ctx, id)
+		if err != nil {
+			return err
+		}
+		if removed && !isAdmin(ctx) {
+			return errRemovedByModerator
+		}
+		return tx.Posts.Restore(ctx, id)
+	})
+}
@@ -0,0 +1,71 @@
+// Package services holds the business rules of users, posts, comments and follows: what is valid, who may
+// change what and which changes are made in one transaction. The HTTP handlers, the gRPC API and the
+// background jobs call the services and only translate requests and responses. Audit records are left to
+// the callers, which know where the request came from.
+package services
+
+import (
+	"context"
+	"errors"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/validation"
+)
+
+// Services holds a service per aggregate, built on the same stores
+type Services struct {
+	Users    *UserService
+	Posts    *PostService
+	Comments *CommentService
+	Follows  *FollowService
+}
+
+// New returns the services of the stores. Passwords of new users are hashed with bcryptCost.
+func New(stores *repositories.Stores, bcryptCost int) *Services {
+	return &Services{
+		Users:    &UserService{stores: stores, bcryptCost: bcryptCost},
+		Posts:    &PostService{stores: stores},
+		Comments: &CommentService{stores: stores},
+		Follows:  &FollowService{stores: stores},
+	}
+}
+
+// errForbidden is returned when the authenticated user may not act on a resource
+var errForbidden = repositories.Forbidden("Forbidden")
+
+// IsOwnerOrAdmin reports whether the authenticated user owns a resource or is an admin. The APIs use it
+// for the fields only the owner may see, like the email of a user.
+func IsOwnerOrAdmin(ctx context.Context, ownerID int) bool {
+	userID, ok := middleware.GetUserIDFromContext(ctx)
+	if ok && userID == ownerID {
+		return true
+	}
+
+	return isAdmin(ctx)
+}
+
+func isAdmin(ctx context.Context) bool {
+	role, _ := middleware.GetRoleFromContext(ctx)
+	return role == models.RoleAdmin
+}
+
+// errRemovedByModerator is returned when an author tries to restore content a moderator removed
+var errRemovedByModerator = repositories.Forbidden("Only admins can restore content removed by a moderator")
+
+// checkMediaOwner rejects a media URL that is a path on this server unless userID uploaded the file, so
+// nobody can show, or have the purger delete, the uploads of somebody else. External URLs are allowed.
+func checkMediaOwner(ctx context.Context, media repositories.MediaStore, field, mediaURL string, userID int) error {
+	if !validation.IsServerPath(mediaURL) {
+		return nil
+	}
+
+	uploaderID, err := media.UploaderID(ctx, mediaURL)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return err
+	}
+	if err != nil || uploaderID != userID {
+		return validation.Errors{{Field: field, Message: "must be a file uploaded by the user"}}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"instagram/internal/metrics"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"instagram/internal/validation"
+)
+
+// UserService manages user accounts
+type UserService struct {
+	stores     *repositories.Stores
+	bcryptCost int
+}
+
+// Create signs up a user with a password and returns the user as stored
+func (s *UserService) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := s.validateNew(ctx, user); err != nil {
+		return nil, err
+	}
+	if user.Username == "" || user.Email == "" || user.Password == "" {
+		return nil, repositories.Invalid("Username, Email, and Password are required")
+	}
+
+	passwordHash, err := utils.HashPassword(user.Password, s.bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	user.PasswordHash = passwordHash
+	user.Password = "" // Clear the password from memory so it's never accidentally exposed
+
+	savedUser, err := s.stores.Users.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Signups.WithLabelValues(metrics.SignupPassword).Inc()
+	return savedUser, nil
+}
+
+// CreateExternal signs up a user of an external identity provider and returns the user as stored. They
+// have no password and log in through the provider.
+func (s *UserService) CreateExternal(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := s.validateNew(ctx, user); err != nil {
+		return nil, err
+	}
+	if user.Username == "" || user.Email == "" {
+		return nil, repositories.Invalid("Username and Email are required")
+	}
+
+	user.PasswordHash = utils.UnusablePasswordHash
+	user.Password = ""
+
+	savedUser, err := s.stores.Users.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	metrics.Signups.WithLabelValues(metrics.SignupOIDC).Inc()
+	return savedUser, nil
+}
+
+// validateNew checks the profile of a user who is signing up
+func (s *UserService) validateNew(ctx context.Context, user *models.User) error {
+	if err := validation.Struct(user); err != nil {
+		return err
+	}
+	// A new user hasn't uploaded anything yet, so only external profile images are accepted
+	return checkMediaOwner(ctx, s.stores.Media, "profile_image", user.ProfileImage, 0)
+}
+
+// Get returns a user. Deactivated profiles are hidden from everyone but their owner and admins.
+func (s *UserService) Get(ctx context.Context, id int) (*models.User, error) {
+	user, err := s.stores.Users.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeactivatedAt != nil && !IsOwnerOrAdmin(ctx, user.ID) {
+		return nil, repositories.NotFound("user with id %d not found", id)
+	}
+	return user, nil
+}
+
+// Update changes the profile of a user on behalf of the user or an admin. It returns the user before
+// and after the change so callers can tell what changed. The password is changed separately.
+func (s *UserService) Update(ctx context.Context, user *models.User) (updated, previous *models.User, err error) {
+	if err := validation.Struct(user); err != nil {
+		return nil, nil, err
+	}
+	if user.Username == "" && user.Email == "" && user.Password == "" {
+		return nil, nil, repositories.Invalid("At least one field is required")
+	}
+	if !IsOwnerOrAdmin(ctx, user.ID) {
+		return nil, nil, errForbidden
+	}
+
+	user.Password = ""
+	err = s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		previous, err = tx.Users.Get(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if user.ProfileImage != previous.ProfileImage {
+			if err := checkMediaOwner(ctx, tx.Media, "profile_image", user.ProfileImage, user.ID); err != nil {
+				return err
+			}
+		}
+		updated, err = tx.Users.Update(ctx, user)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated, previous, nil
+}
+
+// Delete deletes a user on behalf of the user or an admin. It can be restored until it is purged.
+func (s *UserService) Delete(ctx context.Context, id int) error {
+	if !IsOwnerOrAdmin(ctx, id) {
+		return errForbidden
+	}
+	return s.stores.Users.Delete(ctx, id)
+}
+
+// Deactivate hides the authenticated user's profile and content and logs them out everywhere. Logging in
+// again reactivates the account.
+func (s *UserService) Deactivate(ctx context.Context, id int) error {
+	if userID, ok := middleware.GetUserIDFromContext(ctx); !ok || userID != id {
+		return errForbidden
+	}
+
+	return s.stores.Transaction(ctx, func(tx *repositories.Stores) error {
+		if err := tx.Users.Deactivate(ctx, id); err != nil {
+			return err
+		}
+		return tx.Sessions.RevokeAll(ctx, id)
+	})
+}
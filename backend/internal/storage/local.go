@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores media files in a directory on disk and serves them under a URL prefix
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal creates the media directory if needed. baseURL is the URL prefix files are served under, e.g. "/media".
+func NewLocal(dir, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// Save stores the content under a new random name that keeps the extension of name, and returns its URL
+func (s *Local) Save(name string, content io.Reader) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	fileName := hex.EncodeToString(b) + strings.ToLower(path.Ext(name))
+
+	file, err := os.OpenFile(filepath.Join(s.dir, fileName), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create media file: %w", err)
+	}
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write media file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write media file: %w", err)
+	}
+
+	return s.baseURL + "/" + fileName, nil
+}
+
+// Owns reports whether the URL points to a file in this storage. Posts can also reference external images.
+func (s *Local) Owns(url string) bool {
+	_, ok := s.fileName(url)
+	return ok
+}
+
+// Open opens a stored file by its URL
+func (s *Local) Open(url string) (*os.File, error) {
+	fileName, ok := s.fileName(url)
+	if !ok {
+		return nil, fmt.Errorf("%s is not stored locally", url)
+	}
+	return os.Open(filepath.Join(s.dir, fileName))
+}
+
+// Delete removes a stored file. URLs that are not stored locally and files that are already gone are ignored.
+func (s *Local) Delete(url string) error {
+	fileName, ok := s.fileName(url)
+	if !ok {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(s.dir, fileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete media file: %w", err)
+	}
+	return nil
+}
+
+// Handler serves the stored files, it expects to be mounted at the base URL
+func (s *Local) Handler() http.Handler {
+	return http.StripPrefix(s.baseURL+"/", http.FileServer(http.Dir(s.dir)))
+}
+
+// fileName returns the name of the file a URL refers to. Only plain file names directly under the
+// base URL are accepted, so a URL can never point outside of the media directory.
+func (s *Local) fileName(url string) (string, bool) {
+	fileName, found := strings.CutPrefix(url, s.baseURL+"/")
+	if !found || fileName == "" || strings.ContainsAny(fileName, `/\`) || fileName == "." || fileName == ".." {
+		return "", false
+	}
+	return fileName, true
+}
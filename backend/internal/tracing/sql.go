@@ -0,0 +1,240 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes recorded on SQL spans besides the semantic convention ones
+const (
+	rowsReturnedKey = attribute.Key("db.response.returned_rows")
+	rowsAffectedKey = attribute.Key("db.response.affected_rows")
+)
+
+// OpenDB is like sql.Open, but every statement run with a traced context gets its own span with
+// the statement text and the number of rows it returned or changed. Statements run outside of a
+// trace, like those of the background jobs, are not recorded.
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	// sql.Open does not connect, it is only used to look up the registered driver
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&connector{driver: drv, dsn: dsn}), nil
+}
+
+type connector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// startSQLSpan starts a span for query if ctx is part of a trace. The returned span is a no-op
+// otherwise.
+func startSQLSpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	operation := "SQL"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	return Tracer().Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemSqlite,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		),
+	)
+}
+
+// endSQLSpan records err on span and ends it. driver.ErrSkip is not an error, it makes
+// database/sql fall back to preparing the statement, which is traced on its own.
+func endSQLSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, driver.ErrSkip) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQLSpan(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		endSQLSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQLSpan(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	recordRowsAffected(span, result, err)
+	endSQLSpan(span, err)
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	ctx, span := startSQLSpan(ctx, "BEGIN")
+	var tx driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	endSQLSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedTx{Tx: tx, ctx: ctx}, nil
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+type tracedStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := s.Stmt.(driver.StmtQueryContext)
+	if !ok {
+		return nil, errors.New("statement does not support QueryContext")
+	}
+	ctx, span := startSQLSpan(ctx, s.query)
+	rows, err := queryer.QueryContext(ctx, args)
+	if err != nil {
+		endSQLSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := s.Stmt.(driver.StmtExecContext)
+	if !ok {
+		return nil, errors.New("statement does not support ExecContext")
+	}
+	ctx, span := startSQLSpan(ctx, s.query)
+	result, err := execer.ExecContext(ctx, args)
+	recordRowsAffected(span, result, err)
+	endSQLSpan(span, err)
+	return result, err
+}
+
+// tracedTx records the end of a transaction in the trace the transaction was started in
+type tracedTx struct {
+	driver.Tx
+	ctx context.Context
+}
+
+func (t *tracedTx) Commit() error {
+	_, span := startSQLSpan(t.ctx, "COMMIT")
+	err := t.Tx.Commit()
+	endSQLSpan(span, err)
+	return err
+}
+
+func (t *tracedTx) Rollback() error {
+	_, span := startSQLSpan(t.ctx, "ROLLBACK")
+	err := t.Tx.Rollback()
+	endSQLSpan(span, err)
+	return err
+}
+
+// tracedRows counts the rows read and ends the span of its query when it is closed
+type tracedRows struct {
+	driver.Rows
+	span  trace.Span
+	count int64
+	err   error
+}
+
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if err == nil {
+		err = r.err
+	}
+	r.span.SetAttributes(rowsReturnedKey.Int64(r.count))
+	endSQLSpan(r.span, err)
+	return err
+}
+
+func recordRowsAffected(span trace.Span, result driver.Result, err error) {
+	if err != nil || result == nil {
+		return
+	}
+	if affected, err := result.RowsAffected(); err == nil {
+		span.SetAttributes(rowsAffectedKey.Int64(affected))
+	}
+}
@@ -0,0 +1,79 @@
+// Package tracing sets up OpenTelemetry tracing and instruments the database and outgoing HTTP calls.
+// Incoming requests are traced by the tracing middleware.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"instagram/internal/config"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies this server in traces
+const ServiceName = "instagram"
+
+// Tracer returns the tracer of the server. It uses the provider installed by Setup, or a no-op
+// provider when tracing is not set up.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Setup installs the W3C trace context propagator and a tracer provider exporting to the configured
+// exporter. The returned function flushes the remaining spans and must be called before exiting.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Exporter == config.TracingNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var closer io.Closer
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case config.TracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case config.TracingFile:
+		var file *os.File
+		file, err = os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		closer = file
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	case config.TracingOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	default:
+		err = fmt.Errorf("unknown exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// PersonalAccessTokenPrefix identifies personal access tokens, so they can be told apart from JWTs
+// and spotted by secret scanners
+const PersonalAccessTokenPrefix = "igp_"
+
+// GeneratePersonalAccessToken returns a new random personal access token
+func GeneratePersonalAccessToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	return PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// IsPersonalAccessToken reports whether the bearer token is a personal access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashPersonalAccessToken hashes a personal access token for storage and lookup
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,26 +1,58 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 )
 import "github.com/golang-jwt/jwt/v5"
 
-var JWTSecret = []byte("an_actual_secret_instead_of_this")
+// MFAChallengePurpose marks a token that only proves the password step of a login
+const MFAChallengePurpose = "mfa_challenge"
+
+// MFAChallengeTTL is how long a user has to complete the second login step
+const MFAChallengeTTL = 5 * time.Minute
+
+// ExportDownloadPurpose marks a token that grants access to a single data export archive
+const ExportDownloadPurpose = "export_download"
+
+// ExportDownloadTTL is how long a data export download link stays valid
+const ExportDownloadTTL = 15 * time.Minute
+
+// OIDCLinkPurpose marks a token that proves a login at an identity provider whose verified email
+// belongs to an existing account, which is only linked once the account's password is given too
+const OIDCLinkPurpose = "oidc_link"
+
+// OIDCLinkTTL is how long a user has to confirm linking an external identity with their password
+const OIDCLinkTTL = 10 * time.Minute
 
 // Claims structure for JWT (custom claims + standard claims)
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID    int    `json:"user_id"`
+	SessionID int    `json:"sid,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
+	ExportID  int    `json:"export_id,omitempty"`
+	// Provider and Email describe the external identity of an OIDC link token, whose subject is the identity's subject
+	Provider string `json:"provider,omitempty"`
+	Email    string `json:"email,omitempty"`
+	// Restore marks an MFA challenge of a deleted account, which is restored once the challenge is passed
+	Restore bool `json:"restore,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID int) (string, *jwt.RegisteredClaims, error) {
-	// Set the expiration time for the token (1 day from now)
-	expirationTime := time.Now().Add(24 * time.Hour)
+// SessionDuration is how long a login lasts, both the session and the JWT issued for it expire after it
+const SessionDuration = 24 * time.Hour
+
+// GenerateJWT issues a token for the given user that is bound to one of their sessions
+func GenerateJWT(secret []byte, userID, sessionID int) (string, *jwt.RegisteredClaims, error) {
+	// Set the expiration time for the token
+	expirationTime := time.Now().Add(SessionDuration)
 
 	// Create the claims, which includes the userID and expiration time
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -31,7 +63,7 @@ func GenerateJWT(userID int) (string, *jwt.RegisteredClaims, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign the token with the secret key
-	tokenString, err := token.SignedString(JWTSecret)
+	tokenString, err := token.SignedString(secret)
 	if err != nil {
 		return "", nil, err
 	}
@@ -40,15 +72,174 @@ func GenerateJWT(userID int) (string, *jwt.RegisteredClaims, error) {
 	return tokenString, &claims.RegisteredClaims, nil
 }
 
+// GenerateMFAChallengeJWT issues a short-lived token that can only be exchanged for a real JWT
+// together with a valid second factor. Its ID lets the server accept it only once.
+func GenerateMFAChallengeJWT(secret []byte, userID int, restore bool) (string, *jwt.RegisteredClaims, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: MFAChallengePurpose,
+		Restore: restore,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, &claims.RegisteredClaims, nil
+}
+
+// VerifyMFAChallengeJWT verifies an MFA challenge token and returns the user ID it was issued for,
+// whether the login restores a deleted account, and the token's ID and expiry
+func VerifyMFAChallengeJWT(secret []byte, tokenString string) (*Claims, error) {
+	token, err := VerifyJWT(secret, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != MFAChallengePurpose {
+		return nil, fmt.Errorf("not an mfa challenge token")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	id, _ := claims["jti"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if id == "" || err != nil || expiresAt == nil {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	restore, _ := claims["restore"].(bool)
+
+	return &Claims{
+		UserID:           int(userID),
+		Purpose:          MFAChallengePurpose,
+		Restore:          restore,
+		RegisteredClaims: jwt.RegisteredClaims{ID: id, ExpiresAt: expiresAt},
+	}, nil
+}
+
+// GenerateOIDCLinkJWT issues a short-lived token for linking an external identity to an existing user,
+// to be exchanged for a session together with the user's password
+func GenerateOIDCLinkJWT(secret []byte, userID int, provider, subject, email string) (string, *jwt.RegisteredClaims, error) {
+	claims := &Claims{
+		UserID:   userID,
+		Purpose:  OIDCLinkPurpose,
+		Provider: provider,
+		Email:    email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(OIDCLinkTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, &claims.RegisteredClaims, nil
+}
+
+// VerifyOIDCLinkJWT verifies an OIDC link token and returns the user and the external identity it was issued for
+func VerifyOIDCLinkJWT(secret []byte, tokenString string) (*Claims, error) {
+	token, err := VerifyJWT(secret, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != OIDCLinkPurpose {
+		return nil, fmt.Errorf("not an oidc link token")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	provider, _ := claims["provider"].(string)
+	email, _ := claims["email"].(string)
+	subject, err := claims.GetSubject()
+	if provider == "" || subject == "" || err != nil {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	return &Claims{
+		UserID:           int(userID),
+		Purpose:          OIDCLinkPurpose,
+		Provider:         provider,
+		Email:            email,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+	}, nil
+}
+
+// GenerateExportDownloadJWT issues a short-lived token for downloading one of the user's data exports.
+// It is sent as a query parameter so the link works when opened directly in a browser.
+func GenerateExportDownloadJWT(secret []byte, userID, exportID int) (string, *jwt.RegisteredClaims, error) {
+	claims := &Claims{
+		UserID:   userID,
+		Purpose:  ExportDownloadPurpose,
+		ExportID: exportID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ExportDownloadTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(secret)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, &claims.RegisteredClaims, nil
+}
+
+// VerifyExportDownloadJWT verifies a download token and returns the user and export it was issued for
+func VerifyExportDownloadJWT(secret []byte, tokenString string) (int, int, error) {
+	token, err := VerifyJWT(secret, tokenString)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != ExportDownloadPurpose {
+		return 0, 0, fmt.Errorf("not an export download token")
+	}
+
+	userID, userOK := claims["user_id"].(float64)
+	exportID, exportOK := claims["export_id"].(float64)
+	if !userOK || !exportOK {
+		return 0, 0, fmt.Errorf("invalid token claims")
+	}
+
+	return int(userID), int(exportID), nil
+}
+
 // VerifyJWT Function to verify JWT tokens
-func VerifyJWT(tokenString string) (*jwt.Token, error) {
+func VerifyJWT(secret []byte, tokenString string) (*jwt.Token, error) {
 	// Parse the token with the secret key
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// Ensure the signing method is HMAC (SigningMethodHS256 in this case)
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return JWTSecret, nil
+		return secret, nil
 	})
 
 	// Check for parsing or verification errors
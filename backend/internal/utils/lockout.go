@@ -0,0 +1,29 @@
+package utils
+
+import "time"
+
+const (
+	// LoginLockoutThreshold is the number of consecutive failed logins before an account is locked
+	LoginLockoutThreshold = 5
+	// loginLockoutBase is the lockout applied when the threshold is first reached
+	loginLockoutBase = time.Minute
+	// loginLockoutMax caps the exponential backoff
+	loginLockoutMax = 24 * time.Hour
+)
+
+// LoginLockoutDuration returns how long an account stays locked after the given number of
+// consecutive failed logins. The lockout doubles with every failure past the threshold.
+func LoginLockoutDuration(failedAttempts int) time.Duration {
+	if failedAttempts < LoginLockoutThreshold {
+		return 0
+	}
+
+	lockout := loginLockoutBase
+	for i := LoginLockoutThreshold; i < failedAttempts; i++ {
+		lockout *= 2
+		if lockout >= loginLockoutMax {
+			return loginLockoutMax
+		}
+	}
+	return lockout
+}
@@ -4,9 +4,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword generates a bcrypt hash for the given password.
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+// HashPassword generates a bcrypt hash for the given password with the given cost.
+func HashPassword(password string, cost int) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	return string(bytes), err
 }
 
@@ -15,3 +15,7 @@ func VerifyPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// UnusablePasswordHash is stored for accounts created through an external identity provider.
+// It is not a valid bcrypt hash, so no password will ever match it.
+const UnusablePasswordHash = "!"
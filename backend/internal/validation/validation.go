@@ -0,0 +1,161 @@
+// Package validation decodes request bodies strictly and checks them against the rules declared in the
+// `validate` struct tags of the models.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// MaxBodyBytes bounds JSON request bodies. Uploads use multipart forms with their own limits.
+const MaxBodyBytes = 1 << 20
+
+// UsernamePattern matches the characters usernames may have, the same ones usernames derived from
+// external identities are made of
+const UsernamePattern = `^[a-z0-9._]+$`
+
+var usernameRegexp = regexp.MustCompile(UsernamePattern)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Errors name fields as clients send them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// username: lowercase letters, digits, dots and underscores
+	must(v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernameRegexp.MatchString(fl.Field().String())
+	}))
+
+	// mediaurl: an absolute http(s) URL or a path on this server, like the URLs of uploaded media
+	must(v.RegisterValidation("mediaurl", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		if err != nil {
+			return false
+		}
+		if u.Scheme == "" && u.Host == "" {
+			return strings.HasPrefix(u.Path, "/")
+		}
+		return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	}))
+
+	return v
+}
+
+// IsServerPath reports whether a media URL is a path on this server rather than an external URL
+func IsServerPath(mediaURL string) bool {
+	u, err := url.Parse(mediaURL)
+	return err == nil && u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/")
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// FieldError describes why a field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists all invalid fields of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Field + " " + fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Decode reads a single JSON value from the body of r into v. Unknown fields, trailing data and bodies
+// larger than MaxBodyBytes are rejected, the latter with an *http.MaxBytesError.
+func Decode(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// Struct checks v against the rules in its struct tags and returns Errors if any field is invalid
+func Struct(v any) error {
+	return convert(validate.Struct(v), "")
+}
+
+// Var checks a single value against rules, reporting problems under the field name
+func Var(field string, value any, rules string) error {
+	return convert(validate.Var(value, rules), field)
+}
+
+func convert(err error, field string) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	errs := make(Errors, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		name := field
+		if name == "" {
+			name = fieldErr.Field()
+		}
+		errs = append(errs, FieldError{Field: name, Message: message(fieldErr)})
+	}
+	return errs
+}
+
+// message explains a failed rule in words
+func message(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "username":
+		return "may only contain lowercase letters, digits, dots and underscores"
+	case "mediaurl", "url":
+		return "must be an http(s) URL or a path on this server"
+	case "min":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", err.Param())
+		}
+		return "must be at least " + err.Param()
+	case "max":
+		if err.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", err.Param())
+		}
+		return "must be at most " + err.Param()
+	case "gt":
+		return "must be greater than " + err.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(err.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
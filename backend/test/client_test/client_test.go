@@ -0,0 +1,227 @@
+package client_test
+
+import (
+	"context"
+	"database/sql"
+	"instagram/internal/client"
+	"instagram/internal/config"
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/routes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupServer serves the real routers on an in-memory database, the way the server mounts them
+func setupServer(t *testing.T) (*httptest.Server, *sql.DB) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// Only one connection may be used, every new connection would get its own empty in-memory database
+	db.SetMaxOpenConns(1)
+
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "a_secret_that_is_only_used_in_tests"
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+
+	app := handlers.NewApp(cfg, slog.Default(), db, nil)
+	protected := func(router http.Handler) http.Handler {
+		return middleware.JWTMiddleware(router, db, cfg.Auth.JWTSecret)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", routes.AuthRouter(app))
+	mux.Handle("/users/", protected(routes.UserRouter(app)))
+	mux.Handle("/follow/", protected(routes.FollowRouter(app)))
+	mux.Handle("/post/", protected(routes.PostRouter(app)))
+	mux.Handle("/comment/", protected(routes.CommentRouter(app)))
+	server := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		server.Close()
+		_ = db.Close()
+	})
+	return server, db
+}
+
+func signup(t *testing.T, server *httptest.Server, username string) *client.Client {
+	c := client.New(server.URL, server.Client())
+	_, err := c.Signup(context.Background(), models.User{
+		Auth:     models.Auth{Username: username, Email: username + "@example.com"},
+		Password: "password",
+	})
+	assert.NoError(t, err)
+	assert.NotZero(t, c.UserID())
+	return c
+}
+
+// collect drains an iterator, failing the test on errors
+func collect[T any](t *testing.T, seq func(yield func(T, error) bool)) []T {
+	var entries []T
+	for entry, err := range seq {
+		if !assert.NoError(t, err) {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestClientPostsCommentsAndFeed(t *testing.T) {
+	server, _ := setupServer(t)
+	ctx := context.Background()
+
+	author := signup(t, server, "author")
+	reader := signup(t, server, "reader")
+	// Small pages make the iterators fetch several of them
+	reader.PageSize = 2
+
+	for _, caption := range []string{"first", "second", "third"} {
+		post, err := author.CreatePost(ctx, models.Post{UserID: author.UserID(), ImageURL: "https://example.com/a.jpg", Caption: caption})
+		if assert.NoError(t, err) {
+			assert.NotZero(t, post.ID)
+			assert.Equal(t, caption, post.Caption)
+		}
+	}
+	assert.NoError(t, reader.Follow(ctx, models.Follow{FollowerID: reader.UserID(), FollowingID: author.UserID()}))
+
+	feed := collect(t, reader.Feed(ctx, reader.UserID()))
+	if assert.Len(t, feed, 3) {
+		assert.Equal(t, "third", feed[0].Caption)
+		assert.Equal(t, "first", feed[2].Caption)
+		assert.Equal(t, "author", feed[0].Username)
+	}
+
+	posts := collect(t, reader.PostsForUser(ctx, author.UserID()))
+	if !assert.Len(t, posts, 3) {
+		return
+	}
+	postID := posts[0].ID
+
+	post, err := reader.GetPost(ctx, postID)
+	assert.NoError(t, err)
+	assert.Equal(t, "third", post.Caption)
+
+	for _, content := range []string{"nice", "great", "wow"} {
+		err := reader.CreateComment(ctx, models.Comment{PostID: postID, UserID: reader.UserID(), Content: content})
+		assert.NoError(t, err)
+	}
+	comments := collect(t, reader.CommentsForPost(ctx, postID))
+	if assert.Len(t, comments, 3) {
+		assert.Equal(t, "nice", comments[0].Content)
+		assert.Equal(t, "wow", comments[2].Content)
+	}
+
+	// Iteration can stop early
+	count := 0
+	for range reader.Feed(ctx, reader.UserID()) {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, reader.Unfollow(ctx, models.Follow{FollowerID: reader.UserID(), FollowingID: author.UserID()}))
+	assert.Empty(t, collect(t, reader.Feed(ctx, reader.UserID())))
+}
+
+func TestClientUsers(t *testing.T) {
+	server, _ := setupServer(t)
+	ctx := context.Background()
+
+	c := signup(t, server, "someone")
+
+	updated, err := c.UpdateUser(ctx, models.User{Auth: models.Auth{ID: c.UserID(), Username: "renamed"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "renamed", updated.Username)
+	}
+
+	user, err := c.GetUser(ctx, c.UserID())
+	if assert.NoError(t, err) {
+		assert.Equal(t, "renamed", user.Username)
+	}
+
+	// Users can only update themselves
+	_, err = c.UpdateUser(ctx, models.User{Auth: models.Auth{ID: c.UserID() + 1, Username: "other"}})
+	assert.ErrorIs(t, err, client.ErrForbidden)
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	server, _ := setupServer(t)
+	ctx := context.Background()
+
+	c := signup(t, server, "someone")
+
+	_, err := c.GetPost(ctx, 42)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	var apiErr *client.Error
+	if assert.ErrorAs(t, err, &apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal(t, "/post/42", apiErr.Problem.Instance)
+	}
+
+	_, err = c.CreatePost(ctx, models.Post{UserID: c.UserID()})
+	assert.ErrorIs(t, err, client.ErrValidation)
+	if assert.ErrorAs(t, err, &apiErr) && assert.Len(t, apiErr.Problem.Errors, 1) {
+		assert.Equal(t, "image_url", apiErr.Problem.Errors[0].Field)
+	}
+
+	// Signing up twice with the same username is a conflict
+	_, err = client.New(server.URL, server.Client()).Signup(ctx, models.User{
+		Auth:     models.Auth{Username: "someone", Email: "other@example.com"},
+		Password: "password",
+	})
+	assert.ErrorIs(t, err, client.ErrConflict)
+
+	_, err = client.New(server.URL, server.Client()).Login(ctx, "someone@example.com", "wrong")
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+
+	// Requests without credentials are rejected
+	_, err = client.New(server.URL, server.Client()).GetUser(ctx, c.UserID())
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+}
+
+func TestClientRenewsEndedSession(t *testing.T) {
+	server, db := setupServer(t)
+	ctx := context.Background()
+
+	signup(t, server, "someone")
+	c := client.New(server.URL, server.Client())
+	_, err := c.Login(ctx, "someone@example.com", "password")
+	assert.NoError(t, err)
+
+	// Ending every session, e.g. from another device, makes the client log in again
+	_, err = db.Exec("UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP")
+	assert.NoError(t, err)
+
+	user, err := c.GetUser(ctx, c.UserID())
+	assert.NoError(t, err)
+	assert.Equal(t, "someone", user.Username)
+
+	var active int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sessions WHERE revoked_at IS NULL").Scan(&active))
+	assert.Equal(t, 1, active)
+
+	// Tokens set directly are not renewed
+	token := client.New(server.URL, server.Client())
+	token.SetToken("not-a-token")
+	_, err = token.GetUser(ctx, 1)
+	assert.ErrorIs(t, err, client.ErrUnauthorized)
+}
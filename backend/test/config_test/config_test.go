@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"instagram/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "a_secret_that_is_only_used_in_tests"
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+
+	cfg, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, "instagram.db", cfg.Database.Path)
+	assert.Equal(t, 14, cfg.Auth.BcryptCost)
+	assert.Equal(t, config.DefaultDeletionGracePeriod, cfg.Deletion.GracePeriod)
+}
+
+// TestLoadPrecedence checks that the environment overrides the file and flags override both
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: ":9000"
+database:
+  path: file.db
+auth:
+  jwt_secret: `+testSecret+`
+  bcrypt_cost: 10
+cors:
+  allowed_origins: ["https://example.com"]
+  allow_credentials: true
+exports:
+  retention: 48h
+`)
+	t.Setenv("DATABASE_PATH", "env.db")
+	t.Setenv("BCRYPT_COST", "12")
+
+	cfg, err := config.Load([]string{"-config", path, "-db", "flag.db"})
+	assert.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, "flag.db", cfg.Database.Path)
+	assert.Equal(t, 12, cfg.Auth.BcryptCost)
+	assert.Equal(t, []string{"https://example.com"}, cfg.CORS.AllowedOrigins)
+	assert.True(t, cfg.CORS.AllowCredentials)
+	assert.Equal(t, 48*time.Hour, cfg.Exports.Retention)
+}
+
+func TestLoadRejectsInvalidConfiguration(t *testing.T) {
+	// There is no default secret
+	_, err := config.Load(nil)
+	assert.ErrorContains(t, err, "jwt_secret")
+
+	t.Setenv("JWT_SECRET", testSecret)
+
+	t.Setenv("BCRYPT_COST", "2")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "bcrypt_cost")
+	t.Setenv("BCRYPT_COST", "12")
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "allow_credentials")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+
+	t.Setenv("DELETION_GRACE_PERIOD", "soon")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "DELETION_GRACE_PERIOD")
+	t.Setenv("DELETION_GRACE_PERIOD", "24h")
+
+	t.Setenv("LOG_LEVEL", "verbose")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "logging.level")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	t.Setenv("TRACING_EXPORTER", "jaeger")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "tracing.exporter")
+	t.Setenv("TRACING_EXPORTER", "otlp")
+	_, err = config.Load(nil)
+	assert.ErrorContains(t, err, "tracing.endpoint")
+	t.Setenv("TRACING_EXPORTER", "none")
+
+	_, err = config.Load([]string{"-config", writeConfigFile(t, "server:\n  port: 8080\n")})
+	assert.ErrorContains(t, err, "port")
+
+	_, err = config.Load([]string{"-unknown"})
+	assert.Error(t, err)
+}
+
+// TestLoadOIDCProviders checks that OIDC_PROVIDERS selects the providers and their variables override the file
+func TestLoadOIDCProviders(t *testing.T) {
+	path := writeConfigFile(t, `
+auth:
+  jwt_secret: `+testSecret+`
+oidc:
+  providers:
+    - name: google
+      issuer: https://accounts.google.com
+      client_id: file-client
+      client_secret: file-secret
+      redirect_url: https://instagram.example/auth/oidc/google/callback
+    - name: gitlab
+      issuer: https://gitlab.com
+      client_id: gitlab-client
+      client_secret: gitlab-secret
+      redirect_url: https://instagram.example/auth/oidc/gitlab/callback
+`)
+
+	cfg, err := config.Load([]string{"-config", path})
+	assert.NoError(t, err)
+	assert.Len(t, cfg.OIDC.Providers, 2)
+
+	t.Setenv("OIDC_PROVIDERS", "google")
+	t.Setenv("OIDC_GOOGLE_CLIENT_SECRET", "env-secret")
+	t.Setenv("OIDC_GOOGLE_SCOPES", "openid email")
+	cfg, err = config.Load([]string{"-config", path})
+	assert.NoError(t, err)
+	assert.Equal(t, []config.OIDCProviderConfig{{
+		Name:         "google",
+		Issuer:       "https://accounts.google.com",
+		ClientID:     "file-client",
+		ClientSecret: "env-secret",
+		RedirectURL:  "https://instagram.example/auth/oidc/google/callback",
+		Scopes:       []string{"openid", "email"},
+	}}, cfg.OIDC.Providers)
+
+	// Providers only configured in the environment need every setting there
+	t.Setenv("OIDC_PROVIDERS", "google,azure")
+	_, err = config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "oidc.providers[1].issuer")
+	assert.ErrorContains(t, err, "oidc.providers[1].client_id")
+
+	// The callback has to be the provider's callback on this server
+	t.Setenv("OIDC_PROVIDERS", "google")
+	t.Setenv("OIDC_GOOGLE_REDIRECT_URL", "https://instagram.example/auth/oidc/gitlab/callback")
+	_, err = config.Load([]string{"-config", path})
+	assert.ErrorContains(t, err, "oidc.providers[0].redirect_url")
+}
+
+func TestDatabaseDSNEnforcesForeignKeys(t *testing.T) {
+	assert.Equal(t, "instagram.db?_foreign_keys=on", config.DatabaseConfig{Path: "instagram.db"}.DSN())
+	assert.Equal(t, "file:instagram.db?mode=ro&_foreign_keys=on", config.DatabaseConfig{Path: "file:instagram.db?mode=ro"}.DSN())
+}
@@ -0,0 +1,283 @@
+package graphql_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"instagram/internal/config"
+	"instagram/internal/graphql"
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/routes"
+	"instagram/internal/services"
+	"instagram/internal/tracing"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	gql "github.com/graphql-go/graphql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupDB creates an in-memory database with three users: 1 follows 2 and 3, who each have a post
+// with comments and likes
+func setupDB(t *testing.T) *sql.DB {
+	// Statements are traced so that tests can count them
+	db, err := tracing.OpenDB("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// Only one connection may be used, every new connection would get its own empty in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO users (id, username, email, password_hash, bio) VALUES
+            (1, 'reader', 'reader@example.com', 'x', 'reads'),
+            (2, 'alice', 'alice@example.com', 'x', 'posts'),
+            (3, 'bob', 'bob@example.com', 'x', NULL);
+        INSERT INTO follows (follower_id, following_id) VALUES (1, 2), (1, 3);
+        INSERT INTO posts (id, user_id, image_url, caption, created_at) VALUES
+            (1, 2, 'https://example.com/1.jpg', 'first', '2024-01-01 10:00:00'),
+            (2, 3, 'https://example.com/2.jpg', 'second', '2024-01-02 10:00:00');
+        INSERT INTO comments (post_id, user_id, content) VALUES
+            (1, 1, 'one'), (1, 3, 'two'), (1, 1, 'three'), (1, 3, 'four'), (2, 2, 'hi');
+        INSERT INTO likes (user_id, post_id) VALUES (1, 1), (3, 1), (1, 2);
+    `)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+	return db
+}
+
+// asUser returns a context authenticated as the user
+func asUser(userID int) context.Context {
+	return middleware.WithUser(context.Background(), userID, models.RoleUser)
+}
+
+// run executes a request with the services and stores of the database, like the GraphQL handler
+func run(ctx context.Context, db *sql.DB, request models.GraphQLRequest) *gql.Result {
+	stores := repositories.NewSQLiteStores(db)
+	return graphql.Execute(ctx, services.New(stores, bcrypt.MinCost), stores, request)
+}
+
+// execute runs a query and returns its data as JSON, failing the test on errors
+func execute(t *testing.T, ctx context.Context, db *sql.DB, query string) string {
+	result := run(ctx, db, models.GraphQLRequest{Query: query})
+	assert.Empty(t, result.Errors)
+
+	data, err := json.Marshal(result.Data)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestPostWithAuthorCommentsAndCounts(t *testing.T) {
+	db := setupDB(t)
+
+	data := execute(t, asUser(1), db, `{
+        post(id: 1) {
+            caption
+            author { username bio email }
+            likeCount
+            commentCount
+            comments(first: 2) { content author { username } }
+        }
+    }`)
+	assert.JSONEq(t, `{"post": {
+        "caption": "first",
+        "author": {"username": "alice", "bio": "posts", "email": null},
+        "likeCount": 2,
+        "commentCount": 4,
+        "comments": [
+            {"content": "one", "author": {"username": "reader"}},
+            {"content": "two", "author": {"username": "bob"}}
+        ]
+    }}`, data)
+
+	// Missing objects are null
+	data = execute(t, asUser(1), db, `{ post(id: 42) { id } user(id: 42) { id } comment(id: 42) { id } }`)
+	assert.JSONEq(t, `{"post": null, "user": null, "comment": null}`, data)
+
+	// Comments hidden by a moderator are only shown to their author
+	_, err := db.Exec(`INSERT INTO report_resolutions (target_type, target_id, resolution) VALUES ('comment', 1, 'hidden')`)
+	assert.NoError(t, err)
+	data = execute(t, asUser(2), db, `{ comment(id: 1) { content } }`)
+	assert.JSONEq(t, `{"comment": null}`, data)
+	data = execute(t, asUser(1), db, `{ comment(id: 1) { content } }`)
+	assert.JSONEq(t, `{"comment": {"content": "one"}}`, data)
+}
+
+func TestViewerAndFeed(t *testing.T) {
+	db := setupDB(t)
+
+	data := execute(t, asUser(1), db, `{
+        viewer {
+            username email followerCount followingCount
+            following { username followerCount posts { caption } }
+        }
+        feed(limit: 1, offset: 1) { caption author { username } }
+    }`)
+	assert.JSONEq(t, `{
+        "viewer": {
+            "username": "reader", "email": "reader@example.com", "followerCount": 0, "followingCount": 2,
+            "following": [
+                {"username": "bob", "followerCount": 1, "posts": [{"caption": "second"}]},
+                {"username": "alice", "followerCount": 1, "posts": [{"caption": "first"}]}
+            ]
+        },
+        "feed": [{"caption": "first", "author": {"username": "alice"}}]
+    }`, data)
+
+	// Deactivated users are hidden from others, and their follows are not counted
+	_, err := db.Exec("UPDATE users SET deactivated_at = CURRENT_TIMESTAMP WHERE id = 3")
+	assert.NoError(t, err)
+	data = execute(t, asUser(1), db, `{ user(id: 3) { id } viewer { followingCount } }`)
+	assert.JSONEq(t, `{"user": null, "viewer": {"followingCount": 1}}`, data)
+	data = execute(t, asUser(3), db, `{ user(id: 3) { username } }`)
+	assert.JSONEq(t, `{"user": {"username": "bob"}}`, data)
+}
+
+func TestLoadsAreBatched(t *testing.T) {
+	db := setupDB(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, span := tracing.Tracer().Start(asUser(1), "query")
+	execute(t, ctx, db, `{
+        feed {
+            author { username followerCount }
+            likeCount
+            commentCount
+            comments { author { username } }
+        }
+    }`)
+	span.End()
+
+	queries := 0
+	for _, ended := range recorder.Ended() {
+		if ended.Name() == "SELECT" {
+			queries++
+		}
+	}
+	// The feed, then one query per level and kind of object no matter how many posts there are: the
+	// authors, their follower counts, the like counts, the comment counts, the comments and their authors
+	assert.Equal(t, 7, queries)
+}
+
+func TestScopes(t *testing.T) {
+	db := setupDB(t)
+
+	ctx := middleware.WithScopes(asUser(1), []string{models.ScopePostsRead})
+	result := run(ctx, db, models.GraphQLRequest{Query: `{ post(id: 1) { caption author { username } } }`})
+
+	data, err := json.Marshal(result.Data)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"post": {"caption": "first", "author": null}}`, string(data))
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, models.ScopeUsersRead)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	db := setupDB(t)
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		err       string
+	}{
+		{
+			name:  "too deep",
+			query: `{ viewer { following { following { following { following { following { following { following { id } } } } } } } } }`,
+			err:   "depth 9",
+		},
+		{
+			name:  "too deep through fragments",
+			query: `{ viewer { ...F } } fragment F on User { following { following { following { following { following { following { following { id } } } } } } } }`,
+			err:   "depth 9",
+		},
+		{
+			name:  "too complex",
+			query: `{ feed(limit: 100) { comments(first: 20) { author { posts(limit: 100) { id } } } } }`,
+			err:   "complexity",
+		},
+		{
+			name:      "too complex through variables",
+			query:     `query Q($n: Int) { feed(limit: $n) { author { followers(limit: $n) { followers(limit: $n) { id } } } } }`,
+			variables: map[string]interface{}{"n": float64(50)},
+			err:       "complexity",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run(asUser(1), db, models.GraphQLRequest{Query: tt.query, Variables: tt.variables})
+			assert.Nil(t, result.Data)
+			if assert.Len(t, result.Errors, 1) {
+				assert.Contains(t, result.Errors[0].Message, tt.err)
+			}
+		})
+	}
+
+	// Queries within the limits run. Lists without a limit count as defaultListLimit entries.
+	execute(t, asUser(1), db, `{ viewer { following { following(limit: 5) { following(limit: 5) { id } } } } }`)
+	execute(t, asUser(1), db, `{ feed(limit: 10) { comments(first: 5) { author { posts(limit: 10) { id } } } } }`)
+}
+
+func TestHandler(t *testing.T) {
+	db := setupDB(t)
+	mux := routes.GraphQLRouter(handlers.NewApp(config.Default(), slog.Default(), db, nil))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req = req.WithContext(asUser(1))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"query": "query Post($id: Int!) { post(id: $id) { caption } }", "variables": {"id": 2}, "operationName": "Post"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"data": {"post": {"caption": "second"}}}`, rr.Body.String())
+
+	// Queries that cannot run are bad requests
+	rr = post(`{"query": "{ post(id: 1) { nope } }"}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	var body struct {
+		Data   interface{}      `json:"data"`
+		Errors []map[string]any `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Nil(t, body.Data)
+	assert.Len(t, body.Errors, 1)
+
+	rr = post(`{"query": "{"`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Bodies without a query fail validation
+	rr = post(`{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
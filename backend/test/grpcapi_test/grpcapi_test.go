@@ -0,0 +1,328 @@
+package grpcapi_test
+
+import (
+	"context"
+	"database/sql"
+	"instagram/internal/config"
+	"instagram/internal/grpcapi"
+	pb "instagram/internal/grpcapi/instagramv1"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"instagram/internal/utils"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "a_secret_that_is_only_used_in_tests"
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+	return cfg
+}
+
+// setupDB creates an in-memory database with three users, 1 follows 2
+func setupDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	// Only one connection may be used, every new connection would get its own empty in-memory database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO users (id, username, email, password_hash) VALUES
+            (1, 'reader', 'reader@example.com', 'x'),
+            (2, 'alice', 'alice@example.com', 'x'),
+            (3, 'bob', 'bob@example.com', 'x');
+        INSERT INTO follows (follower_id, following_id) VALUES (1, 2);
+        INSERT INTO posts (id, user_id, image_url, caption) VALUES (1, 2, 'https://example.com/1.jpg', 'first');
+    `)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+	return db
+}
+
+// serve starts a server on an in-memory listener and returns a connection to it, and a function that
+// shuts the server down and returns the error it stopped with
+func serve(t *testing.T, db *sql.DB, cfg *config.Config) (*grpc.ClientConn, func() error) {
+	listener := bufconn.Listen(1 << 20)
+	server := grpcapi.New(cfg, db, services.New(repositories.NewSQLiteStores(db), cfg.Auth.BcryptCost))
+	server.WatchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, listener) }()
+	stop := sync.OnceValue(func() error {
+		cancel()
+		return <-done
+	})
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		_ = stop()
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+		if err := stop(); err != nil {
+			t.Errorf("failed to serve: %v", err)
+		}
+	})
+	return conn, stop
+}
+
+// sessionToken logs the user in and returns a context that sends their token
+func sessionToken(t *testing.T, db *sql.DB, cfg *config.Config, userID int) context.Context {
+	session, err := repositories.CreateSession(context.Background(), db, &models.Session{UserID: userID, ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	token, _, err := utils.GenerateJWT([]byte(cfg.Auth.JWTSecret), userID, session.ID)
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+// accessToken creates a personal access token with the scopes and returns a context that sends it
+func accessToken(t *testing.T, db *sql.DB, userID int, scopes ...string) context.Context {
+	token, err := utils.GeneratePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("failed to generate access token: %v", err)
+	}
+	_, err = repositories.CreatePersonalAccessToken(context.Background(), db, &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      "test",
+		Scopes:    scopes,
+		TokenHash: utils.HashPersonalAccessToken(token),
+	})
+	if err != nil {
+		t.Fatalf("failed to create access token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestUsersAndPosts(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, _ := serve(t, db, cfg)
+	users := pb.NewUserServiceClient(conn)
+	posts := pb.NewPostServiceClient(conn)
+	ctx := sessionToken(t, db, cfg, 1)
+
+	// Emails are only shown to the user themselves
+	user, err := users.GetUser(ctx, &pb.GetUserRequest{Id: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "reader", user.GetUsername())
+	assert.Equal(t, "reader@example.com", user.GetEmail())
+
+	user, err = users.GetUser(ctx, &pb.GetUserRequest{Id: 2})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "alice", user.GetUsername())
+	assert.Empty(t, user.GetEmail())
+
+	_, err = users.GetUser(ctx, &pb.GetUserRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = posts.CreatePost(ctx, &pb.CreatePostRequest{UserId: 1, ImageUrl: "https://example.com/2.jpg", Caption: "second"})
+	assert.NoError(t, err)
+
+	list, err := posts.ListUserPosts(ctx, &pb.ListUserPostsRequest{UserId: 1})
+	if !assert.NoError(t, err) || !assert.Len(t, list.GetPosts(), 1) {
+		return
+	}
+	assert.Equal(t, "second", list.GetPosts()[0].GetCaption())
+
+	// Only the owner deletes a post
+	_, err = posts.DeletePost(ctx, &pb.DeletePostRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// The request ID sent by the client is kept
+	var header metadata.MD
+	deleteCtx := metadata.AppendToOutgoingContext(ctx, "x-request-id", "deleted-post")
+	_, err = posts.DeletePost(deleteCtx, &pb.DeletePostRequest{Id: list.GetPosts()[0].GetId()}, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deleted-post"}, header.Get("x-request-id"))
+
+	_, err = posts.GetPost(ctx, &pb.GetPostRequest{Id: list.GetPosts()[0].GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	events, err := repositories.GetAuditEvents(context.Background(), db, models.AuditFilter{Action: models.AuditPostDeleted, Limit: 10})
+	if assert.NoError(t, err) && assert.Len(t, events, 1) {
+		assert.Equal(t, 1, *events[0].ActorID)
+		assert.Equal(t, "deleted-post", events[0].RequestID)
+	}
+}
+
+func TestInvalidFieldsAreDetailed(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, _ := serve(t, db, cfg)
+	ctx := sessionToken(t, db, cfg, 1)
+
+	_, err := pb.NewPostServiceClient(conn).CreatePost(ctx, &pb.CreatePostRequest{UserId: 1, ImageUrl: "not a url"})
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if !assert.Len(t, st.Details(), 1) {
+		return
+	}
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	if assert.True(t, ok) && assert.Len(t, badRequest.GetFieldViolations(), 1) {
+		assert.Equal(t, "image_url", badRequest.GetFieldViolations()[0].GetField())
+	}
+
+	_, err = pb.NewFollowServiceClient(conn).Follow(ctx, &pb.FollowRequest{FollowerId: 1, FollowingId: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestAuthentication(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, _ := serve(t, db, cfg)
+	users := pb.NewUserServiceClient(conn)
+
+	_, err := users.GetUser(context.Background(), &pb.GetUserRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer nope")
+	_, err = users.GetUser(ctx, &pb.GetUserRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Access tokens are limited to their scopes and cannot deactivate accounts
+	ctx = accessToken(t, db, 1, models.ScopeUsersRead)
+	_, err = users.GetUser(ctx, &pb.GetUserRequest{Id: 1})
+	assert.NoError(t, err)
+
+	_, err = users.UpdateUser(ctx, &pb.UpdateUserRequest{Id: 1, Username: "renamed"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = users.DeactivateUser(ctx, &pb.DeactivateUserRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Deactivating the account revokes its sessions
+	ctx = sessionToken(t, db, cfg, 1)
+	_, err = users.DeactivateUser(ctx, &pb.DeactivateUserRequest{Id: 1})
+	assert.NoError(t, err)
+
+	_, err = users.GetUser(ctx, &pb.GetUserRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestWatchFeed(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, _ := serve(t, db, cfg)
+
+	ctx, cancel := context.WithTimeout(sessionToken(t, db, cfg, 1), 5*time.Second)
+	defer cancel()
+	stream, err := pb.NewFeedServiceClient(conn).WatchFeed(ctx, &pb.WatchFeedRequest{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// The headers are sent once the stream is watching
+	_, err = stream.Header()
+	assert.NoError(t, err)
+
+	// Only posts published after the call started are sent, and only from followed users
+	_, err = db.Exec(`INSERT INTO posts (user_id, image_url, caption) VALUES (3, 'https://example.com/3.jpg', 'unfollowed')`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO posts (user_id, image_url, caption) VALUES (2, 'https://example.com/4.jpg', 'new')`)
+	assert.NoError(t, err)
+
+	post, err := stream.Recv()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "new", post.GetPost().GetCaption())
+	assert.Equal(t, "alice", post.GetAuthor().GetUsername())
+
+	// Posts up to after_post_id are skipped
+	catchUp, err := pb.NewFeedServiceClient(conn).WatchFeed(ctx, &pb.WatchFeedRequest{AfterPostId: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	post, err = catchUp.Recv()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "new", post.GetPost().GetCaption())
+	}
+}
+
+// Feeds tell whom a user follows, so other users can neither read nor watch them
+func TestFeedsOfOtherUsers(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, _ := serve(t, db, cfg)
+	feed := pb.NewFeedServiceClient(conn)
+
+	ctx := accessToken(t, db, 3, models.ScopePostsRead)
+	_, err := feed.GetFeed(ctx, &pb.GetFeedRequest{UserId: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := feed.WatchFeed(ctx, &pb.WatchFeedRequest{UserId: 1})
+	if assert.NoError(t, err) {
+		_, err = stream.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	}
+
+	resp, err := feed.GetFeed(sessionToken(t, db, cfg, 1), &pb.GetFeedRequest{UserId: 1})
+	if assert.NoError(t, err) {
+		assert.Len(t, resp.GetPosts(), 1)
+	}
+}
+
+func TestShutdownEndsStreams(t *testing.T) {
+	db := setupDB(t)
+	cfg := testConfig()
+	conn, stop := serve(t, db, cfg)
+
+	stream, err := pb.NewFeedServiceClient(conn).WatchFeed(sessionToken(t, db, cfg, 1), &pb.WatchFeedRequest{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = stream.Header()
+	assert.NoError(t, err)
+
+	// The stream ends right away instead of holding the shutdown up until the timeout
+	start := time.Now()
+	assert.NoError(t, stop())
+	assert.Less(t, time.Since(start), cfg.Server.ShutdownTimeout/2)
+
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminModeration(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	adminID := insertUserWithPassword(t, db, "admin", "admin@gmail.com", "password")
+	moderatorID := insertUserWithPassword(t, db, "moderator", "moderator@gmail.com", "password")
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	_, err := db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, 'https://example.com/a.jpg', 'hello')", userID)
+	assert.NoError(t, err)
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/post/", protected(app, routes.PostRouter(app)))
+	server.Handle("/admin/", protected(app, routes.AdminRouter(app)))
+
+	request := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(email string) string {
+		rr := request(http.MethodPost, "/auth/login", "", map[string]string{"email": email, "password": "password"})
+		var response map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		token, _ := response["token"].(string)
+		return token
+	}
+
+	adminToken := login("admin@gmail.com")
+	moderatorToken := login("moderator@gmail.com")
+	userToken := login("tester@gmail.com")
+
+	// Regular users cannot use the admin API
+	rr := request(http.MethodGet, "/admin/actions", userToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// Only admins can change roles
+	rr = request(http.MethodPut, fmt.Sprintf("/admin/users/%d/role", moderatorID), moderatorToken, map[string]string{"role": "moderator", "reason": "promotion"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = request(http.MethodPut, fmt.Sprintf("/admin/users/%d/role", moderatorID), adminToken, map[string]string{"role": "moderator", "reason": "promotion"})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Users cannot delete other users' posts, moderators remove them through the admin API
+	rr = request(http.MethodDelete, "/post/1", moderatorToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = request(http.MethodDelete, "/admin/posts/1", moderatorToken, map[string]string{})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = request(http.MethodDelete, "/admin/posts/1", moderatorToken, map[string]string{"reason": "spam"})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Moderators cannot act on admins
+	rr = request(http.MethodPost, fmt.Sprintf("/admin/users/%d/suspend", adminID), moderatorToken, map[string]string{"reason": "coup"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = request(http.MethodPost, fmt.Sprintf("/admin/users/%d/suspend", userID), moderatorToken, map[string]interface{}{"reason": "harassment", "duration_hours": 24})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Suspended users lose access immediately and cannot log in again
+	rr = request(http.MethodGet, fmt.Sprintf("/post/user/%d", userID), userToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = request(http.MethodPost, "/auth/login", "", map[string]string{"email": "tester@gmail.com", "password": "password"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = request(http.MethodPost, fmt.Sprintf("/admin/users/%d/unsuspend", userID), moderatorToken, map[string]string{"reason": "appeal accepted"})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = request(http.MethodGet, fmt.Sprintf("/post/user/%d", userID), userToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Only admins can restore a post a moderator removed, not its author
+	rr = request(http.MethodPost, "/post/1/restore", userToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = request(http.MethodPost, "/post/1/restore", adminToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Every action is recorded, newest first
+	rr = request(http.MethodGet, "/admin/actions?limit=10", moderatorToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var actions []map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &actions)
+	if assert.Len(t, actions, 4) {
+		assert.Equal(t, "unsuspend_user", actions[0]["action"])
+		assert.Equal(t, "change_role", actions[3]["action"])
+		assert.Equal(t, float64(adminID), actions[3]["moderator_id"])
+	}
+}
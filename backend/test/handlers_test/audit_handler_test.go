@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/middleware"
+	"instagram/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLog(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	adminID := insertUserWithPassword(t, db, "admin", "admin@gmail.com", "password")
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	_, err := db.Exec("UPDATE users SET role = 'admin' WHERE id = ?", adminID)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, 'https://example.com/a.jpg', 'hello')", userID)
+	assert.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", routes.AuthRouter(app))
+	mux.Handle("/users/", protected(app, routes.UserRouter(app)))
+	mux.Handle("/post/", protected(app, routes.PostRouter(app)))
+	mux.Handle("/admin/", protected(app, routes.AdminRouter(app)))
+	server := middleware.RequestIDMiddleware(mux)
+
+	request := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(email, password string) string {
+		rr := request(http.MethodPost, "/auth/login", "", map[string]string{"email": email, "password": password})
+		var response map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		token, _ := response["token"].(string)
+		return token
+	}
+	audit := func(query string) []map[string]interface{} {
+		rr := request(http.MethodGet, "/admin/audit?"+query, login("admin@gmail.com", "password"), nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var events []map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &events)
+		return events
+	}
+
+	login("tester@gmail.com", "wrong")
+	token := login("tester@gmail.com", "password")
+
+	rr := request(http.MethodPost, "/auth/password", token, map[string]string{"current_password": "wrong", "new_password": "secret"})
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	rr = request(http.MethodPost, "/auth/password", token, map[string]string{"current_password": "password", "new_password": "secret"})
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.NotEmpty(t, login("tester@gmail.com", "secret"))
+
+	rr = request(http.MethodPatch, "/users/", token, map[string]interface{}{"id": userID, "username": "tester", "email": "new@gmail.com"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = request(http.MethodDelete, "/post/1", token, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	deleteRequestID := rr.Header().Get(middleware.RequestIDHeader)
+
+	// Only admins can read the audit log
+	rr = request(http.MethodGet, "/admin/audit", token, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	events := audit(fmt.Sprintf("target_type=user&target_id=%d", userID))
+	var actions []string
+	for _, event := range events {
+		actions = append(actions, event["action"].(string))
+	}
+	assert.Equal(t, []string{"user.email_changed", "auth.login", "user.password_changed", "auth.login", "auth.login_failed"}, actions)
+	assert.Equal(t, "email changed", events[0]["details"])
+	assert.Nil(t, events[4]["actor_id"])
+	assert.Equal(t, "192.0.2.1", events[4]["ip_address"])
+
+	events = audit("action=post.deleted")
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, float64(userID), events[0]["actor_id"])
+		assert.Equal(t, float64(1), events[0]["target_id"])
+		assert.Equal(t, deleteRequestID, events[0]["request_id"])
+	}
+
+	assert.Len(t, audit("request_id="+deleteRequestID), 1)
+	for _, event := range audit(fmt.Sprintf("actor_id=%d&action=auth.", adminID)) {
+		assert.Equal(t, "auth.login", event["action"])
+		assert.Equal(t, float64(adminID), event["actor_id"])
+	}
+	assert.Len(t, audit("since=2999-01-01T00:00:00Z"), 0)
+
+	rr = request(http.MethodGet, "/admin/audit?since=yesterday", login("admin@gmail.com", "password"), nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// The audit log cannot be changed
+	_, err = db.Exec("UPDATE audit_log SET actor_id = NULL")
+	assert.Error(t, err)
+	_, err = db.Exec("DELETE FROM audit_log")
+	assert.Error(t, err)
+}
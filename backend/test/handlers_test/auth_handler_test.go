@@ -0,0 +1,30 @@
+package handlers_test
+
+import (
+	"instagram/internal/problem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSignupDuplicateIsConflict(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO users (username, email, password_hash) VALUES (?, ?, ?)", "taken", "taken@example.com", "hash")
+	assert.NoError(t, err)
+
+	body := `{"username": "taken", "email": "other@example.com", "password": "password"}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/signup", strings.NewReader(body))
+	handler := http.HandlerFunc(testApp(db).HandleSignup)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+	assert.NotContains(t, rr.Body.String(), "UNIQUE constraint")
+}
@@ -0,0 +1,120 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/jobs"
+	"instagram/internal/routes"
+	"instagram/internal/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataExport(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	media, err := storage.NewLocal(t.TempDir(), "/media")
+	assert.NoError(t, err)
+	exporter, err := jobs.NewExporter(db, media, t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	otherID := insertUserWithPassword(t, db, "other", "other@gmail.com", "password")
+	imageURL, err := media.Save("photo.jpg", strings.NewReader("jpeg bytes"))
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, ?, 'hello')", userID, imageURL)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO follows (follower_id, following_id) VALUES (?, ?)", otherID, userID)
+	assert.NoError(t, err)
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/export/", routes.DataExportRouter(app))
+
+	request := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(email string) string {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": "password"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		var response map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		token, _ := response["token"].(string)
+		return token
+	}
+	status := func(path, token string) map[string]interface{} {
+		rr := request(http.MethodGet, path, token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var export map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &export)
+		return export
+	}
+
+	token := login("tester@gmail.com")
+
+	rr := request(http.MethodPost, "/export/", token)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	location := rr.Header().Get("Location")
+	assert.Equal(t, http.StatusConflict, request(http.MethodPost, "/export/", token).Code)
+
+	assert.Equal(t, "pending", status(location, token)["status"])
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, location, login("other@gmail.com")).Code)
+
+	assert.NoError(t, exporter.RunOnce(context.Background()))
+
+	export := status(location, token)
+	assert.Equal(t, "completed", export["status"])
+	downloadURL := export["download_url"].(string)
+
+	// The link only works with its token
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, location+"/download", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, location+"/download?token="+token, "").Code)
+
+	rr = request(http.MethodGet, downloadURL, "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	assert.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		assert.NoError(t, err)
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+
+	assert.Contains(t, files["profile.json"], `"username": "tester"`)
+	assert.NotContains(t, files["profile.json"], "password")
+	mediaPath := "media/" + strings.TrimPrefix(imageURL, "/media/")
+	assert.Contains(t, files["posts.json"], mediaPath)
+	assert.Equal(t, "jpeg bytes", files[mediaPath])
+	assert.Contains(t, files["followers.json"], fmt.Sprintf(`"follower_id": %d`, otherID))
+	assert.Equal(t, "[]\n", files["comments.json"])
+
+	// Archives are deleted after the retention period
+	exporter.SetClock(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	assert.NoError(t, exporter.RunOnce(context.Background()))
+	assert.Equal(t, "expired", status(location, token)["status"])
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, downloadURL, "").Code)
+}
@@ -0,0 +1,100 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoftDeleteAndDeactivation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	authorID := insertUserWithPassword(t, db, "author", "author@gmail.com", "password")
+	readerID := insertUserWithPassword(t, db, "reader", "reader@gmail.com", "password")
+	_, err := db.Exec("INSERT INTO follows (follower_id, following_id) VALUES (?, ?)", readerID, authorID)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, 'https://example.com/a.jpg', 'hello')", authorID)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO comments (user_id, post_id, content) VALUES (?, 1, 'nice')", readerID)
+	assert.NoError(t, err)
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/users/", protected(app, routes.UserRouter(app)))
+	server.Handle("/post/", protected(app, routes.PostRouter(app)))
+	server.Handle("/comment/", protected(app, routes.CommentRouter(app)))
+
+	request := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(path, email string) *httptest.ResponseRecorder {
+		return request(http.MethodPost, path, "", map[string]string{"email": email, "password": "password"})
+	}
+	tokenFrom := func(rr *httptest.ResponseRecorder) string {
+		var response map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		token, _ := response["token"].(string)
+		return token
+	}
+	countItems := func(token, path string) int {
+		rr := request(http.MethodGet, path, token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var items []interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &items)
+		return len(items)
+	}
+
+	authorToken := tokenFrom(login("/auth/login", "author@gmail.com"))
+	readerToken := tokenFrom(login("/auth/login", "reader@gmail.com"))
+	feed := fmt.Sprintf("/post/feed/%d", readerID)
+
+	// Deleted posts disappear with their comments and can be restored by their owner only
+	rr := request(http.MethodDelete, "/post/1", authorToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 0, countItems(readerToken, feed))
+	assert.Equal(t, 0, countItems(readerToken, "/comment/post/1"))
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, "/post/1", readerToken, nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/post/1/restore", readerToken, nil).Code)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, "/post/1/restore", authorToken, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodPost, "/post/1/restore", authorToken, nil).Code)
+	assert.Equal(t, 1, countItems(readerToken, feed))
+	assert.Equal(t, 1, countItems(readerToken, "/comment/post/1"))
+
+	// Deactivation hides the profile and its posts and logs the user out, logging in reactivates it
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, fmt.Sprintf("/users/%d/deactivate", authorID), readerToken, nil).Code)
+	assert.Equal(t, http.StatusNoContent, request(http.MethodPost, fmt.Sprintf("/users/%d/deactivate", authorID), authorToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, feed, authorToken, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(http.MethodGet, fmt.Sprintf("/users/%d", authorID), readerToken, nil).Code)
+	assert.Equal(t, 0, countItems(readerToken, feed))
+
+	authorToken = tokenFrom(login("/auth/login", "author@gmail.com"))
+	assert.NotEmpty(t, authorToken)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, fmt.Sprintf("/users/%d", authorID), readerToken, nil).Code)
+	assert.Equal(t, 1, countItems(readerToken, feed))
+
+	// Deleted accounts cannot log in but can be restored during the grace period
+	assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, fmt.Sprintf("/users/%d", authorID), authorToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, feed, authorToken, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, login("/auth/login", "author@gmail.com").Code)
+	assert.Equal(t, 0, countItems(readerToken, feed))
+
+	assert.Equal(t, http.StatusUnauthorized, login("/auth/restore", "reader@gmail.com").Code)
+	rr = login("/auth/restore", "author@gmail.com")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, tokenFrom(rr))
+	assert.Equal(t, 1, countItems(readerToken, feed))
+}
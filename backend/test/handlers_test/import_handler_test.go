@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"instagram/internal/models"
+	"instagram/internal/routes"
+	"instagram/internal/storage"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// jpeg is the start of a JPEG file, enough for its content type to be detected
+const jpeg = "\xff\xd8\xff\xe0\x00\x10JFIF\x00"
+
+func instagramArchive(t *testing.T) []byte {
+	files := map[string]string{
+		"your_instagram_activity/content/posts_1.json": `[
+			{"media": [{"uri": "media/posts/202401/first.jpg", "creation_timestamp": 1704067200, "title": "CafÃ© morning"}]},
+			{"title": "Two photos", "creation_timestamp": 1706745600, "media": [
+				{"uri": "media/posts/202402/second.jpg", "creation_timestamp": 1706745600, "title": ""},
+				{"uri": "media/posts/202402/third.jpg", "creation_timestamp": 1706745600, "title": ""}
+			]},
+			{"media": [{"uri": "media/posts/202403/clip.mp4", "creation_timestamp": 1709251200, "title": "A video"}]},
+			{"media": [{"uri": "media/posts/202404/page.jpg", "creation_timestamp": 1711929600, "title": "Not a photo"}]},
+			{"media": [{"uri": "media/posts/202405/huge.jpg", "creation_timestamp": 1714521600, "title": "Too large"}]},
+			{"media": [{"uri": "media/posts/202406/long.jpg", "creation_timestamp": 1717200000, "title": "` + strings.Repeat("a", 2201) + `"}]}
+		]`,
+		"connections/followers_and_following/following.json": `{"relationships_following": [
+			{"title": "", "string_list_data": [{"href": "https://www.instagram.com/friend", "value": "friend", "timestamp": 1700000000}]},
+			{"title": "stranger", "string_list_data": [{"href": "https://www.instagram.com/_u/stranger", "timestamp": 1700000000}]}
+		]}`,
+		"media/posts/202401/first.jpg":  jpeg + "first",
+		"media/posts/202402/second.jpg": jpeg + "second",
+		"media/posts/202402/third.jpg":  jpeg + "third",
+		"media/posts/202403/clip.mp4":   "video",
+		"media/posts/202404/page.jpg":   "<html><script>alert(1)</script></html>",
+		"media/posts/202406/long.jpg":   jpeg + "long",
+		// Compresses to a few kilobytes
+		"media/posts/202405/huge.jpg": jpeg + strings.Repeat("\x00", 33<<20),
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range files {
+		file, err := writer.CreateHeader(&zip.FileHeader{Name: "instagram-tester-2024/" + name, Method: zip.Deflate})
+		assert.NoError(t, err)
+		_, err = file.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestHandleImportInstagram(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	media, err := storage.NewLocal(t.TempDir(), "/media")
+	assert.NoError(t, err)
+	app := testApp(db)
+	app.Media = media
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	friendID := insertUserWithPassword(t, db, "friend", "friend@gmail.com", "password")
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/import/", protected(app, routes.ImportRouter(app)))
+
+	body, _ := json.Marshal(map[string]string{"email": "tester@gmail.com", "password": "password"})
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body)))
+	var login map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &login)
+	token, _ := login["token"].(string)
+
+	upload := func(archive []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("archive", "instagram.zip")
+		assert.NoError(t, err)
+		_, err = part.Write(archive)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+
+		req := httptest.NewRequest(http.MethodPost, "/import/instagram", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	summary := func(rr *httptest.ResponseRecorder) models.ImportSummary {
+		assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var summary models.ImportSummary
+		_ = json.Unmarshal(rr.Body.Bytes(), &summary)
+		return summary
+	}
+
+	archive := instagramArchive(t)
+
+	first := summary(upload(archive))
+	assert.Equal(t, 3, first.Imported)
+	assert.Equal(t, 5, first.Skipped)
+	assert.Equal(t, 0, first.Failed)
+	assert.Len(t, first.Items, 8)
+
+	// Files that aren't images or are too large are skipped before anything is stored
+	reasons := map[string]string{}
+	for _, item := range first.Items {
+		reasons[item.Source] = item.Reason
+	}
+	assert.Equal(t, "media file is not an image", reasons["media/posts/202404/page.jpg"])
+	assert.Equal(t, "media files larger than 32 MB can't be imported", reasons["media/posts/202405/huge.jpg"])
+	// Imported posts are validated like any other post
+	assert.Contains(t, reasons["media/posts/202406/long.jpg"], "caption")
+
+	// Posts keep their caption and original time, only photos are imported
+	rows, err := db.Query("SELECT image_url, caption, created_at FROM posts WHERE user_id = ? ORDER BY created_at", userID)
+	assert.NoError(t, err)
+	var captions []string
+	var times []time.Time
+	for rows.Next() {
+		var imageURL, caption string
+		var createdAt time.Time
+		assert.NoError(t, rows.Scan(&imageURL, &caption, &createdAt))
+		assert.True(t, media.Owns(imageURL))
+		captions = append(captions, caption)
+		times = append(times, createdAt)
+	}
+	rows.Close()
+	assert.Equal(t, []string{"Café morning", "Two photos"}, captions)
+	assert.True(t, time.Unix(1704067200, 0).Equal(times[0]))
+
+	var follows int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM follows WHERE follower_id = ? AND following_id = ?", userID, friendID).Scan(&follows))
+	assert.Equal(t, 1, follows)
+
+	// Importing the same archive again creates nothing new
+	second := summary(upload(archive))
+	assert.Equal(t, 0, second.Imported)
+	assert.Equal(t, 3, second.Duplicate)
+
+	var posts int
+	assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM posts WHERE user_id = ?", userID).Scan(&posts))
+	assert.Equal(t, 2, posts)
+
+	assert.Equal(t, http.StatusBadRequest, upload([]byte("not a zip")).Code)
+}
@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	wrong := map[string]string{"email": "tester@gmail.com", "password": "wrong"}
+	right := map[string]string{"email": "tester@gmail.com", "password": "password"}
+
+	for i := 0; i < utils.LoginLockoutThreshold; i++ {
+		rr, _ := serveJSON(t, app.HandleLogin, 0, wrong)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	// Even the correct password is refused while the account is locked
+	rr, _ := serveJSON(t, app.HandleLogin, 0, right)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	// Lift the lock, a successful login then resets the counter
+	_, err := db.Exec("UPDATE login_lockouts SET locked_until = NULL")
+	assert.NoError(t, err)
+
+	rr, _ = serveJSON(t, app.HandleLogin, 0, right)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM login_lockouts").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// Wrong passwords guessed in parallel are all counted
+func TestParallelFailedLoginsAreAllCounted(t *testing.T) {
+	// A database file, so that the logins run on connections of their own
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repositories.RecordFailedLogin(context.Background(), db, userID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	lockout, err := repositories.GetLoginLockout(context.Background(), db, userID)
+	if assert.NoError(t, err) && assert.NotNil(t, lockout) {
+		assert.Equal(t, 20, lockout.FailedAttempts)
+		assert.NotNil(t, lockout.LockedUntil)
+	}
+}
+
+func TestLoginLockoutDurationGrowsExponentially(t *testing.T) {
+	assert.Zero(t, utils.LoginLockoutDuration(utils.LoginLockoutThreshold-1))
+	first := utils.LoginLockoutDuration(utils.LoginLockoutThreshold)
+	assert.Equal(t, 2*first, utils.LoginLockoutDuration(utils.LoginLockoutThreshold+1))
+	assert.Equal(t, 4*first, utils.LoginLockoutDuration(utils.LoginLockoutThreshold+2))
+	assert.Equal(t, utils.LoginLockoutDuration(100), utils.LoginLockoutDuration(1000))
+}
+
+// Failed logins are audited without the address that was tried, it may belong to somebody who never signed up
+func TestFailedLoginWithUnknownEmailIsAuditedWithoutTheAddress(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	rr, _ := serveJSON(t, app.HandleLogin, 0, map[string]string{"email": "stranger@gmail.com", "password": "password"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	var details string
+	err := db.QueryRow("SELECT details FROM audit_log WHERE action = ?", models.AuditLoginFailed).Scan(&details)
+	assert.NoError(t, err)
+	assert.Equal(t, "unknown email", details)
+}
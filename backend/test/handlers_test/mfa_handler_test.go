@@ -0,0 +1,165 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// insertUserWithPassword stores a user with a cheap bcrypt hash so login tests stay fast
+func insertUserWithPassword(t *testing.T, db *sql.DB, username, email, password string) int {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	result, err := db.Exec("INSERT INTO users (username, email, password_hash) VALUES (?, ?, ?)", username, email, string(hash))
+	if err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return int(id)
+}
+
+// serveJSON runs a handler, optionally with an authenticated user in the request context
+func serveJSON(t *testing.T, handler http.HandlerFunc, userID int, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+	payload, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(payload))
+	if userID != 0 {
+		req = req.WithContext(middleware.WithUser(req.Context(), userID, models.RoleUser))
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr, response
+}
+
+func TestTOTPTwoStepLogin(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	credentials := map[string]string{"email": "tester@gmail.com", "password": "password"}
+
+	// Enroll and confirm an authenticator
+	rr, enrollment := serveJSON(t, app.HandleTOTPEnroll, userID, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	secret := enrollment["secret"].(string)
+	assert.Contains(t, enrollment["provisioning_uri"], "otpauth://totp/")
+
+	rr, _ = serveJSON(t, app.HandleTOTPConfirm, userID, map[string]string{"code": "000000"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	code, _ := utils.GenerateTOTPCode(secret, time.Now())
+	rr, confirmation := serveJSON(t, app.HandleTOTPConfirm, userID, map[string]string{"code": code})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	recoveryCodes := confirmation["recovery_codes"].([]interface{})
+	assert.Len(t, recoveryCodes, 10)
+
+	// The password step now only yields a challenge token
+	rr, login := serveJSON(t, app.HandleLogin, 0, credentials)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, login["mfa_required"])
+	assert.Nil(t, login["token"])
+	mfaToken := login["mfa_token"].(string)
+
+	// The challenge token must not be usable as a session token
+	_, err := utils.VerifyMFAChallengeJWT([]byte(testConfig().Auth.JWTSecret), mfaToken)
+	assert.NoError(t, err)
+	handler := protected(app, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+mfaToken)
+	protectedRR := httptest.NewRecorder()
+	handler.ServeHTTP(protectedRR, req)
+	assert.Equal(t, http.StatusUnauthorized, protectedRR.Code)
+
+	// Exchange the challenge with a code for a real token
+	rr, _ = serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": mfaToken, "code": "000000"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Codes work once, the code that confirmed the authenticator is rejected
+	rr, _ = serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": mfaToken, "code": code})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	code, _ = utils.GenerateTOTPCode(secret, time.Now().Add(utils.TOTPPeriod))
+	rr, verified := serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": mfaToken, "code": code})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, verified["token"])
+
+	// So do challenge tokens
+	_, login = serveJSON(t, app.HandleLogin, 0, credentials)
+	recoveryCode := recoveryCodes[0].(string)
+	rr, _ = serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": mfaToken, "recovery_code": recoveryCode})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// Recovery codes work exactly once
+	rr, _ = serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": login["mfa_token"].(string), "recovery_code": recoveryCode})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	_, login = serveJSON(t, app.HandleLogin, 0, credentials)
+	rr, _ = serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": login["mfa_token"].(string), "recovery_code": recoveryCode})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+// A password alone must not undo the deactivation or deletion of an account with two-factor authentication
+func TestMFAGuardsReactivationAndRestore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	secret, err := utils.GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.NoError(t, repositories.SaveTOTPSecret(context.Background(), db, userID, secret))
+	assert.NoError(t, repositories.ConfirmTOTP(context.Background(), db, userID, nil))
+	credentials := map[string]string{"email": "tester@gmail.com", "password": "password"}
+
+	isSet := func(column string) bool {
+		var set bool
+		err := db.QueryRow("SELECT "+column+" IS NOT NULL FROM users WHERE id = ?", userID).Scan(&set)
+		assert.NoError(t, err)
+		return set
+	}
+	// Every code works once, so each login uses the code of another time step
+	verify := func(mfaToken string, step int) int {
+		code, _ := utils.GenerateTOTPCode(secret, time.Now().Add(time.Duration(step)*utils.TOTPPeriod))
+		rr, _ := serveJSON(t, app.HandleMFAVerify, 0, map[string]string{"mfa_token": mfaToken, "code": code})
+		return rr.Code
+	}
+
+	_, err = db.Exec("UPDATE users SET deactivated_at = CURRENT_TIMESTAMP WHERE id = ?", userID)
+	assert.NoError(t, err)
+	rr, login := serveJSON(t, app.HandleLogin, 0, credentials)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, login["mfa_required"])
+	assert.True(t, isSet("deactivated_at"), "the password step leaves the account deactivated")
+	assert.Equal(t, http.StatusOK, verify(login["mfa_token"].(string), 0))
+	assert.False(t, isSet("deactivated_at"))
+
+	_, err = db.Exec("UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", userID)
+	assert.NoError(t, err)
+	rr, restore := serveJSON(t, app.HandleRestoreAccount, 0, credentials)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, restore["mfa_required"])
+	assert.True(t, isSet("deleted_at"), "the password step leaves the account deleted")
+	assert.Equal(t, http.StatusOK, verify(restore["mfa_token"].(string), 1))
+	assert.False(t, isSet("deleted_at"))
+}
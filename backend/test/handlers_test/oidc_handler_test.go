@@ -0,0 +1,354 @@
+package handlers_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/config"
+	"instagram/internal/handlers"
+	"instagram/internal/oidc"
+	"instagram/internal/utils"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOIDCUser is the account the stand-in provider logs in
+type fakeOIDCUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+type fakeAuthorization struct {
+	nonce         string
+	codeChallenge string
+	redirectURI   string
+}
+
+// fakeOIDCServer is a minimal stand-in OpenID Connect provider that enforces PKCE
+type fakeOIDCServer struct {
+	*httptest.Server
+	t    *testing.T
+	key  *rsa.PrivateKey
+	user fakeOIDCUser
+
+	mu    sync.Mutex
+	codes map[string]fakeAuthorization
+}
+
+const fakeClientID, fakeClientSecret = "instagram-client", "instagram-secret"
+
+func newFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	fake := &fakeOIDCServer{t: t, key: key, codes: map[string]fakeAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", fake.discovery)
+	mux.HandleFunc("GET /jwks", fake.jwks)
+	mux.HandleFunc("GET /authorize", fake.authorize)
+	mux.HandleFunc("POST /token", fake.token)
+	fake.Server = httptest.NewServer(mux)
+	return fake
+}
+
+// app returns the App of the tests with the fake server configured as the "fake" identity provider
+func (f *fakeOIDCServer) app(db *sql.DB) *handlers.App {
+	cfg := testConfig()
+	cfg.OIDC.Providers = []config.OIDCProviderConfig{{
+		Name:         "fake",
+		Issuer:       f.URL,
+		ClientID:     fakeClientID,
+		ClientSecret: fakeClientSecret,
+		RedirectURL:  "https://instagram.test/auth/oidc/fake/callback",
+	}}
+	return handlers.NewApp(cfg, slog.Default(), db, nil)
+}
+
+func (f *fakeOIDCServer) discovery(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 f.URL,
+		"authorization_endpoint": f.URL + "/authorize",
+		"token_endpoint":         f.URL + "/token",
+		"jwks_uri":               f.URL + "/jwks",
+	})
+}
+
+func (f *fakeOIDCServer) jwks(w http.ResponseWriter, r *http.Request) {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   encode(f.key.N.Bytes()),
+			"e":   encode(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize immediately "logs in" the configured user and redirects back with a code
+func (f *fakeOIDCServer) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	assert.Equal(f.t, "code", query.Get("response_type"))
+	assert.Equal(f.t, fakeClientID, query.Get("client_id"))
+	assert.Equal(f.t, "S256", query.Get("code_challenge_method"))
+
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	f.mu.Lock()
+	f.codes[code] = fakeAuthorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	f.mu.Unlock()
+
+	http.Redirect(w, r, query.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+}
+
+func (f *fakeOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	if clientID != fakeClientID || clientSecret != fakeClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	f.mu.Lock()
+	authorization, ok := f.codes[r.FormValue("code")]
+	delete(f.codes, r.FormValue("code"))
+	f.mu.Unlock()
+
+	if !ok || authorization.redirectURI != r.FormValue("redirect_uri") ||
+		oidc.CodeChallengeS256(r.FormValue("code_verifier")) != authorization.codeChallenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            f.URL,
+		"aud":            fakeClientID,
+		"sub":            f.user.Subject,
+		"email":          f.user.Email,
+		"email_verified": f.user.EmailVerified,
+		"nonce":          authorization.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	})
+	idToken.Header["kid"] = "test-key"
+	signed, err := idToken.SignedString(f.key)
+	if err != nil {
+		f.t.Fatalf("failed to sign id token: %v", err)
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"id_token":     signed,
+		"expires_in":   3600,
+	})
+}
+
+// startOIDCLogin runs our login endpoint and the provider, and returns the callback request the provider
+// redirects the browser to together with the cookies our login endpoint set in the browser
+func startOIDCLogin(t *testing.T, app *handlers.App, provider string) (*http.Request, []*http.Cookie) {
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/"+provider+"/login", nil)
+	req.SetPathValue("provider", provider)
+	rr := httptest.NewRecorder()
+	app.HandleOIDCLogin(rr, req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+
+	noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := noRedirects.Get(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to call authorize endpoint: %v", err)
+	}
+	resp.Body.Close()
+
+	location, _ := url.Parse(resp.Header.Get("Location"))
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/"+provider+"/callback?"+location.RawQuery, nil)
+	callback.SetPathValue("provider", provider)
+	return callback, rr.Result().Cookies()
+}
+
+// finishOIDCLogin runs our callback with the cookies of the browser
+func finishOIDCLogin(app *handlers.App, callback *http.Request, cookies []*http.Cookie) (*httptest.ResponseRecorder, map[string]interface{}) {
+	for _, cookie := range cookies {
+		callback.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	app.HandleOIDCCallback(rr, callback)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr, response
+}
+
+// oidcLogin runs the whole browser flow: our login endpoint, the provider, and our callback
+func oidcLogin(t *testing.T, app *handlers.App, provider string, tamper func()) (*httptest.ResponseRecorder, map[string]interface{}) {
+	callback, cookies := startOIDCLogin(t, app, provider)
+	if tamper != nil {
+		tamper()
+	}
+	return finishOIDCLogin(app, callback, cookies)
+}
+
+// linkOIDCIdentity confirms linking an external identity with the password of the account
+func linkOIDCIdentity(app *handlers.App, linkToken, password string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	body, _ := json.Marshal(map[string]string{"link_token": linkToken, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/auth/oidc/link", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	app.HandleOIDCLink(rr, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &response)
+	return rr, response
+}
+
+func TestOIDCLogin(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fake := newFakeOIDCServer(t)
+	defer fake.Close()
+
+	app := fake.app(db)
+
+	existingID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+
+	// A verified email of an existing user needs the user's password before the identity is linked
+	fake.user = fakeOIDCUser{Subject: "subject-1", Email: "Tester@gmail.com", EmailVerified: true}
+	rr, response := oidcLogin(t, app, "fake", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, true, response["link_required"])
+	assert.Nil(t, response["token"])
+	linkToken, _ := response["link_token"].(string)
+
+	rr, _ = linkOIDCIdentity(app, linkToken, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr, response = linkOIDCIdentity(app, linkToken, "password")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, response["token"])
+	assert.Equal(t, float64(existingID), response["id"])
+
+	// Later logins use the link even if the provider's email changes
+	fake.user.Email = "changed@gmail.com"
+	rr, response = oidcLogin(t, app, "fake", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, float64(existingID), response["id"])
+
+	// Unknown verified emails get a new account
+	fake.user = fakeOIDCUser{Subject: "subject-2", Email: "new.person@gmail.com", EmailVerified: true}
+	rr, response = oidcLogin(t, app, "fake", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, float64(existingID), response["id"])
+
+	var username string
+	err := db.QueryRow("SELECT username FROM users WHERE email = ?", "new.person@gmail.com").Scan(&username)
+	assert.NoError(t, err)
+	assert.Equal(t, "new.person", username)
+
+	// Unverified emails are never linked
+	fake.user = fakeOIDCUser{Subject: "subject-3", Email: "tester@gmail.com", EmailVerified: false}
+	rr, _ = oidcLogin(t, app, "fake", nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// The provider rejects the code if our PKCE verifier doesn't match the challenge
+	fake.user = fakeOIDCUser{Subject: "subject-1", Email: "tester@gmail.com", EmailVerified: true}
+	rr, _ = oidcLogin(t, app, "fake", func() {
+		_, err := db.Exec("UPDATE oidc_login_states SET code_verifier = 'tampered'")
+		assert.NoError(t, err)
+	})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// States can only be used once
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/fake/callback?code=abc&state=unknown", nil)
+	req.SetPathValue("provider", "fake")
+	app.HandleOIDCCallback(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// A login can only be completed in the browser that started it, or an attacker could log a victim into
+// the attacker's account by sending them the callback URL of the attacker's own login
+func TestOIDCLoginIsBoundToTheBrowser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fake := newFakeOIDCServer(t)
+	defer fake.Close()
+
+	app := fake.app(db)
+	fake.user = fakeOIDCUser{Subject: "attacker", Email: "attacker@gmail.com", EmailVerified: true}
+
+	callback, cookies := startOIDCLogin(t, app, "fake")
+	if assert.Len(t, cookies, 1) {
+		assert.True(t, cookies[0].HttpOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	}
+
+	// The victim's browser has no cookie, or the cookie of a login of its own
+	rr, _ := finishOIDCLogin(app, callback.Clone(callback.Context()), nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	_, victimCookies := startOIDCLogin(t, app, "fake")
+	rr, _ = finishOIDCLogin(app, callback.Clone(callback.Context()), victimCookies)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr, response := finishOIDCLogin(app, callback, cookies)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEmpty(t, response["token"])
+}
+
+// Somebody who signs up with another person's email before that person first logs in with an identity provider
+// must not end up sharing the account with them
+func TestOIDCLoginDoesNotLinkAccountsWithoutPassword(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fake := newFakeOIDCServer(t)
+	defer fake.Close()
+
+	app := fake.app(db)
+
+	squatterID := insertUserWithPassword(t, db, "squatter", "victim@gmail.com", "squatter-password")
+	fake.user = fakeOIDCUser{Subject: "victim", Email: "victim@gmail.com", EmailVerified: true}
+
+	rr, response := oidcLogin(t, app, "fake", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Nil(t, response["token"])
+
+	var linked int
+	err := db.QueryRow("SELECT COUNT(*) FROM external_identities WHERE user_id = ?", squatterID).Scan(&linked)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, linked)
+
+	// Link tokens are only accepted with the account's password, and nothing else is one
+	linkToken, _ := response["link_token"].(string)
+	rr, _ = linkOIDCIdentity(app, linkToken, "victim-password")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mfaToken, _, err := utils.GenerateMFAChallengeJWT([]byte(testConfig().Auth.JWTSecret), squatterID, false)
+	assert.NoError(t, err)
+	rr, _ = linkOIDCIdentity(app, mfaToken, "squatter-password")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	err = db.QueryRow("SELECT COUNT(*) FROM external_identities").Scan(&linked)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, linked)
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	_, err := db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, 'https://example.com/a.jpg', 'hello')", userID)
+	assert.NoError(t, err)
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/post/", protected(app, routes.PostRouter(app)))
+
+	request := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(http.MethodPost, "/auth/login", "", map[string]string{"email": "tester@gmail.com", "password": "password"})
+	var login map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &login)
+	sessionToken := login["token"].(string)
+
+	// Unknown scopes are rejected
+	rr = request(http.MethodPost, "/auth/tokens", sessionToken, map[string]interface{}{"name": "bot", "scopes": []string{"everything"}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = request(http.MethodPost, "/auth/tokens", sessionToken, map[string]interface{}{"name": "bot", "scopes": []string{"posts:read"}})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var created map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	accessToken := created["token"].(string)
+	assert.Contains(t, accessToken, "igp_")
+
+	// The token can read posts, but not write them
+	rr = request(http.MethodGet, fmt.Sprintf("/post/user/%d", userID), accessToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = request(http.MethodDelete, "/post/1", accessToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Header().Get("WWW-Authenticate"), "insufficient_scope")
+
+	// Tokens cannot be used to manage tokens
+	rr = request(http.MethodGet, "/auth/tokens", accessToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// The token is listed without its secret and can be revoked
+	rr = request(http.MethodGet, "/auth/tokens", sessionToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), accessToken)
+
+	rr = request(http.MethodDelete, fmt.Sprintf("/auth/tokens/%v", created["id"]), sessionToken, nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = request(http.MethodGet, fmt.Sprintf("/post/user/%d", userID), accessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -0,0 +1,140 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"instagram/internal/handlers"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGetPostByIdNotFound(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	req := httptest.NewRequest(http.MethodGet, "/post/42", nil)
+	req.SetPathValue("id", "42")
+
+	rr := httptest.NewRecorder()
+	app.HandleGetPostById(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+
+	var details problem.Details
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&details))
+	assert.Equal(t, "post with id 42 not found", details.Detail)
+	assert.NotContains(t, details.Detail, "sql")
+}
+
+func TestHandlePostPostValidation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	body := `{"user_id": 1, "image_url": "not a url", "caption": "` + strings.Repeat("a", 2201) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/post/", strings.NewReader(body))
+
+	rr := httptest.NewRecorder()
+	app.HandlePostPost(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	var details problem.Details
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&details))
+	var fields []string
+	for _, fieldErr := range details.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	assert.ElementsMatch(t, []string{"image_url", "caption"}, fields)
+
+	// Unknown fields are rejected before validation
+	req = httptest.NewRequest(http.MethodPost, "/post/", strings.NewReader(`{"user_id": 1, "image_url": "/media/1.jpg", "likes": 5}`))
+	rr = httptest.NewRecorder()
+	app.HandlePostPost(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHandlePostPostReturnsCreatedPost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+	userID := insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+
+	body := `{"user_id": ` + strconv.Itoa(userID) + `, "image_url": "https://example.com/1.jpg", "caption": "hello"}`
+	req := httptest.NewRequest(http.MethodPost, "/post/", strings.NewReader(body))
+	req = req.WithContext(middleware.WithUser(req.Context(), userID, models.RoleUser))
+
+	rr := httptest.NewRecorder()
+	app.HandlePostPost(rr, req)
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "application/json", rr.Result().Header.Get("Content-Type"))
+	var post models.Post
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&post))
+	assert.NotZero(t, post.ID)
+	assert.Equal(t, userID, post.UserID)
+	assert.Equal(t, "hello", post.Caption)
+}
+
+func TestHandlePostUserSetsContentType(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	body := `{"username": "tester", "email": "tester@gmail.com", "password": "password"}`
+	rr := httptest.NewRecorder()
+	app.HandlePostUser(rr, httptest.NewRequest(http.MethodPost, "/users/", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "application/json", rr.Result().Header.Get("Content-Type"))
+}
+
+// fakePosts keeps posts in memory. Methods a test does not stub panic through the nil PostStore.
+type fakePosts struct {
+	repositories.PostStore
+	posts map[int]*models.Post
+}
+
+func (f fakePosts) Get(ctx context.Context, id int) (*models.Post, error) {
+	post, ok := f.posts[id]
+	if !ok {
+		return nil, repositories.NotFound("post with id %d not found", id)
+	}
+	return post, nil
+}
+
+func TestHandlersUseInjectedStores(t *testing.T) {
+	// No database at all, the handler only talks to the store
+	app := handlers.NewApp(testConfig(), slog.Default(), nil, nil)
+	app.Stores.Posts = fakePosts{posts: map[int]*models.Post{
+		7: {ID: 7, UserID: 1, ImageURL: "/media/7.jpg", Caption: "from a fake"},
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/post/7", nil)
+	req.SetPathValue("id", "7")
+	rr := httptest.NewRecorder()
+	app.HandleGetPostById(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var post models.Post
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&post))
+	assert.Equal(t, "from a fake", post.Caption)
+
+	req = httptest.NewRequest(http.MethodGet, "/post/8", nil)
+	req.SetPathValue("id", "8")
+	rr = httptest.NewRecorder()
+	app.HandleGetPostById(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,133 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"instagram/internal/routes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportsAndModerationQueue(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	moderatorID := insertUserWithPassword(t, db, "moderator", "moderator@gmail.com", "password")
+	authorID := insertUserWithPassword(t, db, "author", "author@gmail.com", "password")
+	readerID := insertUserWithPassword(t, db, "reader", "reader@gmail.com", "password")
+	_, err := db.Exec("UPDATE users SET role = 'moderator' WHERE id = ?", moderatorID)
+	assert.NoError(t, err)
+	for _, follower := range []int{moderatorID, readerID} {
+		_, err = db.Exec("INSERT INTO follows (follower_id, following_id) VALUES (?, ?)", follower, authorID)
+		assert.NoError(t, err)
+	}
+	for _, caption := range []string{"spammy", "violent"} {
+		_, err = db.Exec("INSERT INTO posts (user_id, image_url, caption) VALUES (?, 'https://example.com/a.jpg', ?)", authorID, caption)
+		assert.NoError(t, err)
+	}
+	_, err = db.Exec("INSERT INTO comments (user_id, post_id, content) VALUES (?, 1, 'rude')", authorID)
+	assert.NoError(t, err)
+
+	server := http.NewServeMux()
+	server.Handle("/auth/", routes.AuthRouter(app))
+	server.Handle("/post/", protected(app, routes.PostRouter(app)))
+	server.Handle("/comment/", protected(app, routes.CommentRouter(app)))
+	server.Handle("/report/", protected(app, routes.ReportRouter(app)))
+	server.Handle("/admin/", protected(app, routes.AdminRouter(app)))
+
+	request := func(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(payload))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	login := func(email string) string {
+		rr := request(http.MethodPost, "/auth/login", "", map[string]string{"email": email, "password": "password"})
+		var response map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		token, _ := response["token"].(string)
+		return token
+	}
+	report := func(token, targetType string, targetID int, reason string) int {
+		return request(http.MethodPost, "/report/", token, map[string]interface{}{
+			"target_type": targetType, "target_id": targetID, "reason": reason,
+		}).Code
+	}
+
+	moderatorToken := login("moderator@gmail.com")
+	readerToken := login("reader@gmail.com")
+
+	assert.Equal(t, http.StatusBadRequest, report(readerToken, "post", 1, "boring"))
+	assert.Equal(t, http.StatusNotFound, report(readerToken, "post", 99, "spam"))
+
+	// Post 1 gets two spam reports, post 2 a single violence report which outranks them
+	assert.Equal(t, http.StatusCreated, report(readerToken, "post", 1, "spam"))
+	assert.Equal(t, http.StatusConflict, report(readerToken, "post", 1, "harassment"))
+	assert.Equal(t, http.StatusCreated, report(moderatorToken, "post", 1, "spam"))
+	assert.Equal(t, http.StatusCreated, report(readerToken, "post", 2, "violence"))
+	assert.Equal(t, http.StatusCreated, report(readerToken, "comment", 1, "other"))
+
+	rr := request(http.MethodGet, "/report/", readerToken, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	rr = request(http.MethodGet, "/admin/reports", readerToken, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = request(http.MethodGet, "/admin/reports", moderatorToken, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var queue []map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &queue)
+	if assert.Len(t, queue, 3) {
+		assert.Equal(t, float64(2), queue[0]["target_id"])
+		assert.Equal(t, float64(1), queue[1]["target_id"])
+		assert.Equal(t, float64(2), queue[1]["report_count"])
+		assert.Equal(t, "comment", queue[2]["target_type"])
+	}
+
+	resolve := func(targetType string, targetID int, resolution string) int {
+		return request(http.MethodPost, fmt.Sprintf("/admin/reports/%s/%d/resolve", targetType, targetID), moderatorToken,
+			map[string]string{"resolution": resolution, "reason": "reviewed"}).Code
+	}
+
+	// Hidden content disappears from profiles, feeds and comment lists, dismissed content stays
+	assert.Equal(t, http.StatusNoContent, resolve("post", 2, "hidden"))
+	assert.Equal(t, http.StatusNoContent, resolve("post", 1, "dismissed"))
+	assert.Equal(t, http.StatusNoContent, resolve("comment", 1, "hidden"))
+
+	countItems := func(path string) int {
+		rr := request(http.MethodGet, path, readerToken, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var items []interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &items)
+		return len(items)
+	}
+	assert.Equal(t, 1, countItems(fmt.Sprintf("/post/user/%d", authorID)))
+	assert.Equal(t, 1, countItems(fmt.Sprintf("/post/feed/%d", readerID)))
+	assert.Equal(t, 0, countItems("/comment/post/1"))
+
+	rr = request(http.MethodGet, "/admin/reports", moderatorToken, nil)
+	queue = nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &queue)
+	assert.Empty(t, queue)
+
+	// Hiding the author hides everything they posted, reopening puts the post back in the queue
+	assert.Equal(t, http.StatusCreated, report(readerToken, "user", authorID, "harassment"))
+	assert.Equal(t, http.StatusNoContent, resolve("user", authorID, "hidden"))
+	assert.Equal(t, 0, countItems(fmt.Sprintf("/post/feed/%d", readerID)))
+
+	assert.Equal(t, http.StatusNoContent, resolve("user", authorID, "open"))
+	assert.Equal(t, http.StatusNoContent, resolve("post", 2, "open"))
+	assert.Equal(t, 2, countItems(fmt.Sprintf("/post/user/%d", authorID)))
+
+	rr = request(http.MethodGet, "/admin/reports", moderatorToken, nil)
+	queue = nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &queue)
+	assert.Len(t, queue, 2)
+}
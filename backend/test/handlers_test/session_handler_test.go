@@ -0,0 +1,143 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionsCanBeListedAndRevoked(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	credentials := map[string]string{"email": "tester@gmail.com", "password": "password"}
+
+	// Log in from two devices
+	_, phone := serveJSON(t, app.HandleLogin, 0, credentials)
+	_, laptop := serveJSON(t, app.HandleLogin, 0, credentials)
+
+	// authenticated runs a request through JWTMiddleware with the given token
+	authenticated := func(token string, method string, sessionID string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/auth/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("User-Agent", "test-agent")
+		req.SetPathValue("id", sessionID)
+
+		rr := httptest.NewRecorder()
+		protected(app, handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := authenticated(laptop["token"].(string), http.MethodGet, "", app.HandleGetSessions)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var sessions []models.Session
+	err := json.NewDecoder(rr.Body).Decode(&sessions)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	var phoneSessionID int
+	for _, session := range sessions {
+		if !session.Current {
+			phoneSessionID = session.ID
+		}
+	}
+	assert.NotZero(t, phoneSessionID)
+
+	// Revoke the phone from the laptop
+	rr = authenticated(laptop["token"].(string), http.MethodDelete, fmt.Sprint(phoneSessionID), app.HandleDeleteSession)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// The phone's token is no longer accepted, the laptop's still is
+	rr = authenticated(phone["token"].(string), http.MethodGet, "", app.HandleGetSessions)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = authenticated(laptop["token"].(string), http.MethodGet, "", app.HandleGetSessions)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// Expired sessions are neither listed nor accepted
+	_, tablet := serveJSON(t, app.HandleLogin, 0, credentials)
+	_, err = db.Exec("UPDATE sessions SET expires_at = ? WHERE id = (SELECT MAX(id) FROM sessions)", time.Now().UTC().Add(-time.Minute))
+	assert.NoError(t, err)
+
+	rr = authenticated(tablet["token"].(string), http.MethodGet, "", app.HandleGetSessions)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = authenticated(laptop["token"].(string), http.MethodGet, "", app.HandleGetSessions)
+	sessions = nil
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&sessions))
+	if assert.Len(t, sessions, 1) {
+		assert.True(t, sessions[0].Current)
+		assert.True(t, sessions[0].ExpiresAt.After(time.Now()))
+	}
+}
+
+// Tokens signed with the right secret but without the session claims are rejected, not a crash
+func TestTokensWithoutSessionClaimsAreRejected(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	handler := protected(app, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, claims := range []jwt.MapClaims{
+		{"sid": 1, "exp": time.Now().Add(time.Hour).Unix()},
+		{"user_id": "1", "sid": 1, "exp": time.Now().Add(time.Hour).Unix()},
+		{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()},
+	} {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(app.Config.Auth.JWTSecret))
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "%v", claims)
+	}
+}
+
+// Sessions record when they were last used, at most once per SessionTouchInterval
+func TestSessionsAreTouchedAtMostOncePerInterval(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	app := testApp(db)
+
+	insertUserWithPassword(t, db, "tester", "tester@gmail.com", "password")
+	_, login := serveJSON(t, app.HandleLogin, 0, map[string]string{"email": "tester@gmail.com", "password": "password"})
+
+	request := func() {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+login["token"].(string))
+		rr := httptest.NewRecorder()
+		protected(app, http.HandlerFunc(app.HandleGetSessions)).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	lastSeen := func() time.Time {
+		var seen time.Time
+		assert.NoError(t, db.QueryRow("SELECT last_seen_at FROM sessions").Scan(&seen))
+		return seen
+	}
+
+	recently := time.Now().UTC().Add(-10 * time.Second).Truncate(time.Second)
+	_, err := db.Exec("UPDATE sessions SET last_seen_at = ?", recently)
+	assert.NoError(t, err)
+	request()
+	assert.True(t, recently.Equal(lastSeen()))
+
+	longAgo := time.Now().UTC().Add(-repositories.SessionTouchInterval - time.Second)
+	_, err = db.Exec("UPDATE sessions SET last_seen_at = ?", longAgo)
+	assert.NoError(t, err)
+	request()
+	assert.True(t, lastSeen().After(longAgo.Add(time.Second)))
+}
@@ -2,20 +2,41 @@ package handlers_test
 
 import (
 	"bytes"
-	"context"
 	"database/sql"
 	"encoding/json"
+	"instagram/internal/config"
 	"instagram/internal/handlers"
 	"instagram/internal/middleware"
 	"instagram/internal/models"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// testConfig is the configuration handlers see in tests, with the cheapest bcrypt cost to keep them fast
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "a_secret_that_is_only_used_in_tests"
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+	return cfg
+}
+
+// testApp returns the App handlers run in during tests, on the test database
+func testApp(db *sql.DB) *handlers.App {
+	return handlers.NewApp(testConfig(), slog.Default(), db, nil)
+}
+
+// protected requires a valid token for the router, like the server does for its protected routes
+func protected(app *handlers.App, router http.Handler) http.Handler {
+	return middleware.JWTMiddleware(router, app.DB, app.Config.Auth.JWTSecret)
+}
+
 // Set up an in-memory SQLite DB for testing
 func setupTestDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite3", ":memory:")
@@ -23,18 +44,18 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("failed to open test database: %v", err)
 	}
 
+	// Only one connection may be used, every new connection would get its own empty in-memory database
+	db.SetMaxOpenConns(1)
+
 	// Initialize schema for testing
-	_, err = db.Exec(`CREATE TABLE users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL UNIQUE,
-		email TEXT NOT NULL UNIQUE,
-		password_hash TEXT NOT NULL,
-		bio TEXT,
-		profile_image TEXT,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)`)
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
 	if err != nil {
-		t.Fatalf("failed to create table: %v", err)
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
 	}
 
 	return db
@@ -71,11 +92,8 @@ func TestHandleGetUserById(t *testing.T) {
 	req := httptest.NewRequest("GET", "/users/", nil)
 	req.SetPathValue("id", "1") // users/1
 
-	ctx := context.WithValue(req.Context(), middleware.DBContextKey, db)
-	req = req.WithContext(ctx)
-
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(handlers.HandleGetUserById)
+	handler := http.HandlerFunc(testApp(db).HandleGetUserById)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
@@ -105,19 +123,18 @@ func TestHandleDeleteUserById(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Add context with DB and ID path value
-	ctx := context.WithValue(req.Context(), middleware.DBContextKey, db)
-	req = req.WithContext(ctx)
+	// Add context with the authenticated user and ID path value
+	req = req.WithContext(middleware.WithUser(req.Context(), 1, models.RoleUser))
 	req.SetPathValue("id", "1")
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(handlers.HandleDeleteUserById)
+	handler := http.HandlerFunc(testApp(db).HandleDeleteUserById)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusNoContent, rr.Code)
 
 	// Verify the user is deleted
-	row := db.QueryRow("SELECT id FROM users WHERE id = ?", 1)
+	row := db.QueryRow("SELECT id FROM users WHERE id = ? AND deleted_at IS NULL", 1)
 	var id int
 	err = row.Scan(&id)
 	assert.NotNil(t, err) // Expecting no user found
@@ -133,6 +150,12 @@ func TestHandlePatchUser(t *testing.T) {
 		t.Fatalf("failed to insert user: %v", err)
 	}
 
+	// The profile image is a file the user uploaded
+	_, err = db.Exec("INSERT INTO media_files (url, user_id) VALUES ('/media/profilepic.jpg', 1)")
+	if err != nil {
+		t.Fatalf("failed to insert media file: %v", err)
+	}
+
 	// Create a PATCH request to update the username
 	updatedUser := models.User{
 		Auth: models.Auth{
@@ -143,7 +166,7 @@ func TestHandlePatchUser(t *testing.T) {
 		},
 		Password:     "password",
 		Bio:          "bio",
-		ProfileImage: "profilepic",
+		ProfileImage: "/media/profilepic.jpg",
 		CreatedAt:    time.Now(),
 	}
 	userJSON, _ := json.Marshal(updatedUser)
@@ -152,13 +175,12 @@ func TestHandlePatchUser(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Add context with DB and ID path value
-	ctx := context.WithValue(req.Context(), middleware.DBContextKey, db)
-	req = req.WithContext(ctx)
+	// Add context with the authenticated user and ID path value
+	req = req.WithContext(middleware.WithUser(req.Context(), 1, models.RoleUser))
 	req.SetPathValue("id", "1")
 
 	rr := httptest.NewRecorder()
-	handler := http.HandlerFunc(handlers.HandlePatchUser)
+	handler := http.HandlerFunc(testApp(db).HandlePatchUser)
 	handler.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
@@ -0,0 +1,147 @@
+package jobs_test
+
+import (
+	"context"
+	"database/sql"
+	"instagram/internal/jobs"
+	"instagram/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // Import SQLite driver
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	return db
+}
+
+func TestPurger(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	media, err := storage.NewLocal(t.TempDir(), "/media")
+	assert.NoError(t, err)
+	save := func(name string) string {
+		url, err := media.Save(name, strings.NewReader("image"))
+		assert.NoError(t, err)
+		return url
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-31 * 24 * time.Hour)
+	recently := now.Add(-time.Hour)
+
+	exec := func(query string, args ...interface{}) {
+		_, err := db.Exec(query, args...)
+		assert.NoError(t, err)
+	}
+
+	// User 1 was deleted long ago, user 2 is active, user 3 was deleted recently
+	avatar := save("avatar.png")
+	exec("INSERT INTO users (username, email, password_hash, profile_image, deleted_at) VALUES ('gone', 'gone@gmail.com', 'x', ?, ?)", avatar, longAgo)
+	exec("INSERT INTO users (username, email, password_hash) VALUES ('active', 'active@gmail.com', 'x')")
+	exec("INSERT INTO users (username, email, password_hash, deleted_at) VALUES ('recent', 'recent@gmail.com', 'x', ?)", recently)
+
+	gonePost, oldPost, keptPost := save("a.jpg"), save("b.jpg"), save("c.jpg")
+	exec("INSERT INTO posts (user_id, image_url) VALUES (1, ?)", gonePost)
+	exec("INSERT INTO posts (user_id, image_url, deleted_at) VALUES (2, ?, ?)", oldPost, longAgo)
+	exec("INSERT INTO posts (user_id, image_url) VALUES (2, ?)", keptPost)
+	exec("INSERT INTO posts (user_id, image_url) VALUES (2, 'https://example.com/external.jpg')")
+
+	// Only recorded uploads are deleted with the rows of their uploader. User 1 also showed the image
+	// user 2 uploaded for a post that stays, which must survive user 1.
+	for uploader, urls := range map[int][]string{1: {avatar, gonePost}, 2: {oldPost, keptPost}} {
+		for _, url := range urls {
+			exec("INSERT INTO media_files (url, user_id) VALUES (?, ?)", url, uploader)
+		}
+	}
+	exec("INSERT INTO posts (user_id, image_url) VALUES (1, ?)", keptPost)
+
+	exec("INSERT INTO comments (user_id, post_id, content) VALUES (2, 1, 'on a purged post')")
+	exec("INSERT INTO comments (user_id, post_id, content) VALUES (1, 3, 'by a purged user')")
+	exec("INSERT INTO comments (user_id, post_id, content, deleted_at) VALUES (2, 3, 'deleted long ago', ?)", longAgo)
+	exec("INSERT INTO comments (user_id, post_id, content, deleted_at) VALUES (2, 3, 'deleted recently', ?)", recently)
+	exec("INSERT INTO follows (follower_id, following_id) VALUES (2, 1)")
+	exec("INSERT INTO likes (user_id, post_id) VALUES (1, 3)")
+	exec("INSERT INTO reports (reporter_id, target_type, target_id, reason, severity) VALUES (2, 'user', 1, 'spam', 1)")
+
+	exports := t.TempDir()
+	goneExport, keptExport := filepath.Join(exports, "gone.zip"), filepath.Join(exports, "kept.zip")
+	for _, path := range []string{goneExport, keptExport} {
+		assert.NoError(t, os.WriteFile(path, []byte("archive"), 0o600))
+	}
+	exec("INSERT INTO data_exports (user_id, status, file_path) VALUES (1, 'completed', ?)", goneExport)
+	exec("INSERT INTO data_exports (user_id, status, file_path) VALUES (2, 'completed', ?)", keptExport)
+
+	// Sessions are removed once they expire
+	exec("INSERT INTO sessions (user_id, expires_at) VALUES (2, ?)", recently)
+	exec("INSERT INTO sessions (user_id, expires_at) VALUES (2, ?)", now.Add(time.Hour))
+	exec("INSERT INTO used_mfa_challenges (token_id, expires_at) VALUES ('expired', ?), ('pending', ?)", recently, now.Add(time.Minute))
+
+	purger := jobs.NewPurger(db, media, 30*24*time.Hour)
+	purger.SetClock(func() time.Time { return now })
+	assert.NoError(t, purger.PurgeOnce(context.Background()))
+
+	count := func(query string) int {
+		var n int
+		assert.NoError(t, db.QueryRow(query).Scan(&n))
+		return n
+	}
+	assert.Equal(t, 2, count("SELECT COUNT(*) FROM users"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM users WHERE id = 1"))
+	assert.Equal(t, 2, count("SELECT COUNT(*) FROM posts"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM comments"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM follows"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM likes"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM reports"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM data_exports"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM sessions"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM used_mfa_challenges"))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM media_files"))
+
+	// The export archives of purged users hold their personal data and are deleted with them
+	_, err = os.Stat(goneExport)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(keptExport)
+	assert.NoError(t, err)
+
+	// Stored media of purged rows is deleted, everything else stays
+	for url, exists := range map[string]bool{avatar: false, gonePost: false, oldPost: false, keptPost: true} {
+		file, err := media.Open(url)
+		if exists {
+			assert.NoError(t, err, url)
+			file.Close()
+		} else {
+			assert.ErrorIs(t, err, os.ErrNotExist, url)
+		}
+	}
+
+	// Nothing else is due until the recent deletions pass the grace period
+	assert.NoError(t, purger.PurgeOnce(context.Background()))
+	assert.Equal(t, 2, count("SELECT COUNT(*) FROM users"))
+
+	purger.SetClock(func() time.Time { return now.Add(31 * 24 * time.Hour) })
+	assert.NoError(t, purger.PurgeOnce(context.Background()))
+	assert.Equal(t, 1, count("SELECT COUNT(*) FROM users"))
+	assert.Equal(t, 0, count("SELECT COUNT(*) FROM comments"))
+}
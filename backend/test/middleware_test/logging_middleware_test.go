@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"instagram/internal/logging"
+	"instagram/internal/middleware"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggingMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		logging.FromContext(r.Context()).Info("creating thing")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("thing"))
+	})
+	handler := middleware.RequestIDMiddleware(middleware.LoggingMiddleware(middleware.RecordRoute(mux), logger))
+
+	req := httptest.NewRequest(http.MethodPost, "/things/42", nil)
+	req.Header.Set(middleware.RequestIDHeader, "0123456789abcdef0123456789abcdef")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 2)
+
+	// Handlers log with the request ID
+	assert.Equal(t, "creating thing", records[0]["msg"])
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", records[0]["request_id"])
+
+	// One access log record per request
+	access := records[1]
+	assert.Equal(t, "request", access["msg"])
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", access["request_id"])
+	assert.Equal(t, "POST", access["method"])
+	assert.Equal(t, "POST /things/{id}", access["route"])
+	assert.Equal(t, "/things/42", access["path"])
+	assert.Equal(t, float64(http.StatusCreated), access["status"])
+	assert.Equal(t, float64(5), access["bytes"])
+	assert.Contains(t, access, "latency_ms")
+	assert.NotContains(t, access, "user_id")
+
+	// Server errors are logged at error level
+	buf.Reset()
+	mux.HandleFunc("GET /broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/broken", nil))
+	assert.Contains(t, buf.String(), `"level":"ERROR"`)
+	assert.Contains(t, buf.String(), `"route":"GET /broken"`)
+}
@@ -0,0 +1,51 @@
+package middleware_test
+
+import (
+	"database/sql"
+	"instagram/internal/metrics"
+	"instagram/internal/middleware"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handler := middleware.MetricsMiddleware(middleware.RecordRoute(mux))
+
+	matched := metrics.HTTPRequests.WithLabelValues("GET", "GET /things/{id}", "202")
+	unmatched := metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404")
+	before, beforeUnmatched := testutil.ToFloat64(matched), testutil.ToFloat64(unmatched)
+
+	for _, path := range []string{"/things/1", "/things/2", "/nothing/here"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are counted per route pattern, not per path
+	assert.Equal(t, before+2, testutil.ToFloat64(matched))
+	assert.Equal(t, beforeUnmatched+1, testutil.ToFloat64(unmatched))
+}
+
+func TestMetricsHandler(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	metrics.CommentsCreated.Inc()
+
+	rr := httptest.NewRecorder()
+	metrics.Handler(metrics.NewRegistry(db)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	body, _ := io.ReadAll(rr.Body)
+	assert.Contains(t, string(body), "instagram_comments_created_total")
+	assert.Contains(t, string(body), `go_sql_open_connections{db_name="instagram"}`)
+	assert.Contains(t, string(body), "go_goroutines")
+}
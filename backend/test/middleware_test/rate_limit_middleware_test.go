@@ -0,0 +1,78 @@
+package middleware_test
+
+import (
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "test", Limit: 2, Window: time.Minute, Key: middleware.KeyByIP,
+	})
+	limiter.SetClock(func() time.Time { return now })
+
+	handler := middleware.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), limiter)
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The burst is allowed and reported in the headers
+	rr := request("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+
+	rr = request("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+	// The bucket is empty, one token refills every 30 seconds
+	rr = request("10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+
+	// Other clients have their own bucket
+	rr = request("10.0.0.2:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	now = now.Add(30 * time.Second)
+	rr = request("10.0.0.1:1234")
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestRateLimitMiddlewareKeysByUser(t *testing.T) {
+	limiter := middleware.NewRateLimiter(middleware.RateLimitPolicy{
+		Name: "test", Limit: 1, Window: time.Hour, Key: middleware.KeyByUser,
+	})
+
+	handler := middleware.RateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), limiter)
+
+	request := func(userID int) int {
+		req := httptest.NewRequest(http.MethodPost, "/post/", nil)
+		req = req.WithContext(middleware.WithUser(req.Context(), userID, models.RoleUser))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Users behind the same IP don't share a bucket
+	assert.Equal(t, http.StatusOK, request(1))
+	assert.Equal(t, http.StatusOK, request(2))
+	assert.Equal(t, http.StatusTooManyRequests, request(1))
+}
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"instagram/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	var seen string
+	handler := middleware.RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = middleware.GetRequestIDFromContext(r.Context())
+	}))
+
+	serve := func(requestID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if requestID != "" {
+			req.Header.Set(middleware.RequestIDHeader, requestID)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// IDs are generated when missing and are unique
+	rr := serve("")
+	first := rr.Header().Get(middleware.RequestIDHeader)
+	assert.Len(t, first, 32)
+	assert.Equal(t, first, seen)
+	assert.NotEqual(t, first, serve("").Header().Get(middleware.RequestIDHeader))
+
+	// Well formed IDs from upstream proxies are propagated
+	rr = serve("proxy-abc-123")
+	assert.Equal(t, "proxy-abc-123", rr.Header().Get(middleware.RequestIDHeader))
+	assert.Equal(t, "proxy-abc-123", seen)
+
+	// Malformed IDs are replaced
+	rr = serve("has spaces")
+	assert.NotEqual(t, "has spaces", seen)
+	rr = serve(strings.Repeat("a", 200))
+	assert.Len(t, seen, 32)
+}
@@ -0,0 +1,57 @@
+package middleware_test
+
+import (
+	"instagram/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /things/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// Handlers can add their own spans to the trace of the request
+		assert.True(t, trace.SpanContextFromContext(r.Context()).IsValid())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	handler := middleware.TracingMiddleware(middleware.Traced("test", middleware.RecordRoute(mux)))
+
+	req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+	route, middlewareSpan, server := spans[0], spans[1], spans[2]
+
+	// The trace of the caller is continued
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, "GET /things/{id}", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, codes.Error, server.Status().Code)
+
+	assert.Equal(t, "test", middlewareSpan.Name())
+	assert.Equal(t, server.SpanContext().SpanID(), middlewareSpan.Parent().SpanID())
+	assert.Equal(t, "GET /things/{id}", route.Name())
+	assert.Equal(t, middlewareSpan.SpanContext().SpanID(), route.Parent().SpanID())
+}
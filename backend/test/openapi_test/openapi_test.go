@@ -0,0 +1,137 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"instagram/internal/config"
+	"instagram/internal/handlers"
+	"instagram/internal/openapi"
+	"instagram/internal/routes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildRouters registers every route the server serves
+func buildRouters() {
+	app := handlers.NewApp(config.Default(), slog.Default(), nil, nil)
+	routes.AuthRouter(app)
+	routes.UserRouter(app)
+	routes.FollowRouter(app)
+	routes.PostRouter(app)
+	routes.CommentRouter(app)
+	routes.ReportRouter(app)
+	routes.AdminRouter(app)
+	routes.ImportRouter(app)
+	routes.DataExportRouter(app)
+	routes.GraphQLRouter(app)
+}
+
+func TestSpecCoversEveryRoute(t *testing.T) {
+	buildRouters()
+	patterns := routes.Patterns()
+	assert.NotEmpty(t, patterns)
+
+	spec := openapi.Spec()
+	for _, pattern := range patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !assert.True(t, ok, "route %q has no method", pattern) {
+			continue
+		}
+
+		operation := spec.Paths[path][strings.ToLower(method)]
+		if assert.NotNil(t, operation, "route %q is missing from the OpenAPI document", pattern) {
+			assert.NotEmpty(t, operation.Summary, "route %q has no summary", pattern)
+		}
+	}
+}
+
+func TestSpecPathParameters(t *testing.T) {
+	operation := openapi.Spec().Paths["/admin/reports/{target_type}/{target_id}"]["get"]
+	if !assert.NotNil(t, operation) || !assert.Len(t, operation.Parameters, 2) {
+		return
+	}
+
+	assert.Equal(t, "target_type", operation.Parameters[0].Name)
+	assert.Equal(t, "string", operation.Parameters[0].Schema.Type)
+	assert.Equal(t, "target_id", operation.Parameters[1].Name)
+	assert.Equal(t, "integer", operation.Parameters[1].Schema.Type)
+	assert.True(t, operation.Parameters[1].Required)
+}
+
+func TestSpecSchemasFollowValidationRules(t *testing.T) {
+	schemas := openapi.Spec().Components.Schemas
+
+	comment := schemas["Comment"]
+	if !assert.NotNil(t, comment) {
+		return
+	}
+	assert.ElementsMatch(t, []string{"post_id", "user_id", "content"}, comment.Required)
+	assert.Equal(t, 2200, *comment.Properties["content"].MaxLength)
+	assert.Equal(t, float64(0), *comment.Properties["post_id"].ExclusiveMinimum)
+
+	// Fields of embedded structs are flattened and hidden fields are left out
+	user := schemas["User"]
+	if !assert.NotNil(t, user) {
+		return
+	}
+	assert.Equal(t, "email", user.Properties["email"].Format)
+	assert.NotEmpty(t, user.Properties["username"].Pattern)
+	assert.Equal(t, "date-time", user.Properties["created_at"].Format)
+	assert.NotContains(t, user.Properties, "password_hash")
+	assert.NotContains(t, user.Properties, "PasswordHash")
+}
+
+func TestHandlerServesDocument(t *testing.T) {
+	rr := httptest.NewRecorder()
+	openapi.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var document map[string]any
+	if !assert.NoError(t, json.NewDecoder(rr.Body).Decode(&document)) {
+		return
+	}
+	assert.Equal(t, openapi.Version, document["openapi"])
+
+	// Every reference points at a schema in the components
+	schemas := document["components"].(map[string]any)["schemas"].(map[string]any)
+	var refs func(v any)
+	refs = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name, found := strings.CutPrefix(ref, "#/components/schemas/")
+				if assert.True(t, found, "unexpected reference %q", ref) {
+					assert.Contains(t, schemas, name)
+				}
+			}
+			for _, value := range v {
+				refs(value)
+			}
+		case []any:
+			for _, value := range v {
+				refs(value)
+			}
+		}
+	}
+	refs(document)
+}
+
+func TestDocsHandlerServesSwaggerUI(t *testing.T) {
+	handler := openapi.DocsHandler("/docs")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs/", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "swagger-ui")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/docs/swagger-initializer.js", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"/openapi.json"`)
+}
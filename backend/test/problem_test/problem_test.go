@@ -0,0 +1,55 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"instagram/internal/problem"
+	"instagram/internal/repositories"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeError(t *testing.T, err error) (*httptest.ResponseRecorder, problem.Details) {
+	rr := httptest.NewRecorder()
+	problem.Error(rr, httptest.NewRequest(http.MethodGet, "/post/1", nil), err)
+
+	var details problem.Details
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&details))
+	return rr, details
+}
+
+func TestErrorMapsDomainErrors(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{repositories.NotFound("post with id %d not found", 1), http.StatusNotFound},
+		{repositories.Conflict("username or email is already taken"), http.StatusConflict},
+		{repositories.Validation("user ID is required for updating"), http.StatusUnprocessableEntity},
+		// Domain errors are recognized when wrapped too
+		{fmt.Errorf("failed to retrieve updated user: %w", repositories.NotFound("user with id 1 not found")), http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rr, details := writeError(t, test.err)
+		assert.Equal(t, test.status, rr.Code)
+		assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+		assert.Equal(t, test.status, details.Status)
+		assert.Equal(t, http.StatusText(test.status), details.Title)
+		assert.Equal(t, "/post/1", details.Instance)
+		assert.NotEmpty(t, details.Detail)
+	}
+}
+
+func TestErrorHidesInternalErrors(t *testing.T) {
+	rr, details := writeError(t, errors.New("failed to get post: no such table: posts"))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, http.StatusInternalServerError, details.Status)
+	assert.Empty(t, details.Detail)
+	assert.NotContains(t, rr.Body.String(), "no such table")
+}
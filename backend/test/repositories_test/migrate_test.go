@@ -0,0 +1,121 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// schema describes the tables, columns, indexes and triggers of a SQLite database. Columns added by a
+// migration come last, so they are compared regardless of their order.
+func schema(t *testing.T, db *sql.DB) map[string][]string {
+	objects := make(map[string][]string)
+	rows, err := db.Query(`SELECT type, name, tbl_name FROM sqlite_master
+        WHERE name NOT LIKE 'sqlite_%' AND name != 'schema_migrations' ORDER BY name`)
+	if err != nil {
+		t.Fatalf("failed to list schema: %v", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var kind, name, table string
+		if err := rows.Scan(&kind, &name, &table); err != nil {
+			t.Fatalf("failed to list schema: %v", err)
+		}
+		objects[kind+" "+name] = []string{table}
+		if kind == "table" {
+			tables = append(tables, name)
+		}
+	}
+	_ = rows.Close()
+
+	for _, table := range tables {
+		columns, err := db.Query(`SELECT name, type, "notnull", COALESCE(dflt_value, ''), pk FROM pragma_table_info(?) ORDER BY name`, table)
+		if err != nil {
+			t.Fatalf("failed to list columns of %s: %v", table, err)
+		}
+		for columns.Next() {
+			var name, kind, dflt string
+			var notNull, pk int
+			if err := columns.Scan(&name, &kind, &notNull, &dflt, &pk); err != nil {
+				t.Fatalf("failed to list columns of %s: %v", table, err)
+			}
+			objects["table "+table] = append(objects["table "+table], fmt.Sprint(name, kind, notNull, dflt, pk))
+		}
+		_ = columns.Close()
+	}
+	return objects
+}
+
+func openFile(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// The database shipped with the first release is upgraded to the schema of sql/inititialize_db.sql
+func TestMigrateUpgradesShippedDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "instagram.db")
+	copyFile(t, "../../instagram.db", path)
+	db := openFile(t, path)
+
+	assert.Error(t, repositories.CheckSchema(ctx, db), "the shipped database predates migrations")
+	assert.NoError(t, repositories.Migrate(ctx, db))
+	assert.NoError(t, repositories.CheckSchema(ctx, db))
+
+	// Migrating an up to date database does nothing
+	assert.NoError(t, repositories.Migrate(ctx, db))
+
+	expected := openFile(t, filepath.Join(t.TempDir(), "expected.db"))
+	initSchema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := expected.Exec(string(initSchema)); err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+	assert.Equal(t, schema(t, expected), schema(t, db))
+
+	user, err := repositories.SaveUser(ctx, db, &models.User{Auth: models.Auth{Username: "tester", Email: "tester@example.com", PasswordHash: "x"}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, models.RoleUser, user.Role)
+	}
+}
+
+// A new database gets every migration
+func TestMigrateCreatesDatabase(t *testing.T) {
+	ctx := context.Background()
+	db := openFile(t, filepath.Join(t.TempDir(), "new.db"))
+
+	assert.NoError(t, repositories.Migrate(ctx, db))
+	assert.NoError(t, repositories.CheckSchema(ctx, db))
+	_, err := repositories.GetAuditEvents(ctx, db, models.AuditFilter{})
+	assert.NoError(t, err)
+}
+
+func copyFile(t *testing.T, from, to string) {
+	src, err := os.Open(from)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", from, err)
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		t.Fatalf("failed to create %s: %v", to, err)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatalf("failed to copy %s: %v", from, err)
+	}
+}
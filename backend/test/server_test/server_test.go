@@ -0,0 +1,140 @@
+package server_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"instagram/internal/config"
+	"instagram/internal/server"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // Import SQLite driver
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	return db
+}
+
+func get(handler http.Handler, path string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	return rr
+}
+
+func TestHealthAndReadiness(t *testing.T) {
+	db := setupTestDB(t)
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	srv := server.New(config.Default().Server, db, api)
+
+	assert.Equal(t, http.StatusOK, get(srv.Handler(), "/healthz").Code)
+	assert.Equal(t, http.StatusOK, get(srv.Handler(), "/readyz").Code)
+	assert.Equal(t, http.StatusTeapot, get(srv.Handler(), "/users/1").Code)
+
+	// Without the database the server is alive but not ready
+	assert.NoError(t, db.Close())
+	assert.Equal(t, http.StatusOK, get(srv.Handler(), "/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get(srv.Handler(), "/readyz").Code)
+}
+
+func TestReadinessChecks(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	srv := server.New(config.Default().Server, db, http.NotFoundHandler())
+
+	var checkErr error
+	srv.AddReadinessCheck("Database schema", func(ctx context.Context) error { return checkErr })
+	assert.Equal(t, http.StatusOK, get(srv.Handler(), "/readyz").Code)
+
+	checkErr = errors.New("database schema is at version 1, expected 2")
+	rr := get(srv.Handler(), "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Database schema check failed")
+	assert.NotContains(t, rr.Body.String(), "version 1")
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = 5 * time.Second
+	srv := server.New(cfg, db, api)
+
+	workerStopped := false
+	srv.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		workerStopped = true
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, listener) }()
+
+	// Start a request and shut down while it is in flight
+	response := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		response <- string(body)
+	}()
+	<-started
+	cancel()
+
+	// The server reports not ready while it drains
+	assert.Eventually(t, func() bool {
+		return get(srv.Handler(), "/readyz").Code == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	close(release)
+	assert.Equal(t, "done", <-response)
+	assert.NoError(t, <-served)
+	assert.True(t, workerStopped)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	cfg := config.Default().Server
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+	srv := server.New(cfg, db, http.NotFoundHandler())
+
+	// A worker that ignores cancellation does not keep the process alive
+	block := make(chan struct{})
+	defer close(block)
+	srv.Go(func(ctx context.Context) { <-block })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorContains(t, srv.Serve(ctx, listener), "did not stop in time")
+}
@@ -0,0 +1,305 @@
+package services_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"instagram/internal/metrics"
+	"instagram/internal/middleware"
+	"instagram/internal/models"
+	"instagram/internal/repositories"
+	"instagram/internal/services"
+	"instagram/internal/utils"
+	"instagram/internal/validation"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	// Only one connection may be used, every new connection would get its own empty in-memory database
+	db.SetMaxOpenConns(1)
+
+	schema, err := os.ReadFile("../../sql/inititialize_db.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	_, err = db.Exec(string(schema))
+	if err != nil {
+		t.Fatalf("failed to create tables: %v", err)
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO users (id, username, email, password_hash, role) VALUES
+            (1, 'tester', 'tester@example.com', 'x', 'user'),
+            (2, 'alice', 'alice@example.com', 'x', 'user'),
+            (3, 'admin', 'admin@example.com', 'x', 'admin');
+        INSERT INTO posts (id, user_id, image_url, caption) VALUES (1, 2, 'https://example.com/1.jpg', 'first');
+    `)
+	if err != nil {
+		t.Fatalf("failed to insert test data: %v", err)
+	}
+	return db
+}
+
+// as returns a context authenticated as the user, like the JWT middleware does
+func as(userID int, role string) context.Context {
+	return middleware.WithUser(context.Background(), userID, role)
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	var n int
+	if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("failed to count: %v", err)
+	}
+	return n
+}
+
+func TestFollowRules(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+	ctx := as(1, models.RoleUser)
+
+	err := svc.Follows.Follow(ctx, &models.Follow{FollowerID: 1, FollowingID: 1}, metrics.SourceAPI)
+	assert.ErrorIs(t, err, repositories.ErrInvalid)
+	assert.EqualError(t, err, "You can't follow yourself")
+
+	err = svc.Follows.Follow(ctx, &models.Follow{FollowerID: 1, FollowingID: 42}, metrics.SourceAPI)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+	err = svc.Follows.Follow(ctx, &models.Follow{FollowerID: 1, FollowingID: 0}, metrics.SourceAPI)
+	assert.Error(t, err, "invalid fields are rejected before the stores are used")
+
+	assert.NoError(t, svc.Follows.Follow(ctx, &models.Follow{FollowerID: 1, FollowingID: 2}, metrics.SourceAPI))
+	err = svc.Follows.Follow(ctx, &models.Follow{FollowerID: 1, FollowingID: 2}, metrics.SourceAPI)
+	assert.ErrorIs(t, err, repositories.ErrConflict)
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM follows`))
+
+	assert.NoError(t, svc.Follows.Unfollow(ctx, &models.Follow{FollowerID: 1, FollowingID: 2}))
+	assert.Equal(t, 0, count(t, db, `SELECT COUNT(*) FROM follows`))
+}
+
+func TestUserRules(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+
+	_, err := svc.Users.Create(context.Background(), &models.User{Auth: models.Auth{Username: "bob"}})
+	assert.ErrorIs(t, err, repositories.ErrInvalid)
+
+	created, err := svc.Users.Create(context.Background(), &models.User{
+		Auth:     models.Auth{Username: "bob", Email: "bob@example.com"},
+		Password: "password",
+	})
+	if assert.NoError(t, err) {
+		assert.Empty(t, created.Password)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(created.PasswordHash), []byte("password")))
+	}
+
+	// Users of identity providers have no password to log in with
+	external, err := svc.Users.CreateExternal(context.Background(), &models.User{
+		Auth: models.Auth{Username: "carol", Email: "carol@example.com", PasswordHash: "chosen-by-caller"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, utils.UnusablePasswordHash, external.PasswordHash)
+	}
+	_, err = svc.Users.CreateExternal(context.Background(), &models.User{Auth: models.Auth{Username: "Not Valid", Email: "dave@example.com"}})
+	assert.Error(t, err)
+
+	// Only the user and admins may change a profile
+	update := &models.User{Auth: models.Auth{ID: 2, Email: "mallory@example.com"}}
+	_, _, err = svc.Users.Update(as(1, models.RoleUser), update)
+	assert.ErrorIs(t, err, repositories.ErrForbidden)
+
+	updated, previous, err := svc.Users.Update(as(3, models.RoleAdmin), update)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "alice@example.com", previous.Email)
+		assert.Equal(t, "mallory@example.com", updated.Email)
+	}
+
+	assert.ErrorIs(t, svc.Users.Delete(as(1, models.RoleUser), 2), repositories.ErrForbidden)
+
+	// Only the user may deactivate their account, which hides it from everyone else
+	assert.ErrorIs(t, svc.Users.Deactivate(as(3, models.RoleAdmin), 2), repositories.ErrForbidden)
+	assert.NoError(t, svc.Users.Deactivate(as(2, models.RoleUser), 2))
+
+	_, err = svc.Users.Get(as(1, models.RoleUser), 2)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	_, err = svc.Users.Get(as(2, models.RoleUser), 2)
+	assert.NoError(t, err)
+}
+
+func TestDeactivateRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+	stores := repositories.NewSQLiteStores(db)
+
+	// Revoking the sessions fails after the account was deactivated in the same transaction
+	tx := stores.Tx
+	stores.Tx = func(ctx context.Context, fn func(tx *repositories.Stores) error) error {
+		return tx(ctx, func(tx *repositories.Stores) error {
+			tx.Sessions = failingSessions{}
+			return fn(tx)
+		})
+	}
+	svc := services.New(stores, bcrypt.MinCost)
+
+	err := svc.Users.Deactivate(as(2, models.RoleUser), 2)
+	assert.EqualError(t, err, "sessions are unavailable")
+	assert.Equal(t, 0, count(t, db, `SELECT COUNT(*) FROM users WHERE deactivated_at IS NOT NULL`))
+}
+
+type failingSessions struct{}
+
+func (failingSessions) RevokeAll(context.Context, int) error {
+	return errors.New("sessions are unavailable")
+}
+
+func TestContentRules(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+	author, other, admin := as(2, models.RoleUser), as(1, models.RoleUser), as(3, models.RoleAdmin)
+
+	comment := &models.Comment{PostID: 1, UserID: 1, Content: "nice"}
+	assert.NoError(t, svc.Comments.Create(other, comment))
+	assert.ErrorIs(t, svc.Comments.Delete(author, comment.ID), repositories.ErrForbidden)
+	assert.NoError(t, svc.Comments.Delete(admin, comment.ID))
+	assert.ErrorIs(t, svc.Comments.Restore(author, comment.ID), repositories.ErrForbidden)
+	assert.NoError(t, svc.Comments.Restore(other, comment.ID))
+
+	assert.ErrorIs(t, svc.Posts.Delete(other, 1), repositories.ErrForbidden)
+	assert.NoError(t, svc.Posts.Delete(author, 1))
+
+	// Deleted posts cannot be commented on until they are restored
+	err := svc.Comments.Create(other, &models.Comment{PostID: 1, UserID: 1, Content: "still there?"})
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM comments`))
+
+	assert.ErrorIs(t, svc.Posts.Restore(other, 1), repositories.ErrForbidden)
+	assert.NoError(t, svc.Posts.Restore(author, 1))
+	assert.NoError(t, svc.Comments.Create(other, &models.Comment{PostID: 1, UserID: 1, Content: "welcome back"}))
+
+	// Content a moderator removed can only be restored by an admin
+	stores := repositories.NewSQLiteStores(db)
+	assert.NoError(t, stores.Posts.Remove(context.Background(), 1))
+	assert.NoError(t, stores.Comments.Remove(context.Background(), comment.ID))
+	assert.ErrorIs(t, svc.Posts.Restore(author, 1), repositories.ErrForbidden)
+	assert.ErrorIs(t, svc.Comments.Restore(other, comment.ID), repositories.ErrForbidden)
+	assert.NoError(t, svc.Posts.Restore(admin, 1))
+	assert.NoError(t, svc.Comments.Restore(admin, comment.ID))
+}
+
+// Hidden posts and comments are only shown to their authors and admins, and hidden posts can't be commented on
+func TestHiddenContent(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+	author, other, admin := as(2, models.RoleUser), as(1, models.RoleUser), as(3, models.RoleAdmin)
+
+	comment := &models.Comment{PostID: 1, UserID: 1, Content: "nice"}
+	assert.NoError(t, svc.Comments.Create(other, comment))
+	_, err := db.Exec(`INSERT INTO report_resolutions (target_type, target_id, resolution) VALUES ('comment', ?, 'hidden'), ('post', 1, 'hidden')`, comment.ID)
+	assert.NoError(t, err)
+
+	_, err = svc.Posts.Get(other, 1)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	_, err = svc.Posts.Get(author, 1)
+	assert.NoError(t, err)
+	_, err = svc.Posts.Get(admin, 1)
+	assert.NoError(t, err)
+
+	_, err = svc.Comments.Get(author, comment.ID)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	_, err = svc.Comments.Get(other, comment.ID)
+	assert.NoError(t, err)
+	_, err = svc.Comments.Get(admin, comment.ID)
+	assert.NoError(t, err)
+
+	err = svc.Comments.Create(author, &models.Comment{PostID: 1, UserID: 2, Content: "hello?"})
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM comments`))
+}
+
+// Paths on this server can only be used by the user who uploaded the file, or the purge of one user's post
+// would delete another user's image
+func TestMediaOfOtherUsers(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+	_, err := db.Exec(`INSERT INTO media_files (url, user_id) VALUES ('/media/alice.jpg', 2)`)
+	assert.NoError(t, err)
+
+	var validationErrs validation.Errors
+	for _, url := range []string{"/media/alice.jpg", "/media/unknown.jpg"} {
+		err = svc.Posts.Create(as(1, models.RoleUser), &models.Post{UserID: 1, ImageURL: url})
+		assert.ErrorAs(t, err, &validationErrs, url)
+
+		_, _, err = svc.Users.Update(as(1, models.RoleUser), &models.User{Auth: models.Auth{ID: 1, Username: "tester", Email: "tester@example.com"}, ProfileImage: url})
+		assert.ErrorAs(t, err, &validationErrs, url)
+	}
+	_, err = svc.Users.Create(context.Background(), &models.User{Auth: models.Auth{Username: "new", Email: "new@example.com"}, Password: "password", ProfileImage: "/media/alice.jpg"})
+	assert.ErrorAs(t, err, &validationErrs)
+
+	// Uploaders and external images are fine, also for an admin posting as the uploader
+	assert.NoError(t, svc.Posts.Create(as(2, models.RoleUser), &models.Post{UserID: 2, ImageURL: "/media/alice.jpg"}))
+	assert.NoError(t, svc.Posts.Create(as(3, models.RoleAdmin), &models.Post{UserID: 2, ImageURL: "/media/alice.jpg"}))
+	assert.NoError(t, svc.Posts.Create(as(1, models.RoleUser), &models.Post{UserID: 1, ImageURL: "https://example.com/alice.jpg"}))
+	_, _, err = svc.Users.Update(as(2, models.RoleUser), &models.User{Auth: models.Auth{ID: 2, Username: "alice", Email: "alice@example.com"}, ProfileImage: "/media/alice.jpg"})
+	assert.NoError(t, err)
+
+	// Imported images are recorded as uploads of the user they were imported for
+	imported := &models.Post{UserID: 1, ImageURL: "/media/imported.jpg", CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.ErrorIs(t, svc.Posts.Import(as(2, models.RoleUser), imported, models.ImportSourceInstagram, "a.jpg"), repositories.ErrForbidden)
+	if assert.NoError(t, svc.Posts.Import(as(1, models.RoleUser), imported, models.ImportSourceInstagram, "a.jpg")) {
+		assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM media_files WHERE url = '/media/imported.jpg' AND user_id = 1"))
+		assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM imported_items WHERE post_id = ?", imported.ID))
+		assert.NoError(t, svc.Posts.Create(as(1, models.RoleUser), &models.Post{UserID: 1, ImageURL: "/media/imported.jpg"}))
+	}
+}
+
+// The services only depend on the stores, which can be replaced by fakes
+func TestServicesWithoutDatabase(t *testing.T) {
+	stores := &repositories.Stores{Users: fakeUsers{}}
+	svc := services.New(stores, bcrypt.MinCost)
+
+	_, err := svc.Users.Get(context.Background(), 7)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+}
+
+type fakeUsers struct {
+	repositories.UserStore
+}
+
+func (fakeUsers) Get(_ context.Context, id int) (*models.User, error) {
+	now := time.Now()
+	return &models.User{Auth: models.Auth{ID: id}, DeactivatedAt: &now}, nil
+}
+
+// Only admins may act as somebody else, whatever user ID the request names
+func TestActingAsAnotherUser(t *testing.T) {
+	db := setupTestDB(t)
+	svc := services.New(repositories.NewSQLiteStores(db), bcrypt.MinCost)
+	tester, admin := as(1, models.RoleUser), as(3, models.RoleAdmin)
+
+	asAlice := &models.Post{UserID: 2, ImageURL: "https://example.com/2.jpg"}
+	assert.ErrorIs(t, svc.Posts.Create(tester, asAlice), repositories.ErrForbidden)
+	assert.ErrorIs(t, svc.Comments.Create(tester, &models.Comment{PostID: 1, UserID: 2, Content: "me"}), repositories.ErrForbidden)
+	assert.ErrorIs(t, svc.Follows.Follow(tester, &models.Follow{FollowerID: 2, FollowingID: 1}, metrics.SourceAPI), repositories.ErrForbidden)
+	assert.ErrorIs(t, svc.Follows.Unfollow(tester, &models.Follow{FollowerID: 2, FollowingID: 1}), repositories.ErrForbidden)
+	assert.ErrorIs(t, svc.Posts.Create(context.Background(), asAlice), repositories.ErrForbidden, "unauthenticated")
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM posts`))
+	assert.Equal(t, 0, count(t, db, `SELECT COUNT(*) FROM comments`))
+	assert.Equal(t, 0, count(t, db, `SELECT COUNT(*) FROM follows`))
+
+	assert.NoError(t, svc.Posts.Create(admin, asAlice))
+	assert.NoError(t, svc.Comments.Create(admin, &models.Comment{PostID: 1, UserID: 2, Content: "me"}))
+	assert.NoError(t, svc.Follows.Follow(admin, &models.Follow{FollowerID: 2, FollowingID: 1}, metrics.SourceAPI))
+	assert.NoError(t, svc.Follows.Unfollow(admin, &models.Follow{FollowerID: 2, FollowingID: 1}))
+	assert.Equal(t, 2, count(t, db, `SELECT COUNT(*) FROM posts WHERE user_id = 2`))
+}
@@ -0,0 +1,75 @@
+package tracing_test
+
+import (
+	"context"
+	"instagram/internal/tracing"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestOpenDBTracesStatements(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	db, err := tracing.OpenDB("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// Statements outside of a trace are not recorded
+	_, err = db.Exec("CREATE TABLE things (name TEXT)")
+	assert.NoError(t, err)
+	assert.Empty(t, recorder.Ended())
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "parent")
+	_, err = db.ExecContext(ctx, "INSERT INTO things (name) VALUES (?), (?)", "a", "b")
+	assert.NoError(t, err)
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM things")
+	assert.NoError(t, err)
+	for rows.Next() {
+	}
+	assert.NoError(t, rows.Close())
+	parent.End()
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+
+	insert, query := spans[0], spans[1]
+	assert.Equal(t, "INSERT", insert.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), insert.Parent().SpanID())
+	assert.Equal(t, "sqlite", attributes(insert)["db.system"].AsString())
+	assert.Equal(t, "INSERT INTO things (name) VALUES (?), (?)", attributes(insert)["db.query.text"].AsString())
+	assert.Equal(t, int64(2), attributes(insert)["db.response.affected_rows"].AsInt64())
+
+	assert.Equal(t, "SELECT", query.Name())
+	assert.Equal(t, int64(2), attributes(query)["db.response.returned_rows"].AsInt64())
+}
+
+func TestOpenDBUnknownDriver(t *testing.T) {
+	_, err := tracing.OpenDB("nope", ":memory:")
+	assert.Error(t, err)
+}
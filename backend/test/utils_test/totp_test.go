@@ -0,0 +1,75 @@
+package utils_test
+
+import (
+	"encoding/base32"
+	"instagram/internal/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 test key from RFC 6238 Appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTPCodeMatchesRFC6238(t *testing.T) {
+	// RFC 6238 publishes 8 digit codes; our 6 digit codes are their last six digits
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, expected := range vectors {
+		code, err := utils.GenerateTOTPCode(rfcSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected[2:], code, "time %d", unix)
+	}
+}
+
+func TestVerifyTOTPCodeAllowsOneStepOfSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := utils.GenerateTOTPCode(rfcSecret, now)
+	assert.NoError(t, err)
+
+	assert.True(t, utils.VerifyTOTPCode(rfcSecret, code, now))
+	assert.True(t, utils.VerifyTOTPCode(rfcSecret, code, now.Add(utils.TOTPPeriod)))
+	assert.False(t, utils.VerifyTOTPCode(rfcSecret, code, now.Add(3*utils.TOTPPeriod)))
+	assert.False(t, utils.VerifyTOTPCode(rfcSecret, "12345", now))
+}
+
+// The matched step is the one the code was generated for, whatever the skew
+func TestMatchTOTPCodeReturnsTheCodesStep(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := utils.GenerateTOTPCode(rfcSecret, now)
+	assert.NoError(t, err)
+
+	for _, at := range []time.Time{now, now.Add(utils.TOTPPeriod)} {
+		step, ok := utils.MatchTOTPCode(rfcSecret, code, at)
+		assert.True(t, ok)
+		assert.Equal(t, int64(1234567890/30), step)
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := utils.TOTPProvisioningURI("Instagram", "tester@gmail.com", "SECRET")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Instagram:tester@gmail.com?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=Instagram")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := utils.GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	for _, code := range codes {
+		assert.Len(t, code, 11)
+		assert.Equal(t, utils.HashRecoveryCode(code), utils.HashRecoveryCode(" "+strings.ToUpper(code)+" "))
+	}
+}
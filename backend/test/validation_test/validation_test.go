@@ -0,0 +1,73 @@
+package validation_test
+
+import (
+	"instagram/internal/models"
+	"instagram/internal/validation"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructReportsInvalidFields(t *testing.T) {
+	user := models.User{
+		Auth: models.Auth{Username: "Not Valid!", Email: "not-an-email"},
+		Bio:  strings.Repeat("a", 151),
+	}
+
+	err := validation.Struct(&user)
+
+	var errs validation.Errors
+	if !assert.ErrorAs(t, err, &errs) {
+		return
+	}
+	fields := map[string]string{}
+	for _, fieldErr := range errs {
+		fields[fieldErr.Field] = fieldErr.Message
+	}
+	assert.Equal(t, map[string]string{
+		"username": "may only contain lowercase letters, digits, dots and underscores",
+		"email":    "must be a valid email address",
+		"bio":      "must be at most 150 characters long",
+	}, fields)
+}
+
+func TestStructAcceptsValidModels(t *testing.T) {
+	assert.NoError(t, validation.Struct(&models.User{Auth: models.Auth{Username: "jane.doe_1", Email: "jane@example.com"}}))
+	assert.NoError(t, validation.Struct(&models.Post{UserID: 1, ImageURL: "/media/posts/1.jpg"}))
+	assert.NoError(t, validation.Struct(&models.Post{UserID: 1, ImageURL: "https://example.com/1.jpg"}))
+	assert.NoError(t, validation.Struct(&models.Comment{UserID: 1, PostID: 1, Content: "Nice"}))
+	assert.NoError(t, validation.Struct(&models.Follow{FollowerID: 1, FollowingID: 2}))
+}
+
+func TestStructChecksURLsAndLengths(t *testing.T) {
+	for _, post := range []models.Post{
+		{ImageURL: ""},
+		{ImageURL: "javascript:alert(1)"},
+		{ImageURL: "relative/path.jpg"},
+		{ImageURL: "/media/1.jpg", Caption: strings.Repeat("é", 2201)},
+	} {
+		assert.Error(t, validation.Struct(&post), post.ImageURL)
+	}
+
+	assert.Error(t, validation.Struct(&models.Comment{UserID: 1, PostID: 1}))
+	assert.Error(t, validation.Struct(&models.Follow{FollowerID: 1}))
+}
+
+func TestDecode(t *testing.T) {
+	decode := func(body string) error {
+		var comment models.Comment
+		req := httptest.NewRequest(http.MethodPost, "/comment/", strings.NewReader(body))
+		return validation.Decode(httptest.NewRecorder(), req, &comment)
+	}
+
+	assert.NoError(t, decode(`{"user_id": 1, "post_id": 1, "content": "Nice"}`))
+	assert.ErrorContains(t, decode(`{"user_id": 1, "likes": 1000}`), "unknown field")
+	assert.Error(t, decode(`{"user_id": 1} {"user_id": 2}`))
+
+	var maxBytesErr *http.MaxBytesError
+	err := decode(`{"content": "` + strings.Repeat("a", validation.MaxBodyBytes) + `"}`)
+	assert.ErrorAs(t, err, &maxBytesErr)
+}